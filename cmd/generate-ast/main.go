@@ -18,10 +18,20 @@ func main() {
 	packageName := "ast"
 
 	typeDefs := []string{
-		"Binary   : left Expr, operator token.Token, right Expr",
-		"Grouping : expression Expr",
-		"Literal  : value any",
-		"Unary    : operator token.Token, right Expr",
+		"BadExpr     : tokens []token.Token",
+		"Binary      : left Expr, operator token.Token, right Expr",
+		"Call        : callee Expr, paren token.Token, arguments []Expr",
+		"Conditional : condition Expr, thenBranch Expr, elseBranch Expr",
+		"Grouping    : expression Expr",
+		"Index       : object Expr, bracket token.Token, index Expr",
+		"ListLiteral : bracket token.Token, elements []Expr",
+		"Literal     : value any",
+		"MapLiteral  : brace token.Token, keys []Expr, values []Expr",
+		"Property    : object Expr, name token.Token",
+		"SetIndex    : object Expr, bracket token.Token, index Expr, value Expr",
+		"SetProperty : object Expr, name token.Token, value Expr",
+		"Unary       : operator token.Token, right Expr",
+		"Variable    : name token.Token",
 	}
 
 	err := ast.GenerateAst(outputFile, packageName, typeDefs)
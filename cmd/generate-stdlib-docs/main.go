@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/taylorlowery/lox/internal/stdlib"
+)
+
+func main() {
+	if len(os.Args) != 2 {
+		fmt.Println("usage: generate-stdlib-docs <output file>")
+		os.Exit(64)
+	}
+
+	file, err := os.Create(os.Args[1])
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(74)
+	}
+	defer file.Close()
+
+	if err := stdlib.WriteReference(file, stdlib.Modules(stdlib.NewSource(0))); err != nil {
+		fmt.Println(err)
+		os.Exit(74)
+	}
+}
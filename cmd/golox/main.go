@@ -1,26 +1,97 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/taylorlowery/lox/golox"
 )
 
+func usage() {
+	fmt.Println("usage: golox [--backend=ast|vm] [--trace] [--no-optimize] [--dump-ast] [--seed=n] [script]")
+	fmt.Println("       golox build [--no-optimize] [-o out.loxc] script")
+	fmt.Println("       golox run [--cache-dir=dir] [--trace] [--max-steps=n] [--max-memory=bytes] [--timeout=d] [--no-optimize] [--dump-ast] [--seed=n] script")
+	fmt.Println("       golox disasm [--no-optimize] [--dump-ast] script")
+	fmt.Println("The ast backend, the default, only prints the syntax tree of each program; vm evaluates it.")
+}
+
+// optimizerFlags registers the flags that control the optimizer on a flag set.
+// The returned function gives the options they select, once the flags are parsed.
+func optimizerFlags(flags *flag.FlagSet, dump bool) func() []golox.Option {
+	noOptimize := flags.Bool("no-optimize", false, "compile the syntax tree as parsed, without folding constants or rewriting the bytecode")
+	dumpAST := new(bool)
+	if dump {
+		dumpAST = flags.Bool("dump-ast", false, "print the syntax tree the VM compiles, after optimization, to stderr")
+	}
+	return func() []golox.Option {
+		opts := []golox.Option{golox.WithOptimizer(!*noOptimize)}
+		if *dumpAST {
+			opts = append(opts, golox.WithASTDump(os.Stderr))
+		}
+		return opts
+	}
+}
+
+// seedFlag registers the flag that seeds the random numbers programs see on a flag set.
+// The returned function gives the options it selects, once the flags are parsed.
+func seedFlag(flags *flag.FlagSet) func() []golox.Option {
+	seed := flags.Uint64("seed", 0, "seed the random numbers programs see, to make runs reproducible (default: a random seed)")
+	return func() []golox.Option {
+		var opts []golox.Option
+		flags.Visit(func(f *flag.Flag) {
+			if f.Name == "seed" {
+				opts = append(opts, golox.WithSeed(*seed))
+			}
+		})
+		return opts
+	}
+}
+
 func main() {
-	if len(os.Args) > 2 {
-		fmt.Println("usage: golox [script]")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "build":
+			build(os.Args[2:])
+		case "run":
+			run(os.Args[2:])
+		case "disasm":
+			disasm(os.Args[2:])
+		}
+	}
+
+	backend := flag.String("backend", golox.BackendAST, "backend to run programs with: ast prints each program's syntax tree without evaluating it, vm evaluates it")
+	trace := flag.Bool("trace", false, "print the VM stack and each instruction before it runs to stderr (vm backend only)")
+	optimizerOpts := optimizerFlags(flag.CommandLine, true)
+	seedOpts := seedFlag(flag.CommandLine)
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() > 1 {
+		usage()
 		os.Exit(64)
 	}
-	g, err := golox.NewGolox()
+	opts := append([]golox.Option{golox.WithBackend(*backend)}, optimizerOpts()...)
+	opts = append(opts, seedOpts()...)
+	if *trace {
+		if *backend != golox.BackendVM {
+			fmt.Println("--trace requires --backend=vm")
+			os.Exit(64)
+		}
+		opts = append(opts, golox.WithTrace(os.Stderr))
+	}
+	g, err := golox.NewGolox(opts...)
 	if err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(64)
 	}
-	if len(os.Args) == 2 {
-		err, exitCode := g.RunFile(os.Args[1])
+	if flag.NArg() == 1 {
+		err, exitCode := g.RunFile(flag.Arg(0))
 		if err != nil {
 			fmt.Println(err)
+			os.Exit(exitCode)
 		}
 		if g.HadError() {
 			os.Exit(exitCode)
@@ -36,3 +107,101 @@ func main() {
 	// just to be thorough
 	os.Exit(0)
 }
+
+// disasm prints the bytecode a script compiles to.
+func disasm(args []string) {
+	flags := flag.NewFlagSet("disasm", flag.ExitOnError)
+	optimizerOpts := optimizerFlags(flags, true)
+	flags.Usage = usage
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+		os.Exit(64)
+	}
+	g, err := golox.NewGolox(optimizerOpts()...)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	err, exitCode := g.DisassembleFile(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+	}
+	os.Exit(exitCode)
+}
+
+// build compiles a script to a .loxc file.
+func build(args []string) {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	out := flags.String("o", "", "file to write the bytecode to (default: the script with a .loxc extension)")
+	optimizerOpts := optimizerFlags(flags, false)
+	flags.Usage = usage
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+		os.Exit(64)
+	}
+	g, err := golox.NewGolox(optimizerOpts()...)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	err, exitCode := g.BuildFile(flags.Arg(0), *out)
+	if err != nil {
+		fmt.Println(err)
+	}
+	os.Exit(exitCode)
+}
+
+// run runs a script or .loxc file on the VM,
+// caching the bytecode it compiles scripts to.
+func run(args []string) {
+	defaultCacheDir := ""
+	if dir, err := os.UserCacheDir(); err == nil {
+		defaultCacheDir = filepath.Join(dir, "golox")
+	}
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	cacheDir := flags.String("cache-dir", defaultCacheDir, "directory to cache compiled scripts in, or empty to disable caching")
+	trace := flags.Bool("trace", false, "print the VM stack and each instruction before it runs to stderr")
+	maxSteps := flags.Int("max-steps", 0, "stop the program after this many instructions, or 0 for no limit")
+	maxMemory := flags.Int64("max-memory", 0, "stop the program if it allocates more than this many bytes, or 0 for no limit")
+	timeout := flags.Duration("timeout", 0, "stop the program after this long, or 0 for no limit")
+	optimizerOpts := optimizerFlags(flags, true)
+	seedOpts := seedFlag(flags)
+	flags.Usage = usage
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+		os.Exit(64)
+	}
+
+	opts := append([]golox.Option{golox.WithBackend(golox.BackendVM)}, optimizerOpts()...)
+	opts = append(opts, seedOpts()...)
+	if *cacheDir != "" {
+		opts = append(opts, golox.WithCacheDir(*cacheDir))
+	}
+	if *trace {
+		opts = append(opts, golox.WithTrace(os.Stderr))
+	}
+	if *maxSteps > 0 {
+		opts = append(opts, golox.WithMaxSteps(*maxSteps))
+	}
+	if *maxMemory > 0 {
+		opts = append(opts, golox.WithMaxMemory(*maxMemory))
+	}
+	if *timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		opts = append(opts, golox.WithContext(ctx))
+	}
+	g, err := golox.NewGolox(opts...)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(64)
+	}
+	err, exitCode := g.RunFile(flags.Arg(0))
+	if err != nil {
+		fmt.Println(err)
+	}
+	os.Exit(exitCode)
+}
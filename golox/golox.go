@@ -2,34 +2,71 @@ package golox
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/taylorlowery/lox/internal/ast"
-	"github.com/taylorlowery/lox/internal/parser"
-	"github.com/taylorlowery/lox/internal/scanner"
+	"github.com/taylorlowery/lox/internal/chunk"
+	"github.com/taylorlowery/lox/internal/compiler"
+	"github.com/taylorlowery/lox/internal/host"
 	"github.com/taylorlowery/lox/internal/token"
+	"github.com/taylorlowery/lox/internal/value"
+	"github.com/taylorlowery/lox/internal/vm"
+	"github.com/taylorlowery/lox/pkg/lox"
+)
+
+// Backends a Golox instance can run programs with.
+const (
+	// BackendAST prints the parsed syntax tree of a program without evaluating it.
+	BackendAST = "ast"
+	// BackendVM compiles a program to bytecode, runs it on a virtual machine
+	// and prints the value it produces.
+	BackendVM = "vm"
 )
 
 type Golox struct {
-	stdin  io.Reader
-	stdout io.Writer
-	stderr io.Writer
-	hadErr bool
+	stdin         io.Reader
+	stdout        io.Writer
+	stderr        io.Writer
+	backend       string
+	trace         io.Writer
+	cacheDir      string
+	astDump       io.Writer
+	hadErr        bool
+	hadRuntimeErr bool
+	// limitErr is set when the VM stops a program because it hit a limit
+	limitErr error
+	// loxOpts configure cfg, which the VM backend compiles and runs programs with
+	loxOpts []lox.Option
+	cfg     *host.Config
 }
 
-type option func(*Golox) error
+// Errors returned when the VM stops a program because it hit a limit
+// set with WithContext, WithMaxSteps, WithMaxCallDepth or WithMaxMemory.
+var (
+	ErrCancelled     = lox.ErrCancelled
+	ErrStepLimit     = lox.ErrStepLimit
+	ErrStackOverflow = lox.ErrStackOverflow
+	ErrMemoryLimit   = lox.ErrMemoryLimit
+)
+
+// Option configures a Golox instance.
+type Option func(*Golox) error
 
 // NewGolox returns a new instance of NewGolox
 // with the input configured to Stdin
 // and output to Stdout
-func NewGolox(opts ...option) (*Golox, error) {
+func NewGolox(opts ...Option) (*Golox, error) {
 	g := &Golox{
-		stdin:  os.Stdin,
-		stdout: os.Stdout,
-		stderr: os.Stderr,
+		stdin:   os.Stdin,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+		backend: BackendAST,
 	}
 	for _, opt := range opts {
 		err := opt(g)
@@ -37,11 +74,16 @@ func NewGolox(opts ...option) (*Golox, error) {
 			return nil, err
 		}
 	}
+	cfg, err := host.NewConfig(g.loxOpts...)
+	if err != nil {
+		return nil, err
+	}
+	g.cfg = cfg
 	return g, nil
 }
 
 // WithOutput configures a Golox instance to use a given writer as output
-func WithOutput(w io.Writer) option {
+func WithOutput(w io.Writer) Option {
 	return func(g *Golox) error {
 		if w == nil {
 			return errors.New("nil output writer")
@@ -52,7 +94,7 @@ func WithOutput(w io.Writer) option {
 }
 
 // WithInput configures a Golox instance to use a given reader as input
-func WithInput(r io.Reader) option {
+func WithInput(r io.Reader) Option {
 	return func(g *Golox) error {
 		if r == nil {
 			return errors.New("nil input reader")
@@ -63,7 +105,7 @@ func WithInput(r io.Reader) option {
 }
 
 // WithStderr configures a given Golox instance to use a given writer for error output
-func WithStderr(w io.Writer) option {
+func WithStderr(w io.Writer) Option {
 	return func(g *Golox) error {
 		if w == nil {
 			return errors.New("nil output writer")
@@ -73,42 +115,405 @@ func WithStderr(w io.Writer) option {
 	}
 }
 
-func (g Golox) run(source string) {
-	scanner := scanner.NewScanner(source)
-	tokens, err := scanner.ScanTokens()
+// WithBackend configures a Golox instance to run programs with the named backend,
+// BackendAST or BackendVM
+func WithBackend(name string) Option {
+	return func(g *Golox) error {
+		switch name {
+		case BackendAST, BackendVM:
+			g.backend = name
+			return nil
+		default:
+			return fmt.Errorf("unknown backend %q", name)
+		}
+	}
+}
+
+// WithTrace configures a Golox instance to write a trace of the VM backend's execution
+// to a given writer: the stack and the disassembled instruction, before each instruction runs
+func WithTrace(w io.Writer) Option {
+	return func(g *Golox) error {
+		if w == nil {
+			return errors.New("nil trace writer")
+		}
+		g.trace = w
+		return nil
+	}
+}
+
+// WithCacheDir configures a Golox instance to keep the bytecode the VM backend
+// compiles scripts to in a given directory, keyed by the hash of their source,
+// so that running an unchanged script again skips parsing and compiling
+func WithCacheDir(dir string) Option {
+	return func(g *Golox) error {
+		if dir == "" {
+			return errors.New("empty cache directory")
+		}
+		g.cacheDir = dir
+		return nil
+	}
+}
+
+// WithOptimizer turns the optimizations the VM backend makes to a program on or off:
+// folding constants in its syntax tree, and rewriting its bytecode. They're on by default
+func WithOptimizer(enabled bool) Option {
+	return WithLox(lox.WithOptimizer(enabled))
+}
+
+// WithASTDump configures a Golox instance to write the syntax tree
+// the VM backend compiles, after optimization, to a given writer
+func WithASTDump(w io.Writer) Option {
+	return func(g *Golox) error {
+		if w == nil {
+			return errors.New("nil AST dump writer")
+		}
+		g.astDump = w
+		return nil
+	}
+}
+
+// WithContext configures a Golox instance to stop running a program on the VM backend
+// when a given context is done. RunFile and RunPrompt then return an error wrapping ErrCancelled
+func WithContext(ctx context.Context) Option {
+	return WithLox(lox.WithContext(ctx))
+}
+
+// WithMaxSteps configures a Golox instance to stop a program on the VM backend
+// that runs more than n instructions. RunFile and RunPrompt then return an error wrapping ErrStepLimit
+func WithMaxSteps(n int) Option {
+	return WithLox(lox.WithMaxSteps(n))
+}
+
+// WithMaxCallDepth configures a Golox instance to stop a program on the VM backend
+// whose calls nest more than n frames deep, counting the program's own.
+// RunFile and RunPrompt then return an error wrapping ErrStackOverflow.
+// Lox has no functions of its own yet, so a program's calls to natives are only ever 2 frames deep.
+// Expressions nest too, but the parser rejects those that nest too deeply to compile.
+func WithMaxCallDepth(n int) Option {
+	return WithLox(lox.WithMaxCallDepth(n))
+}
+
+// WithMaxMemory configures a Golox instance to stop a program on the VM backend
+// that allocates more than n bytes. RunFile and RunPrompt then return an error wrapping ErrMemoryLimit
+// The optimizer leaves string concatenation for the program to do,
+// so that the strings it builds count towards the limit whether or not it's on.
+// The results of natives given with WithLox count too.
+func WithMaxMemory(n int64) Option {
+	return WithLox(lox.WithMaxMemory(n))
+}
+
+// WithLox configures the programs a Golox instance runs on the VM backend
+// with options from the lox package, such as the natives lox.WithNative gives them
+func WithLox(opts ...lox.Option) Option {
+	return func(g *Golox) error {
+		g.loxOpts = append(g.loxOpts, opts...)
+		return nil
+	}
+}
+
+// WithSeed configures a Golox instance to seed the random numbers
+// the standard library gives programs, so that runs with the same seed
+// see the same numbers. Without it, the seed is itself random
+func WithSeed(seed uint64) Option {
+	return WithLox(lox.WithSeed(seed))
+}
+
+// MemoryUsage returns the number of bytes allocated by the program the instance is running,
+// or 0 if it isn't running one. It's safe to call while a program runs
+func (g *Golox) MemoryUsage() int64 {
+	return g.cfg.Memory.Used()
+}
+
+// parse scans and parses source, reporting any errors.
+// It returns false if there were errors.
+func (g *Golox) parse(source string) (ast.Expr, bool) {
+	expr, err := host.Parse(source)
+	if err != nil {
+		var syntaxErr host.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			g.Error(syntaxErr.Line, syntaxErr.Message)
+		} else {
+			g.Error(0, err.Error())
+		}
+		return nil, false
+	}
+	return expr, true
+}
+
+// compile optimizes a parsed program and lowers it to bytecode, reporting any errors.
+// It returns false if there were errors.
+func (g *Golox) compile(expr ast.Expr) (*chunk.Chunk, bool) {
+	c, err := g.lower(expr)
 	if err != nil {
-		g.Error(err.Line, err.Message)
-		return
+		var compileErr compiler.CompileError
+		if errors.As(err, &compileErr) {
+			g.Error(compileErr.Line, compileErr.Message)
+		} else {
+			g.Error(0, err.Error())
+		}
+		return nil, false
 	}
+	return c, true
+}
+
+// lower optimizes a parsed program and compiles it to bytecode.
+func (g *Golox) lower(expr ast.Expr) (*chunk.Chunk, error) {
+	expr = g.cfg.OptimizeTree(expr)
+	if g.astDump != nil {
+		astPrinter, err := ast.NewAstPrinter()
+		if err != nil {
+			panic(err)
+		}
+		fmt.Fprintln(g.astDump, astPrinter.PrintAst(expr))
+	}
+
+	return g.cfg.Compile(expr)
+}
 
-	p := parser.NewParser(tokens)
-	expr, parseErr := p.Parse()
-	if parseErr != nil {
-		g.Error(0, parseErr.Error())
+// run parses and runs source, reporting the program's errors.
+// It only returns an error if the VM couldn't be set up.
+func (g *Golox) run(source string) error {
+	expr, ok := g.parse(source)
+	if !ok {
+		return nil
 	}
 
-	astPrinter, printerError := ast.NewAstPrinter()
-	if printerError != nil {
-		panic(printerError)
+	switch g.backend {
+	case BackendVM:
+		return g.runVM(expr)
+	default:
+		astPrinter, printerError := ast.NewAstPrinter()
+		if printerError != nil {
+			panic(printerError)
+		}
+
+		fmt.Fprintln(g.stdout, astPrinter.PrintAst(expr))
 	}
+	return nil
+}
 
-	fmt.Println(astPrinter.PrintAst(expr))
+func (g *Golox) runVM(expr ast.Expr) error {
+	c, ok := g.compile(expr)
+	if !ok {
+		return nil
+	}
+	return g.execute(c)
+}
+
+// execute runs a chunk on the VM and prints the value it produces.
+// The program's errors are reported, and only an error setting up the VM is returned.
+func (g *Golox) execute(c *chunk.Chunk) error {
+	machine, err := g.newVM()
+	if err != nil {
+		return err
+	}
+	result, err := machine.Run(c)
+	if err != nil {
+		var runtimeErr vm.RuntimeError
+		switch {
+		case errors.Is(err, ErrCancelled), errors.Is(err, ErrStepLimit), errors.Is(err, ErrStackOverflow), errors.Is(err, ErrMemoryLimit):
+			// the host stopped the program, so it's the host's to report
+			g.limitErr = err
+			g.hadRuntimeErr = true
+		case errors.As(err, &runtimeErr):
+			g.reportRuntimeError(runtimeErr)
+		default:
+			g.RuntimeError(0, err.Error())
+		}
+		return nil
+	}
+
+	fmt.Fprintln(g.stdout, value.Stringify(result))
+	return nil
+}
+
+func (g *Golox) newVM() (*vm.VM, error) {
+	if g.trace != nil {
+		return g.cfg.NewVM(vm.WithTrace(g.trace))
+	}
+	return g.cfg.NewVM()
+}
+
+// DisassembleFile reads a file at a given path,
+// compiles it to bytecode and writes a listing of the bytecode to the output
+func (g *Golox) DisassembleFile(scriptPath string) (error, int) {
+	bytes, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return err, 65
+	}
+	expr, ok := g.parse(string(bytes))
+	if !ok {
+		return nil, 65
+	}
+	c, ok := g.compile(expr)
+	if !ok {
+		return nil, 65
+	}
+	chunk.Disassemble(g.stdout, c, filepath.Base(scriptPath))
+	return nil, 0
+}
+
+// BuildFile reads a file at a given path, compiles it to bytecode
+// and writes the bytecode to outPath in the .loxc format.
+// If outPath is empty, it replaces the script's extension with .loxc
+func (g *Golox) BuildFile(scriptPath string, outPath string) (error, int) {
+	source, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return err, 65
+	}
+	expr, ok := g.parse(string(source))
+	if !ok {
+		return nil, 65
+	}
+	c, ok := g.compile(expr)
+	if !ok {
+		return nil, 65
+	}
+	if outPath == "" {
+		outPath = compiledPath(scriptPath)
+	}
+	if err := writeCompiled(outPath, c, chunk.HashSource(source)); err != nil {
+		return err, 74
+	}
+	return nil, 0
+}
+
+// compiledPath returns the path BuildFile writes a script's bytecode to by default.
+func compiledPath(scriptPath string) string {
+	return strings.TrimSuffix(scriptPath, filepath.Ext(scriptPath)) + ".loxc"
+}
+
+// writeCompiled writes a chunk to a temporary file and renames it into place,
+// so that a reader never sees a partly written file.
+func writeCompiled(path string, c *chunk.Chunk, hash chunk.SourceHash) error {
+	data, err := chunk.Marshal(c, hash)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".*.loxc.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// readCompiled loads a chunk from a .loxc file
+// if it was compiled from source with the given hash.
+func readCompiled(path string, hash chunk.SourceHash) (*chunk.Chunk, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	c, sourceHash, err := chunk.Unmarshal(data)
+	if err != nil || sourceHash != hash {
+		return nil, false
+	}
+	return c, true
+}
+
+// load returns the bytecode for a script, preferring, in order,
+// an up to date .loxc file built next to it, an up to date entry in the cache,
+// and compiling the source. Stale or corrupt files are ignored,
+// and a freshly compiled script is written to the cache.
+// Scripts are always compiled when the syntax tree is being dumped.
+// The .loxc file is skipped when the optimizer is off, which it may not have been
+// at build time, and under a memory limit, since strings it folded would escape the limit.
+func (g *Golox) load(scriptPath string, source []byte) (*chunk.Chunk, bool) {
+	hash := chunk.HashSource(source)
+	useCache := g.astDump == nil
+	if g.cfg.Optimize && g.cfg.MaxMemory == 0 {
+		if c, ok := readCompiled(compiledPath(scriptPath), hash); ok && useCache {
+			return c, true
+		}
+	}
+	cachePath := ""
+	if g.cacheDir != "" {
+		// unoptimized bytecode is cached separately, so turning the optimizer off takes effect
+		name := hash.String() + ".loxc"
+		switch {
+		case !g.cfg.Optimize:
+			name = hash.String() + "-noopt.loxc"
+		case g.cfg.MaxMemory > 0:
+			// nor is bytecode optimized to run under a memory limit
+			name = hash.String() + "-limited.loxc"
+		}
+		cachePath = filepath.Join(g.cacheDir, name)
+		if c, ok := readCompiled(cachePath, hash); ok && useCache {
+			return c, true
+		}
+	}
+
+	expr, ok := g.parse(string(source))
+	if !ok {
+		return nil, false
+	}
+	c, ok := g.compile(expr)
+	if !ok {
+		return nil, false
+	}
+	if cachePath != "" {
+		// the cache only saves time, so a script still runs if it can't be written
+		if err := os.MkdirAll(g.cacheDir, 0o755); err == nil {
+			_ = writeCompiled(cachePath, c, hash)
+		}
+	}
+	return c, true
 }
 
 // RunFile reads a file at a given path,
-// parses it and executes it as Lox
-func (g Golox) RunFile(filepath string) (error, int) {
-	bytes, err := os.ReadFile(filepath)
+// parses it and executes it as Lox.
+// A .loxc file written by BuildFile is run on the VM without recompiling,
+// and the VM backend loads a script's up to date .loxc file or cache entry if there is one
+func (g *Golox) RunFile(scriptPath string) (error, int) {
+	bytes, err := os.ReadFile(scriptPath)
 	if err != nil {
 		return err, 65
 	}
-	g.run(string(bytes))
+	switch {
+	case filepath.Ext(scriptPath) == ".loxc":
+		c, _, err := chunk.Unmarshal(bytes)
+		if err != nil {
+			return err, 65
+		}
+		c.File = scriptPath
+		err = g.execute(c)
+	case g.backend == BackendVM:
+		if c, ok := g.load(scriptPath, bytes); ok {
+			c.File = scriptPath
+			err = g.execute(c)
+		}
+	default:
+		err = g.run(string(bytes))
+	}
+	if err != nil {
+		return err, 70
+	}
+	if g.limitErr != nil {
+		return g.limitErr, 70
+	}
+	if g.hadErr {
+		return nil, 65
+	}
+	if g.hadRuntimeErr {
+		return nil, 70
+	}
 	return nil, 0
 }
 
 // RunPrompt starts a loop over the Golox's input,
 // parsing its input line by line and executing it as Lox
-func (g Golox) RunPrompt() error {
+func (g *Golox) RunPrompt() error {
 	// thusly named to differentiate from my own scanner
 	bufioScanner := bufio.NewScanner(g.stdin)
 	for {
@@ -120,17 +525,23 @@ func (g Golox) RunPrompt() error {
 		if line == "" {
 			continue
 		}
-		g.run(line)
+		if err := g.run(line); err != nil {
+			return err
+		}
+		if g.limitErr != nil {
+			return g.limitErr
+		}
 		g.hadErr = false
+		g.hadRuntimeErr = false
 	}
 	return bufioScanner.Err()
 }
 
-func (g Golox) Error(line int, message string) {
+func (g *Golox) Error(line int, message string) {
 	g.report(line, "", message)
 }
 
-func (g Golox) TokenError(t token.Token, message string) {
+func (g *Golox) TokenError(t token.Token, message string) {
 	if t.TokenType == token.EOF {
 		g.report(t.Line, " at end", message)
 	} else {
@@ -138,11 +549,26 @@ func (g Golox) TokenError(t token.Token, message string) {
 	}
 }
 
-func (g Golox) report(line int, where string, message string) {
-	fmt.Fprintf(os.Stderr, "[line: %d] Error %s: %s\n", line, where, message)
+func (g *Golox) report(line int, where string, message string) {
+	fmt.Fprintf(g.stderr, "[line: %d] Error %s: %s\n", line, where, message)
 	g.hadErr = true
 }
 
-func (g Golox) HadError() bool {
-	return g.hadErr
+// RuntimeError reports an error raised while a program was running.
+func (g *Golox) RuntimeError(line int, message string) {
+	fmt.Fprintf(g.stderr, "%s\n[line %d]\n", message, line)
+	g.hadRuntimeErr = true
+}
+
+// reportRuntimeError reports an error raised by the VM with its stack trace.
+func (g *Golox) reportRuntimeError(err vm.RuntimeError) {
+	fmt.Fprintln(g.stderr, err.Message)
+	for _, frame := range err.Trace {
+		fmt.Fprintf(g.stderr, "  %s\n", frame)
+	}
+	g.hadRuntimeErr = true
+}
+
+func (g *Golox) HadError() bool {
+	return g.hadErr || g.hadRuntimeErr
 }
@@ -2,10 +2,17 @@ package golox_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/taylorlowery/lox/golox"
+	"github.com/taylorlowery/lox/pkg/lox"
 )
 
 func TestRunPrompt_ReadsInputAndPrintsIt(t *testing.T) {
@@ -71,3 +78,595 @@ func TestRunFile_ReadsFileFromPathAndOutputsIt(t *testing.T) {
 		t.Fatalf("want %q, got %q", want, got)
 	}
 }
+
+// TestRunFile_CorpusOutput runs every program in testdata/corpus
+// and compares what it prints with the .out file next to it,
+// and what it reports as an error with the .err file, if there is one.
+// It's a golden file test of the VM, the only backend that evaluates programs,
+// run both with the optimizer and without it.
+func TestRunFile_CorpusOutput(t *testing.T) {
+	t.Parallel()
+
+	programs, err := filepath.Glob("testdata/corpus/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(programs) == 0 {
+		t.Fatal("no programs in corpus")
+	}
+
+	for _, optimize := range []bool{true, false} {
+		for _, program := range programs {
+			name := strings.TrimSuffix(program, ".lox")
+			testName := "optimized/" + filepath.Base(name)
+			if !optimize {
+				testName = "unoptimized/" + filepath.Base(name)
+			}
+			t.Run(testName, func(t *testing.T) {
+				t.Parallel()
+				var output bytes.Buffer
+				var errOutput bytes.Buffer
+
+				g, err := golox.NewGolox(
+					golox.WithOutput(&output),
+					golox.WithStderr(&errOutput),
+					golox.WithBackend(golox.BackendVM),
+					golox.WithOptimizer(optimize),
+				)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				err, exitCode := g.RunFile(program)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				want, err := os.ReadFile(name + ".out")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := output.String(); got != string(want) {
+					t.Errorf("want output %q, got %q", want, got)
+				}
+
+				wantErr, err := os.ReadFile(name + ".err")
+				if err != nil && !errors.Is(err, fs.ErrNotExist) {
+					t.Fatal(err)
+				}
+				if got := errOutput.String(); got != string(wantErr) {
+					t.Errorf("want error output %q, got %q", wantErr, got)
+				}
+
+				wantExitCode := 0
+				if len(wantErr) > 0 {
+					wantExitCode = 70
+				}
+				if exitCode != wantExitCode {
+					t.Errorf("want exit code %d, got %d", wantExitCode, exitCode)
+				}
+			})
+		}
+	}
+}
+
+// TestRunFile_BackendsParseCorpusAlike checks that the tree the AST backend prints
+// for every program in testdata/corpus is the one the VM backend compiles,
+// since the AST backend doesn't evaluate programs to compare their output.
+func TestRunFile_BackendsParseCorpusAlike(t *testing.T) {
+	t.Parallel()
+
+	programs, err := filepath.Glob("testdata/corpus/*.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, program := range programs {
+		t.Run(filepath.Base(program), func(t *testing.T) {
+			t.Parallel()
+			var astOutput bytes.Buffer
+			var vmTree bytes.Buffer
+
+			astGolox, err := golox.NewGolox(
+				golox.WithOutput(&astOutput),
+				golox.WithStderr(io.Discard),
+				golox.WithBackend(golox.BackendAST),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			vmGolox, err := golox.NewGolox(
+				golox.WithOutput(io.Discard),
+				golox.WithStderr(io.Discard),
+				golox.WithBackend(golox.BackendVM),
+				golox.WithOptimizer(false),
+				golox.WithASTDump(&vmTree),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err, exitCode := astGolox.RunFile(program); err != nil || exitCode != 0 {
+				t.Fatalf("ast backend: %v, exit code %d", err, exitCode)
+			}
+			if err, _ := vmGolox.RunFile(program); err != nil {
+				t.Fatalf("vm backend: %v", err)
+			}
+			if astOutput.String() != vmTree.String() {
+				t.Errorf("ast backend printed %q, vm backend compiled %q", astOutput.String(), vmTree.String())
+			}
+		})
+	}
+}
+
+func TestNewGolox_RejectsUnknownBackend(t *testing.T) {
+	t.Parallel()
+
+	_, err := golox.NewGolox(golox.WithBackend("jit"))
+	if err == nil {
+		t.Fatal("expected an error for an unknown backend")
+	}
+}
+
+func TestDisassembleFile_WritesBytecodeListing(t *testing.T) {
+	t.Parallel()
+	var output bytes.Buffer
+	var errOutput bytes.Buffer
+
+	g, err := golox.NewGolox(
+		golox.WithOutput(&output),
+		golox.WithStderr(&errOutput),
+		golox.WithOptimizer(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err, exitCode := g.DisassembleFile("testdata/corpus/grouping.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exitCode != 0 {
+		t.Fatalf("expected 0 exit code, got %d: %s", exitCode, errOutput.String())
+	}
+
+	want := `== grouping.lox ==
+0000    1 OP_CONSTANT         0 '1'
+0002    | OP_CONSTANT         1 '2'
+0004    | OP_ADD
+0005    | OP_CONSTANT         2 '3'
+0007    | OP_CONSTANT         3 '4'
+0009    | OP_NEGATE
+0010    | OP_SUBTRACT
+0011    | OP_MULTIPLY
+0012    | OP_RETURN
+`
+	if got := output.String(); got != want {
+		t.Fatalf("want %q, got %q", want, got)
+	}
+}
+
+func TestRunFile_TracesVMExecution(t *testing.T) {
+	t.Parallel()
+	var output bytes.Buffer
+	var trace bytes.Buffer
+
+	g, err := golox.NewGolox(
+		golox.WithOutput(&output),
+		golox.WithBackend(golox.BackendVM),
+		golox.WithTrace(&trace),
+		golox.WithOptimizer(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err, _ = g.RunFile("testdata/corpus/comma.lox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "3\n" {
+		t.Fatalf("want output %q, got %q", "3\n", output.String())
+	}
+	if !strings.Contains(trace.String(), "[ 1 ]\n0002    | OP_POP\n") {
+		t.Fatalf("expected trace to show the stack before OP_POP, got:\n%s", trace.String())
+	}
+}
+
+func TestBuildFile_OutputRunsWithoutTheSource(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.lox")
+	if err := os.WriteFile(script, []byte("(1 + 2) * 3"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	g, err := golox.NewGolox(golox.WithStderr(&bytes.Buffer{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, exitCode := g.BuildFile(script, ""); err != nil || exitCode != 0 {
+		t.Fatalf("build failed with exit code %d: %v", exitCode, err)
+	}
+	if err := os.Remove(script); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	g, err = golox.NewGolox(golox.WithOutput(&output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, exitCode := g.RunFile(filepath.Join(dir, "script.loxc")); err != nil || exitCode != 0 {
+		t.Fatalf("run failed with exit code %d: %v", exitCode, err)
+	}
+	if output.String() != "9\n" {
+		t.Fatalf("want %q, got %q", "9\n", output.String())
+	}
+}
+
+func TestRunFile_IgnoresStaleBuildOutput(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.lox")
+	if err := os.WriteFile(script, []byte("1 + 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := golox.NewGolox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, _ := g.BuildFile(script, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("2 + 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var output bytes.Buffer
+	g, err = golox.NewGolox(golox.WithOutput(&output), golox.WithBackend(golox.BackendVM))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, _ := g.RunFile(script); err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "4\n" {
+		t.Fatalf("want %q, got %q", "4\n", output.String())
+	}
+}
+
+func TestRunFile_IgnoresBuildOutputUnderAMemoryLimit(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.lox")
+	if err := os.WriteFile(script, []byte("\"a\" + \"b\""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := golox.NewGolox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, _ := g.BuildFile(script, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	// the build folded the concatenation, which would escape the limit
+	g, err = golox.NewGolox(
+		golox.WithOutput(&bytes.Buffer{}),
+		golox.WithBackend(golox.BackendVM),
+		golox.WithMaxMemory(1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, _ := g.RunFile(script); !errors.Is(err, golox.ErrMemoryLimit) {
+		t.Fatalf("want %v, got %v", golox.ErrMemoryLimit, err)
+	}
+}
+
+func TestRunFile_IgnoresBuildOutputWithTheOptimizerOff(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.lox")
+	if err := os.WriteFile(script, []byte("1 + 2"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := golox.NewGolox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, _ := g.BuildFile(script, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	var trace bytes.Buffer
+	g, err = golox.NewGolox(
+		golox.WithOutput(&bytes.Buffer{}),
+		golox.WithBackend(golox.BackendVM),
+		golox.WithTrace(&trace),
+		golox.WithOptimizer(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err, _ := g.RunFile(script); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(trace.String(), "OP_ADD") {
+		t.Fatalf("expected the unoptimized addition to run, got:\n%s", trace.String())
+	}
+}
+
+func TestRunFile_CachesCompiledScripts(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	script := filepath.Join(dir, "script.lox")
+	if err := os.WriteFile(script, []byte("\"a\" + \"b\""), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	runScript := func() string {
+		t.Helper()
+		var output bytes.Buffer
+		g, err := golox.NewGolox(
+			golox.WithOutput(&output),
+			golox.WithBackend(golox.BackendVM),
+			golox.WithCacheDir(cacheDir),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err, exitCode := g.RunFile(script); err != nil || exitCode != 0 {
+			t.Fatalf("run failed with exit code %d: %v", exitCode, err)
+		}
+		return output.String()
+	}
+
+	if got := runScript(); got != "ab\n" {
+		t.Fatalf("want %q, got %q", "ab\n", got)
+	}
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*.loxc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 cache entry, got %v", entries)
+	}
+
+	// a corrupt entry is recompiled and replaced
+	if err := os.WriteFile(entries[0], []byte("LOXC garbage"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got := runScript(); got != "ab\n" {
+		t.Fatalf("want %q, got %q", "ab\n", got)
+	}
+	data, err := os.ReadFile(entries[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) == "LOXC garbage" {
+		t.Fatal("expected the corrupt cache entry to be replaced")
+	}
+}
+
+func TestRunFile_RejectsCorruptBuildOutput(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "script.loxc")
+	if err := os.WriteFile(path, []byte("not bytecode"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := golox.NewGolox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err, exitCode := g.RunFile(path)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if exitCode != 65 {
+		t.Fatalf("expected 65 exit code, got %d", exitCode)
+	}
+}
+
+func TestDisassembleFile_OptimizerCanBeTurnedOff(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		optimize bool
+		wantAST  string
+		wantCode string
+	}{
+		{
+			name:     "optimized",
+			optimize: true,
+			wantAST:  "21\n",
+			wantCode: "0000    1 OP_CONSTANT         0 '21'\n",
+		},
+		{
+			name:     "unoptimized",
+			optimize: false,
+			wantAST:  "(* (group (+ 1 2)) (group (- 3 (- 4))))\n",
+			wantCode: "0000    1 OP_CONSTANT         0 '1'\n",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var output bytes.Buffer
+			var dump bytes.Buffer
+
+			g, err := golox.NewGolox(
+				golox.WithOutput(&output),
+				golox.WithOptimizer(tt.optimize),
+				golox.WithASTDump(&dump),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err, exitCode := g.DisassembleFile("testdata/corpus/grouping.lox"); err != nil || exitCode != 0 {
+				t.Fatalf("disassembly failed with exit code %d: %v", exitCode, err)
+			}
+
+			if dump.String() != tt.wantAST {
+				t.Errorf("want tree %q, got %q", tt.wantAST, dump.String())
+			}
+			if !strings.Contains(output.String(), tt.wantCode) {
+				t.Errorf("want code starting with %q, got:\n%s", tt.wantCode, output.String())
+			}
+		})
+	}
+}
+
+func TestRunFile_StopsAtLimits(t *testing.T) {
+	t.Parallel()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name string
+		opt  golox.Option
+		want error
+	}{
+		{
+			name: "step limit",
+			opt:  golox.WithMaxSteps(2),
+			want: golox.ErrStepLimit,
+		},
+		{
+			name: "cancelled context",
+			opt:  golox.WithContext(cancelled),
+			want: golox.ErrCancelled,
+		},
+		{
+			name: "memory limit",
+			opt:  golox.WithMaxMemory(1),
+			want: golox.ErrMemoryLimit,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			var output bytes.Buffer
+			var errOutput bytes.Buffer
+
+			g, err := golox.NewGolox(
+				golox.WithOutput(&output),
+				golox.WithStderr(&errOutput),
+				golox.WithBackend(golox.BackendVM),
+				golox.WithOptimizer(false),
+				tt.opt,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err, exitCode := g.RunFile("testdata/corpus/concatenation.lox")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("want %v, got %v", tt.want, err)
+			}
+			if exitCode != 70 {
+				t.Errorf("want exit code 70, got %d", exitCode)
+			}
+			if output.String() != "" || errOutput.String() != "" {
+				t.Errorf("expected no output, got %q and %q", output.String(), errOutput.String())
+			}
+			if usage := g.MemoryUsage(); usage != 0 {
+				t.Errorf("expected no memory in use after the run, got %d", usage)
+			}
+		})
+	}
+}
+
+func TestRunFile_StopsAtCallDepth(t *testing.T) {
+	t.Parallel()
+
+	// the program and clock make 2 frames
+	for depth, want := range map[int]error{1: golox.ErrStackOverflow, 2: nil} {
+		g, err := golox.NewGolox(
+			golox.WithOutput(&bytes.Buffer{}),
+			golox.WithBackend(golox.BackendVM),
+			golox.WithMaxCallDepth(depth),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err, _ := g.RunFile("testdata/corpus/clock.lox"); !errors.Is(err, want) {
+			t.Errorf("depth %d: want %v, got %v", depth, want, err)
+		}
+	}
+}
+
+func TestRunFile_RejectsDeeplyNestedExpressions(t *testing.T) {
+	t.Parallel()
+
+	sources := map[string]string{
+		"parentheses": strings.Repeat("(", 100000) + "1" + strings.Repeat(")", 100000),
+		"negations":   strings.Repeat("-", 100000) + "1",
+		"additions":   strings.Repeat("1 + ", 100000) + "1",
+	}
+
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			script := filepath.Join(t.TempDir(), "script.lox")
+			if err := os.WriteFile(script, []byte(source), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var errOutput bytes.Buffer
+			g, err := golox.NewGolox(
+				golox.WithOutput(&bytes.Buffer{}),
+				golox.WithStderr(&errOutput),
+				golox.WithBackend(golox.BackendVM),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err, exitCode := g.RunFile(script); err != nil || exitCode != 65 {
+				t.Fatalf("want exit code 65, got %d: %v", exitCode, err)
+			}
+			if !strings.Contains(errOutput.String(), "Expression nesting too deep.") {
+				t.Errorf("expected a nesting error, got %q", errOutput.String())
+			}
+
+			in, err := lox.NewInterpreter()
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = in.Eval(context.Background(), source)
+			var syntaxErr lox.SyntaxError
+			if !errors.As(err, &syntaxErr) || !strings.Contains(syntaxErr.Message, "Expression nesting too deep.") {
+				t.Errorf("expected a nesting SyntaxError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRunPrompt_StopsAtLimits(t *testing.T) {
+	t.Parallel()
+	var output bytes.Buffer
+
+	g, err := golox.NewGolox(
+		golox.WithInput(strings.NewReader("1\n1 + 2\n3\n")),
+		golox.WithOutput(&output),
+		golox.WithBackend(golox.BackendVM),
+		golox.WithOptimizer(false),
+		golox.WithMaxSteps(2),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = g.RunPrompt()
+	if !errors.Is(err, golox.ErrStepLimit) {
+		t.Fatalf("want %v, got %v", golox.ErrStepLimit, err)
+	}
+	if want := "> 1\n> "; output.String() != want {
+		t.Fatalf("want %q, got %q", want, output.String())
+	}
+}
@@ -0,0 +1,395 @@
+package ast
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+
+	"github.com/taylorlowery/lox/internal/token"
+)
+
+type compareConfig struct {
+	ignorePositions bool
+}
+
+// CompareOption changes how Equal, Diff and Hash compare trees.
+type CompareOption func(c *compareConfig)
+
+// IgnorePositions makes Equal, Hash and Diff disregard source positions,
+// i.e. the line numbers carried by tokens.
+func IgnorePositions() CompareOption {
+	return func(c *compareConfig) {
+		c.ignorePositions = true
+	}
+}
+
+func newCompareConfig(opts []CompareOption) compareConfig {
+	var c compareConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// Equal reports whether two expression trees are structurally equal:
+// the same node types in the same shape, with equal tokens and literal values.
+// Unlike reflect.DeepEqual, a NaN literal equals another NaN literal.
+func Equal(a, b Expr, opts ...CompareOption) bool {
+	return Diff(a, b, opts...) == ""
+}
+
+// Diff returns a description of the first difference between two expression trees,
+// including the path to the differing node, or "" if they are equal.
+// Paths start at "expr" and name the fields followed to reach the node,
+// e.g. "expr.Left.Right.Value".
+func Diff(want, got Expr, opts ...CompareOption) string {
+	c := newCompareConfig(opts)
+	return c.diffExpr("expr", want, got)
+}
+
+func (c compareConfig) diffExpr(path string, want, got Expr) string {
+	if want == nil || got == nil {
+		if want == nil && got == nil {
+			return ""
+		}
+		return fmt.Sprintf("%s: want %s, got %s", path, describe(want), describe(got))
+	}
+
+	switch w := want.(type) {
+	case *BadExpr:
+		g, ok := got.(*BadExpr)
+		if !ok {
+			break
+		}
+		if len(w.Tokens) != len(g.Tokens) {
+			return fmt.Sprintf("%s.Tokens: want %d tokens, got %d", path, len(w.Tokens), len(g.Tokens))
+		}
+		for i := range w.Tokens {
+			if d := c.diffToken(fmt.Sprintf("%s.Tokens[%d]", path, i), w.Tokens[i], g.Tokens[i]); d != "" {
+				return d
+			}
+		}
+		return ""
+	case *Binary:
+		g, ok := got.(*Binary)
+		if !ok {
+			break
+		}
+		if d := c.diffExpr(path+".Left", w.Left, g.Left); d != "" {
+			return d
+		}
+		if d := c.diffToken(path+".Operator", w.Operator, g.Operator); d != "" {
+			return d
+		}
+		return c.diffExpr(path+".Right", w.Right, g.Right)
+	case *Call:
+		g, ok := got.(*Call)
+		if !ok {
+			break
+		}
+		if d := c.diffExpr(path+".Callee", w.Callee, g.Callee); d != "" {
+			return d
+		}
+		if d := c.diffToken(path+".Paren", w.Paren, g.Paren); d != "" {
+			return d
+		}
+		return c.diffExprs(path+".Arguments", w.Arguments, g.Arguments)
+	case *Conditional:
+		g, ok := got.(*Conditional)
+		if !ok {
+			break
+		}
+		if d := c.diffExpr(path+".Condition", w.Condition, g.Condition); d != "" {
+			return d
+		}
+		if d := c.diffExpr(path+".ThenBranch", w.ThenBranch, g.ThenBranch); d != "" {
+			return d
+		}
+		return c.diffExpr(path+".ElseBranch", w.ElseBranch, g.ElseBranch)
+	case *Grouping:
+		g, ok := got.(*Grouping)
+		if !ok {
+			break
+		}
+		return c.diffExpr(path+".Expression", w.Expression, g.Expression)
+	case *Index:
+		g, ok := got.(*Index)
+		if !ok {
+			break
+		}
+		if d := c.diffExpr(path+".Object", w.Object, g.Object); d != "" {
+			return d
+		}
+		if d := c.diffToken(path+".Bracket", w.Bracket, g.Bracket); d != "" {
+			return d
+		}
+		return c.diffExpr(path+".Index", w.Index, g.Index)
+	case *ListLiteral:
+		g, ok := got.(*ListLiteral)
+		if !ok {
+			break
+		}
+		if d := c.diffToken(path+".Bracket", w.Bracket, g.Bracket); d != "" {
+			return d
+		}
+		return c.diffExprs(path+".Elements", w.Elements, g.Elements)
+	case *Literal:
+		g, ok := got.(*Literal)
+		if !ok {
+			break
+		}
+		if !literalEqual(w.Value, g.Value) {
+			return fmt.Sprintf("%s.Value: want %#v, got %#v", path, w.Value, g.Value)
+		}
+		return ""
+	case *MapLiteral:
+		g, ok := got.(*MapLiteral)
+		if !ok {
+			break
+		}
+		if d := c.diffToken(path+".Brace", w.Brace, g.Brace); d != "" {
+			return d
+		}
+		if d := c.diffExprs(path+".Keys", w.Keys, g.Keys); d != "" {
+			return d
+		}
+		return c.diffExprs(path+".Values", w.Values, g.Values)
+	case *Property:
+		g, ok := got.(*Property)
+		if !ok {
+			break
+		}
+		if d := c.diffExpr(path+".Object", w.Object, g.Object); d != "" {
+			return d
+		}
+		return c.diffToken(path+".Name", w.Name, g.Name)
+	case *SetIndex:
+		g, ok := got.(*SetIndex)
+		if !ok {
+			break
+		}
+		if d := c.diffExpr(path+".Object", w.Object, g.Object); d != "" {
+			return d
+		}
+		if d := c.diffToken(path+".Bracket", w.Bracket, g.Bracket); d != "" {
+			return d
+		}
+		if d := c.diffExpr(path+".Index", w.Index, g.Index); d != "" {
+			return d
+		}
+		return c.diffExpr(path+".Value", w.Value, g.Value)
+	case *SetProperty:
+		g, ok := got.(*SetProperty)
+		if !ok {
+			break
+		}
+		if d := c.diffExpr(path+".Object", w.Object, g.Object); d != "" {
+			return d
+		}
+		if d := c.diffToken(path+".Name", w.Name, g.Name); d != "" {
+			return d
+		}
+		return c.diffExpr(path+".Value", w.Value, g.Value)
+	case *Unary:
+		g, ok := got.(*Unary)
+		if !ok {
+			break
+		}
+		if d := c.diffToken(path+".Operator", w.Operator, g.Operator); d != "" {
+			return d
+		}
+		return c.diffExpr(path+".Right", w.Right, g.Right)
+	case *Variable:
+		g, ok := got.(*Variable)
+		if !ok {
+			break
+		}
+		return c.diffToken(path+".Name", w.Name, g.Name)
+	default:
+		return fmt.Sprintf("%s: unsupported node %T", path, want)
+	}
+	return fmt.Sprintf("%s: want %s, got %s", path, describe(want), describe(got))
+}
+
+func (c compareConfig) diffExprs(path string, want, got []Expr) string {
+	if len(want) != len(got) {
+		return fmt.Sprintf("%s: want %d expressions, got %d", path, len(want), len(got))
+	}
+	for i := range want {
+		if d := c.diffExpr(fmt.Sprintf("%s[%d]", path, i), want[i], got[i]); d != "" {
+			return d
+		}
+	}
+	return ""
+}
+
+func (c compareConfig) diffToken(path string, want, got token.Token) string {
+	switch {
+	case want.TokenType != got.TokenType:
+		return fmt.Sprintf("%s.TokenType: want %s, got %s", path, want.TokenType, got.TokenType)
+	case want.Lexeme != got.Lexeme:
+		return fmt.Sprintf("%s.Lexeme: want %q, got %q", path, want.Lexeme, got.Lexeme)
+	case !literalEqual(want.Literal, got.Literal):
+		return fmt.Sprintf("%s.Literal: want %#v, got %#v", path, want.Literal, got.Literal)
+	case !c.ignorePositions && want.Line != got.Line:
+		return fmt.Sprintf("%s.Line: want %d, got %d", path, want.Line, got.Line)
+	}
+	return ""
+}
+
+// describe names a node for Diff output, printing small nodes in full.
+func describe(e Expr) string {
+	switch e := e.(type) {
+	case nil:
+		return "nil"
+	case *Literal:
+		return fmt.Sprintf("%T(%#v)", e, e.Value)
+	default:
+		return fmt.Sprintf("%T", e)
+	}
+}
+
+// literalEqual compares literal values,
+// treating two NaNs as equal and 0 and -0 as different
+// so that equal literals always evaluate the same way.
+func literalEqual(a, b any) bool {
+	af, aok := a.(float64)
+	bf, bok := b.(float64)
+	if aok && bok {
+		if math.IsNaN(af) && math.IsNaN(bf) {
+			return true
+		}
+		return math.Float64bits(af) == math.Float64bits(bf)
+	}
+	return a == b
+}
+
+// Hash returns a hash of an expression tree's structure,
+// suitable for caching and deduplicating subtrees.
+// Trees that are Equal with the same options have the same hash.
+func Hash(e Expr, opts ...CompareOption) uint64 {
+	c := newCompareConfig(opts)
+	h := fnv.New64a()
+	c.hashExpr(h, e)
+	return h.Sum64()
+}
+
+func (c compareConfig) hashExpr(h hash.Hash64, e Expr) {
+	switch e := e.(type) {
+	case nil:
+		h.Write([]byte("nil;"))
+	case *BadExpr:
+		h.Write([]byte("BadExpr("))
+		for _, t := range e.Tokens {
+			c.hashToken(h, t)
+		}
+		h.Write([]byte(");"))
+	case *Binary:
+		h.Write([]byte("Binary("))
+		c.hashExpr(h, e.Left)
+		c.hashToken(h, e.Operator)
+		c.hashExpr(h, e.Right)
+		h.Write([]byte(");"))
+	case *Call:
+		h.Write([]byte("Call("))
+		c.hashExpr(h, e.Callee)
+		c.hashToken(h, e.Paren)
+		for _, arg := range e.Arguments {
+			c.hashExpr(h, arg)
+		}
+		h.Write([]byte(");"))
+	case *Conditional:
+		h.Write([]byte("Conditional("))
+		c.hashExpr(h, e.Condition)
+		c.hashExpr(h, e.ThenBranch)
+		c.hashExpr(h, e.ElseBranch)
+		h.Write([]byte(");"))
+	case *Grouping:
+		h.Write([]byte("Grouping("))
+		c.hashExpr(h, e.Expression)
+		h.Write([]byte(");"))
+	case *Index:
+		h.Write([]byte("Index("))
+		c.hashExpr(h, e.Object)
+		c.hashToken(h, e.Bracket)
+		c.hashExpr(h, e.Index)
+		h.Write([]byte(");"))
+	case *ListLiteral:
+		h.Write([]byte("ListLiteral("))
+		c.hashToken(h, e.Bracket)
+		for _, element := range e.Elements {
+			c.hashExpr(h, element)
+		}
+		h.Write([]byte(");"))
+	case *Literal:
+		h.Write([]byte("Literal("))
+		hashLiteral(h, e.Value)
+		h.Write([]byte(");"))
+	case *MapLiteral:
+		h.Write([]byte("MapLiteral("))
+		c.hashToken(h, e.Brace)
+		// a tree decoded from JSON can have more keys than values, or fewer
+		fmt.Fprintf(h, "%d,%d;", len(e.Keys), len(e.Values))
+		for _, key := range e.Keys {
+			c.hashExpr(h, key)
+		}
+		for _, v := range e.Values {
+			c.hashExpr(h, v)
+		}
+		h.Write([]byte(");"))
+	case *Property:
+		h.Write([]byte("Property("))
+		c.hashExpr(h, e.Object)
+		c.hashToken(h, e.Name)
+		h.Write([]byte(");"))
+	case *SetIndex:
+		h.Write([]byte("SetIndex("))
+		c.hashExpr(h, e.Object)
+		c.hashToken(h, e.Bracket)
+		c.hashExpr(h, e.Index)
+		c.hashExpr(h, e.Value)
+		h.Write([]byte(");"))
+	case *SetProperty:
+		h.Write([]byte("SetProperty("))
+		c.hashExpr(h, e.Object)
+		c.hashToken(h, e.Name)
+		c.hashExpr(h, e.Value)
+		h.Write([]byte(");"))
+	case *Unary:
+		h.Write([]byte("Unary("))
+		c.hashToken(h, e.Operator)
+		c.hashExpr(h, e.Right)
+		h.Write([]byte(");"))
+	case *Variable:
+		h.Write([]byte("Variable("))
+		c.hashToken(h, e.Name)
+		h.Write([]byte(");"))
+	default:
+		fmt.Fprintf(h, "%T;", e)
+	}
+}
+
+func (c compareConfig) hashToken(h hash.Hash64, t token.Token) {
+	fmt.Fprintf(h, "Token(%d,%q,", t.TokenType, t.Lexeme)
+	hashLiteral(h, t.Literal)
+	if !c.ignorePositions {
+		fmt.Fprintf(h, ",%d", t.Line)
+	}
+	h.Write([]byte(");"))
+}
+
+func hashLiteral(h hash.Hash64, v any) {
+	switch v := v.(type) {
+	case float64:
+		bits := math.Float64bits(v)
+		if math.IsNaN(v) {
+			bits = math.Float64bits(math.NaN())
+		}
+		h.Write([]byte("f"))
+		binary.Write(h, binary.LittleEndian, bits)
+	default:
+		fmt.Fprintf(h, "%T:%#v", v, v)
+	}
+}
@@ -0,0 +1,174 @@
+package ast
+
+import (
+	"math"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/token"
+)
+
+func negate(line int, right Expr) *Unary {
+	return &Unary{
+		Operator: token.Token{TokenType: token.MINUS, Lexeme: "-", Line: line},
+		Right:    right,
+	}
+}
+
+func multiply(line int, left, right Expr) *Binary {
+	return &Binary{
+		Left:     left,
+		Operator: token.Token{TokenType: token.STAR, Lexeme: "*", Line: line},
+		Right:    right,
+	}
+}
+
+func TestEqual(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		a    Expr
+		b    Expr
+		opts []CompareOption
+		want bool
+	}{
+		{
+			name: "identical trees",
+			a:    multiply(1, negate(1, &Literal{Value: 1.0}), &Grouping{Expression: &Literal{Value: "a"}}),
+			b:    multiply(1, negate(1, &Literal{Value: 1.0}), &Grouping{Expression: &Literal{Value: "a"}}),
+			want: true,
+		},
+		{
+			name: "different literal",
+			a:    negate(1, &Literal{Value: 1.0}),
+			b:    negate(1, &Literal{Value: 2.0}),
+			want: false,
+		},
+		{
+			name: "different node type",
+			a:    &Grouping{Expression: &Literal{Value: 1.0}},
+			b:    negate(1, &Literal{Value: 1.0}),
+			want: false,
+		},
+		{
+			name: "different lines",
+			a:    negate(1, &Literal{Value: 1.0}),
+			b:    negate(7, &Literal{Value: 1.0}),
+			want: false,
+		},
+		{
+			name: "different lines ignoring positions",
+			a:    negate(1, &Literal{Value: 1.0}),
+			b:    negate(7, &Literal{Value: 1.0}),
+			opts: []CompareOption{IgnorePositions()},
+			want: true,
+		},
+		{
+			name: "NaN literals",
+			a:    &Literal{Value: math.NaN()},
+			b:    &Literal{Value: math.NaN()},
+			want: true,
+		},
+		{
+			name: "zero and negative zero",
+			a:    &Literal{Value: 0.0},
+			b:    &Literal{Value: math.Copysign(0, -1)},
+			want: false,
+		},
+		{
+			name: "both nil",
+			want: true,
+		},
+		{
+			name: "one nil",
+			a:    &Literal{Value: nil},
+			want: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Equal(tc.a, tc.b, tc.opts...)
+			if got != tc.want {
+				t.Fatalf("want Equal %t, got %t (diff: %q)", tc.want, got, Diff(tc.a, tc.b, tc.opts...))
+			}
+			if tc.want && Hash(tc.a, tc.opts...) != Hash(tc.b, tc.opts...) {
+				t.Fatal("equal trees have different hashes")
+			}
+		})
+	}
+}
+
+func TestHash_DistinguishesTrees(t *testing.T) {
+	t.Parallel()
+
+	a := multiply(1, &Literal{Value: 2.0}, &Literal{Value: 3.0})
+	b := multiply(1, &Literal{Value: 3.0}, &Literal{Value: 2.0})
+	if Hash(a) == Hash(b) {
+		t.Fatal("expected operand order to change the hash")
+	}
+
+	c := &Literal{Value: "1"}
+	d := &Literal{Value: 1.0}
+	if Hash(c) == Hash(d) {
+		t.Fatal("expected literal type to change the hash")
+	}
+
+	// keys and values are hashed separately, so a malformed map doesn't panic
+	e := &MapLiteral{Keys: []Expr{c, d}, Values: []Expr{c}}
+	f := &MapLiteral{Keys: []Expr{c}, Values: []Expr{d, c}}
+	if Hash(e) == Hash(f) {
+		t.Fatal("expected the number of keys and values to change the hash")
+	}
+}
+
+func TestDiff_ReportsPathToFirstDifference(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		want Expr
+		got  Expr
+		diff string
+	}{
+		{
+			name: "equal",
+			want: negate(1, &Literal{Value: 1.0}),
+			got:  negate(1, &Literal{Value: 1.0}),
+			diff: "",
+		},
+		{
+			name: "nested literal",
+			want: multiply(1, &Literal{Value: 1.0}, negate(1, &Literal{Value: 2.0})),
+			got:  multiply(1, &Literal{Value: 1.0}, negate(1, &Literal{Value: 3.0})),
+			diff: "expr.Right.Right.Value: want 2, got 3",
+		},
+		{
+			name: "node type",
+			want: &Grouping{Expression: &Literal{Value: true}},
+			got:  &Grouping{Expression: negate(1, &Literal{Value: true})},
+			diff: "expr.Expression: want *ast.Literal(true), got *ast.Unary",
+		},
+		{
+			name: "operator line",
+			want: negate(1, &Literal{Value: 1.0}),
+			got:  negate(2, &Literal{Value: 1.0}),
+			diff: "expr.Operator.Line: want 1, got 2",
+		},
+		{
+			name: "missing node",
+			want: &Grouping{Expression: &Literal{Value: 1.0}},
+			got:  &Grouping{},
+			diff: "expr.Expression: want *ast.Literal(1), got nil",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := Diff(tc.want, tc.got)
+			if got != tc.diff {
+				t.Fatalf("want diff %q, got %q", tc.diff, got)
+			}
+		})
+	}
+}
@@ -3,16 +3,36 @@ package ast
 import "github.com/taylorlowery/lox/internal/token"
 
 type Visitor[K any] interface {
+	visitBadExpr(b *BadExpr) K
 	visitBinaryExpr(b *Binary) K
+	visitCallExpr(c *Call) K
+	visitConditionalExpr(c *Conditional) K
 	visitGroupingExpr(g *Grouping) K
+	visitIndexExpr(i *Index) K
+	visitListLiteralExpr(l *ListLiteral) K
 	visitLiteralExpr(l *Literal) K
+	visitMapLiteralExpr(m *MapLiteral) K
+	visitPropertyExpr(p *Property) K
+	visitSetIndexExpr(s *SetIndex) K
+	visitSetPropertyExpr(s *SetProperty) K
 	visitUnaryExpr(u *Unary) K
+	visitVariableExpr(v *Variable) K
 }
 
 type Expr interface {
 	accept(v Visitor[any]) any
 }
 
+// BadExpr stands in for an expression that failed to parse,
+// holding the tokens the parser skipped over to recover.
+type BadExpr struct {
+	Tokens []token.Token
+}
+
+func (b *BadExpr) accept(v Visitor[any]) any {
+	return v.visitBadExpr(b)
+}
+
 type Binary struct {
 	Left     Expr
 	Operator token.Token
@@ -23,6 +43,26 @@ func (b *Binary) accept(v Visitor[any]) any {
 	return v.visitBinaryExpr(b)
 }
 
+type Call struct {
+	Callee    Expr
+	Paren     token.Token
+	Arguments []Expr
+}
+
+func (c *Call) accept(v Visitor[any]) any {
+	return v.visitCallExpr(c)
+}
+
+type Conditional struct {
+	Condition  Expr
+	ThenBranch Expr
+	ElseBranch Expr
+}
+
+func (c *Conditional) accept(v Visitor[any]) any {
+	return v.visitConditionalExpr(c)
+}
+
 type Grouping struct {
 	Expression Expr
 }
@@ -31,6 +71,25 @@ func (g *Grouping) accept(v Visitor[any]) any {
 	return v.visitGroupingExpr(g)
 }
 
+type Index struct {
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+}
+
+func (i *Index) accept(v Visitor[any]) any {
+	return v.visitIndexExpr(i)
+}
+
+type ListLiteral struct {
+	Bracket  token.Token
+	Elements []Expr
+}
+
+func (l *ListLiteral) accept(v Visitor[any]) any {
+	return v.visitListLiteralExpr(l)
+}
+
 type Literal struct {
 	Value any
 }
@@ -39,6 +98,46 @@ func (l *Literal) accept(v Visitor[any]) any {
 	return v.visitLiteralExpr(l)
 }
 
+type MapLiteral struct {
+	Brace  token.Token
+	Keys   []Expr
+	Values []Expr
+}
+
+func (m *MapLiteral) accept(v Visitor[any]) any {
+	return v.visitMapLiteralExpr(m)
+}
+
+type Property struct {
+	Object Expr
+	Name   token.Token
+}
+
+func (p *Property) accept(v Visitor[any]) any {
+	return v.visitPropertyExpr(p)
+}
+
+type SetIndex struct {
+	Object  Expr
+	Bracket token.Token
+	Index   Expr
+	Value   Expr
+}
+
+func (s *SetIndex) accept(v Visitor[any]) any {
+	return v.visitSetIndexExpr(s)
+}
+
+type SetProperty struct {
+	Object Expr
+	Name   token.Token
+	Value  Expr
+}
+
+func (s *SetProperty) accept(v Visitor[any]) any {
+	return v.visitSetPropertyExpr(s)
+}
+
 type Unary struct {
 	Operator token.Token
 	Right    Expr
@@ -47,3 +146,11 @@ type Unary struct {
 func (u *Unary) accept(v Visitor[any]) any {
 	return v.visitUnaryExpr(u)
 }
+
+type Variable struct {
+	Name token.Token
+}
+
+func (v *Variable) accept(visitor Visitor[any]) any {
+	return visitor.visitVariableExpr(v)
+}
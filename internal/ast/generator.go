@@ -16,7 +16,22 @@ func defineType(w io.Writer, structName string, baseName string, fieldList strin
 		fmt.Fprintf(w, "\t%s\n", field)
 	}
 	fmt.Fprintf(w, "}\n\n")
-	fmt.Fprintf(w, "func (%c *%s) accept(v Visitor[any]) any {\n\treturn v.visit%s%s(%c)\n}\n", strings.ToLower(structName)[0], structName, structName, baseName, strings.ToLower(structName)[0])
+	receiver := strings.ToLower(structName)[0]
+	// the visitor can't share the receiver's name
+	visitor := "v"
+	if receiver == 'v' {
+		visitor = "visitor"
+	}
+	fmt.Fprintf(w, "func (%c *%s) accept(%s Visitor[any]) any {\n\treturn %s.%s(%c)\n}\n", receiver, structName, visitor, visitor, visitMethod(structName, baseName), receiver)
+}
+
+// visitMethod names the visitor method for a struct, e.g. visitBinaryExpr.
+// A struct named for the base, such as BadExpr, doesn't repeat it: visitBadExpr.
+func visitMethod(structName string, baseName string) string {
+	if strings.HasSuffix(structName, baseName) {
+		return "visit" + structName
+	}
+	return "visit" + structName + baseName
 }
 
 func defineAst(w io.Writer, packageName string, baseName string, typeDefs []string) {
@@ -43,7 +58,7 @@ func defineVisitor(w io.Writer, baseName string, typeDefs []string) {
 
 	for _, typeDef := range typeDefs {
 		typeName := strings.TrimSpace(strings.Split(typeDef, ":")[0])
-		fmt.Fprintf(w, "\tvisit%s%s(%c *%s) K\n", typeName, baseName, strings.ToLower(typeName)[0], typeName)
+		fmt.Fprintf(w, "\t%s(%c *%s) K\n", visitMethod(typeName, baseName), strings.ToLower(typeName)[0], typeName)
 	}
 
 	fmt.Fprintf(w, "}\n\n")
@@ -118,6 +118,7 @@ func TestDefineVisitor(t *testing.T) {
 	var output bytes.Buffer
 
 	typeDefs := []string{
+		"BadExpr  : tokens []token.Token",
 		"Binary   : left Expr, operator token.Token, right Expr",
 		"Grouping : expression Expr",
 		"Literal  : value any",
@@ -127,6 +128,7 @@ func TestDefineVisitor(t *testing.T) {
 	defineVisitor(&output, "Expr", typeDefs)
 
 	want := `type Visitor[K any] interface {
+	visitBadExpr(b *BadExpr) K
 	visitBinaryExpr(b *Binary) K
 	visitGroupingExpr(g *Grouping) K
 	visitLiteralExpr(l *Literal) K
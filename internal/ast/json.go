@@ -0,0 +1,674 @@
+package ast
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/taylorlowery/lox/internal/token"
+)
+
+// SchemaVersion is the version of the JSON encoding written by Marshal.
+// Bump it whenever a node or field is added, renamed or removed,
+// so that trees stored by an older golox are detected as stale.
+const SchemaVersion = 7
+
+// ErrSchemaVersion is returned by Unmarshal when the stored tree
+// was written with a different SchemaVersion.
+var ErrSchemaVersion = errors.New("ast: unsupported schema version")
+
+type document struct {
+	Version int             `json:"version"`
+	Expr    json.RawMessage `json:"expr"`
+}
+
+// Marshal encodes an expression tree as versioned JSON.
+// Every node is written as an object with a "type" discriminator
+// naming its struct, e.g. {"type": "Literal", "value": 1}.
+func Marshal(e Expr) ([]byte, error) {
+	expr, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(document{
+		Version: SchemaVersion,
+		Expr:    expr,
+	})
+}
+
+// Unmarshal decodes an expression tree written by Marshal.
+// It returns an error wrapping ErrSchemaVersion
+// if the data was written with a different schema version.
+func Unmarshal(data []byte) (Expr, error) {
+	var doc document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc.Version != SchemaVersion {
+		return nil, fmt.Errorf("%w: got %d, want %d", ErrSchemaVersion, doc.Version, SchemaVersion)
+	}
+	return unmarshalExpr(doc.Expr)
+}
+
+// unmarshalExpr decodes a single node, using its "type" field
+// to pick the concrete struct. JSON null decodes to a nil Expr.
+func unmarshalExpr(data json.RawMessage) (Expr, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+	var header struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	var e Expr
+	switch header.Type {
+	case "BadExpr":
+		e = &BadExpr{}
+	case "Binary":
+		e = &Binary{}
+	case "Call":
+		e = &Call{}
+	case "Conditional":
+		e = &Conditional{}
+	case "Grouping":
+		e = &Grouping{}
+	case "Index":
+		e = &Index{}
+	case "ListLiteral":
+		e = &ListLiteral{}
+	case "Literal":
+		e = &Literal{}
+	case "MapLiteral":
+		e = &MapLiteral{}
+	case "Property":
+		e = &Property{}
+	case "SetIndex":
+		e = &SetIndex{}
+	case "SetProperty":
+		e = &SetProperty{}
+	case "Unary":
+		e = &Unary{}
+	case "Variable":
+		e = &Variable{}
+	default:
+		return nil, fmt.Errorf("ast: unknown node type %q", header.Type)
+	}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+func marshalExprs(exprs []Expr) ([]json.RawMessage, error) {
+	raw := make([]json.RawMessage, len(exprs))
+	for i, e := range exprs {
+		var err error
+		raw[i], err = json.Marshal(e)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return raw, nil
+}
+
+func unmarshalExprs(raw []json.RawMessage) ([]Expr, error) {
+	exprs := make([]Expr, len(raw))
+	for i, data := range raw {
+		var err error
+		exprs[i], err = unmarshalExpr(data)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return exprs, nil
+}
+
+type jsonBadExpr struct {
+	Type   string        `json:"type"`
+	Tokens []token.Token `json:"tokens"`
+}
+
+func (b *BadExpr) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonBadExpr{
+		Type:   "BadExpr",
+		Tokens: b.Tokens,
+	})
+}
+
+func (b *BadExpr) UnmarshalJSON(data []byte) error {
+	var j jsonBadExpr
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*b = BadExpr{
+		Tokens: j.Tokens,
+	}
+	return nil
+}
+
+type jsonBinary struct {
+	Type     string          `json:"type"`
+	Left     json.RawMessage `json:"left"`
+	Operator token.Token     `json:"operator"`
+	Right    json.RawMessage `json:"right"`
+}
+
+func (b *Binary) MarshalJSON() ([]byte, error) {
+	left, err := json.Marshal(b.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := json.Marshal(b.Right)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonBinary{
+		Type:     "Binary",
+		Left:     left,
+		Operator: b.Operator,
+		Right:    right,
+	})
+}
+
+func (b *Binary) UnmarshalJSON(data []byte) error {
+	var j jsonBinary
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	left, err := unmarshalExpr(j.Left)
+	if err != nil {
+		return err
+	}
+	right, err := unmarshalExpr(j.Right)
+	if err != nil {
+		return err
+	}
+	*b = Binary{
+		Left:     left,
+		Operator: j.Operator,
+		Right:    right,
+	}
+	return nil
+}
+
+type jsonCall struct {
+	Type      string            `json:"type"`
+	Callee    json.RawMessage   `json:"callee"`
+	Paren     token.Token       `json:"paren"`
+	Arguments []json.RawMessage `json:"arguments"`
+}
+
+func (c *Call) MarshalJSON() ([]byte, error) {
+	callee, err := json.Marshal(c.Callee)
+	if err != nil {
+		return nil, err
+	}
+	arguments, err := marshalExprs(c.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonCall{
+		Type:      "Call",
+		Callee:    callee,
+		Paren:     c.Paren,
+		Arguments: arguments,
+	})
+}
+
+func (c *Call) UnmarshalJSON(data []byte) error {
+	var j jsonCall
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	callee, err := unmarshalExpr(j.Callee)
+	if err != nil {
+		return err
+	}
+	arguments, err := unmarshalExprs(j.Arguments)
+	if err != nil {
+		return err
+	}
+	*c = Call{
+		Callee:    callee,
+		Paren:     j.Paren,
+		Arguments: arguments,
+	}
+	return nil
+}
+
+type jsonConditional struct {
+	Type       string          `json:"type"`
+	Condition  json.RawMessage `json:"condition"`
+	ThenBranch json.RawMessage `json:"thenBranch"`
+	ElseBranch json.RawMessage `json:"elseBranch"`
+}
+
+func (c *Conditional) MarshalJSON() ([]byte, error) {
+	condition, err := json.Marshal(c.Condition)
+	if err != nil {
+		return nil, err
+	}
+	thenBranch, err := json.Marshal(c.ThenBranch)
+	if err != nil {
+		return nil, err
+	}
+	elseBranch, err := json.Marshal(c.ElseBranch)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonConditional{
+		Type:       "Conditional",
+		Condition:  condition,
+		ThenBranch: thenBranch,
+		ElseBranch: elseBranch,
+	})
+}
+
+func (c *Conditional) UnmarshalJSON(data []byte) error {
+	var j jsonConditional
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	condition, err := unmarshalExpr(j.Condition)
+	if err != nil {
+		return err
+	}
+	thenBranch, err := unmarshalExpr(j.ThenBranch)
+	if err != nil {
+		return err
+	}
+	elseBranch, err := unmarshalExpr(j.ElseBranch)
+	if err != nil {
+		return err
+	}
+	*c = Conditional{
+		Condition:  condition,
+		ThenBranch: thenBranch,
+		ElseBranch: elseBranch,
+	}
+	return nil
+}
+
+type jsonGrouping struct {
+	Type       string          `json:"type"`
+	Expression json.RawMessage `json:"expression"`
+}
+
+func (g *Grouping) MarshalJSON() ([]byte, error) {
+	expression, err := json.Marshal(g.Expression)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonGrouping{
+		Type:       "Grouping",
+		Expression: expression,
+	})
+}
+
+func (g *Grouping) UnmarshalJSON(data []byte) error {
+	var j jsonGrouping
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	expression, err := unmarshalExpr(j.Expression)
+	if err != nil {
+		return err
+	}
+	*g = Grouping{
+		Expression: expression,
+	}
+	return nil
+}
+
+type jsonIndex struct {
+	Type    string          `json:"type"`
+	Object  json.RawMessage `json:"object"`
+	Bracket token.Token     `json:"bracket"`
+	Index   json.RawMessage `json:"index"`
+}
+
+func (i *Index) MarshalJSON() ([]byte, error) {
+	object, err := json.Marshal(i.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := json.Marshal(i.Index)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonIndex{
+		Type:    "Index",
+		Object:  object,
+		Bracket: i.Bracket,
+		Index:   index,
+	})
+}
+
+func (i *Index) UnmarshalJSON(data []byte) error {
+	var j jsonIndex
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	object, err := unmarshalExpr(j.Object)
+	if err != nil {
+		return err
+	}
+	index, err := unmarshalExpr(j.Index)
+	if err != nil {
+		return err
+	}
+	*i = Index{
+		Object:  object,
+		Bracket: j.Bracket,
+		Index:   index,
+	}
+	return nil
+}
+
+type jsonListLiteral struct {
+	Type     string            `json:"type"`
+	Bracket  token.Token       `json:"bracket"`
+	Elements []json.RawMessage `json:"elements"`
+}
+
+func (l *ListLiteral) MarshalJSON() ([]byte, error) {
+	elements, err := marshalExprs(l.Elements)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonListLiteral{
+		Type:     "ListLiteral",
+		Bracket:  l.Bracket,
+		Elements: elements,
+	})
+}
+
+func (l *ListLiteral) UnmarshalJSON(data []byte) error {
+	var j jsonListLiteral
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	elements, err := unmarshalExprs(j.Elements)
+	if err != nil {
+		return err
+	}
+	*l = ListLiteral{
+		Bracket:  j.Bracket,
+		Elements: elements,
+	}
+	return nil
+}
+
+type jsonMapLiteral struct {
+	Type   string            `json:"type"`
+	Brace  token.Token       `json:"brace"`
+	Keys   []json.RawMessage `json:"keys"`
+	Values []json.RawMessage `json:"values"`
+}
+
+func (m *MapLiteral) MarshalJSON() ([]byte, error) {
+	keys, err := marshalExprs(m.Keys)
+	if err != nil {
+		return nil, err
+	}
+	values, err := marshalExprs(m.Values)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonMapLiteral{
+		Type:   "MapLiteral",
+		Brace:  m.Brace,
+		Keys:   keys,
+		Values: values,
+	})
+}
+
+func (m *MapLiteral) UnmarshalJSON(data []byte) error {
+	var j jsonMapLiteral
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	if len(j.Keys) != len(j.Values) {
+		return fmt.Errorf("ast: map literal has %d keys but %d values", len(j.Keys), len(j.Values))
+	}
+	keys, err := unmarshalExprs(j.Keys)
+	if err != nil {
+		return err
+	}
+	values, err := unmarshalExprs(j.Values)
+	if err != nil {
+		return err
+	}
+	*m = MapLiteral{
+		Brace:  j.Brace,
+		Keys:   keys,
+		Values: values,
+	}
+	return nil
+}
+
+type jsonLiteral struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+func (l *Literal) MarshalJSON() ([]byte, error) {
+	value, err := token.MarshalLiteral(l.Value)
+	if err != nil {
+		return nil, fmt.Errorf("ast: %w", err)
+	}
+	return json.Marshal(jsonLiteral{
+		Type:  "Literal",
+		Value: value,
+	})
+}
+
+func (l *Literal) UnmarshalJSON(data []byte) error {
+	var j jsonLiteral
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	value, err := token.UnmarshalLiteral(j.Value)
+	if err != nil {
+		return fmt.Errorf("ast: %w", err)
+	}
+	*l = Literal{
+		Value: value,
+	}
+	return nil
+}
+
+type jsonProperty struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+	Name   token.Token     `json:"name"`
+}
+
+func (p *Property) MarshalJSON() ([]byte, error) {
+	object, err := json.Marshal(p.Object)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonProperty{
+		Type:   "Property",
+		Object: object,
+		Name:   p.Name,
+	})
+}
+
+func (p *Property) UnmarshalJSON(data []byte) error {
+	var j jsonProperty
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	object, err := unmarshalExpr(j.Object)
+	if err != nil {
+		return err
+	}
+	*p = Property{
+		Object: object,
+		Name:   j.Name,
+	}
+	return nil
+}
+
+type jsonSetIndex struct {
+	Type    string          `json:"type"`
+	Object  json.RawMessage `json:"object"`
+	Bracket token.Token     `json:"bracket"`
+	Index   json.RawMessage `json:"index"`
+	Value   json.RawMessage `json:"value"`
+}
+
+func (s *SetIndex) MarshalJSON() ([]byte, error) {
+	object, err := json.Marshal(s.Object)
+	if err != nil {
+		return nil, err
+	}
+	index, err := json.Marshal(s.Index)
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(s.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonSetIndex{
+		Type:    "SetIndex",
+		Object:  object,
+		Bracket: s.Bracket,
+		Index:   index,
+		Value:   value,
+	})
+}
+
+func (s *SetIndex) UnmarshalJSON(data []byte) error {
+	var j jsonSetIndex
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	object, err := unmarshalExpr(j.Object)
+	if err != nil {
+		return err
+	}
+	index, err := unmarshalExpr(j.Index)
+	if err != nil {
+		return err
+	}
+	value, err := unmarshalExpr(j.Value)
+	if err != nil {
+		return err
+	}
+	*s = SetIndex{
+		Object:  object,
+		Bracket: j.Bracket,
+		Index:   index,
+		Value:   value,
+	}
+	return nil
+}
+
+type jsonSetProperty struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+	Name   token.Token     `json:"name"`
+	Value  json.RawMessage `json:"value"`
+}
+
+func (s *SetProperty) MarshalJSON() ([]byte, error) {
+	object, err := json.Marshal(s.Object)
+	if err != nil {
+		return nil, err
+	}
+	value, err := json.Marshal(s.Value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonSetProperty{
+		Type:   "SetProperty",
+		Object: object,
+		Name:   s.Name,
+		Value:  value,
+	})
+}
+
+func (s *SetProperty) UnmarshalJSON(data []byte) error {
+	var j jsonSetProperty
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	object, err := unmarshalExpr(j.Object)
+	if err != nil {
+		return err
+	}
+	value, err := unmarshalExpr(j.Value)
+	if err != nil {
+		return err
+	}
+	*s = SetProperty{
+		Object: object,
+		Name:   j.Name,
+		Value:  value,
+	}
+	return nil
+}
+
+type jsonUnary struct {
+	Type     string          `json:"type"`
+	Operator token.Token     `json:"operator"`
+	Right    json.RawMessage `json:"right"`
+}
+
+func (u *Unary) MarshalJSON() ([]byte, error) {
+	right, err := json.Marshal(u.Right)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonUnary{
+		Type:     "Unary",
+		Operator: u.Operator,
+		Right:    right,
+	})
+}
+
+func (u *Unary) UnmarshalJSON(data []byte) error {
+	var j jsonUnary
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	right, err := unmarshalExpr(j.Right)
+	if err != nil {
+		return err
+	}
+	*u = Unary{
+		Operator: j.Operator,
+		Right:    right,
+	}
+	return nil
+}
+
+type jsonVariable struct {
+	Type string      `json:"type"`
+	Name token.Token `json:"name"`
+}
+
+func (v *Variable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonVariable{
+		Type: "Variable",
+		Name: v.Name,
+	})
+}
+
+func (v *Variable) UnmarshalJSON(data []byte) error {
+	var j jsonVariable
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	*v = Variable{
+		Name: j.Name,
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/taylorlowery/lox/internal/token"
+)
+
+func TestMarshal_RoundTripsEveryNode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		expr Expr
+	}{
+		{
+			name: "number literal",
+			expr: &Literal{Value: 42.5},
+		},
+		{
+			name: "string literal",
+			expr: &Literal{Value: "hello"},
+		},
+		{
+			name: "boolean literal",
+			expr: &Literal{Value: true},
+		},
+		{
+			name: "nil literal",
+			expr: &Literal{Value: nil},
+		},
+		{
+			name: "infinite literal",
+			expr: &Literal{Value: math.Inf(-1)},
+		},
+		{
+			name: "bad expression",
+			expr: &BadExpr{
+				Tokens: []token.Token{
+					{TokenType: token.RIGHT_PAREN, Lexeme: ")", Line: 1},
+					{TokenType: token.NUMBER, Lexeme: "2", Literal: 2.0, Line: 1},
+				},
+			},
+		},
+		{
+			name: "conditional",
+			expr: &Conditional{
+				Condition:  &Literal{Value: true},
+				ThenBranch: &Literal{Value: 1.0},
+				ElseBranch: &Literal{Value: "no"},
+			},
+		},
+		{
+			name: "call",
+			expr: &Call{
+				Callee: &Variable{Name: token.Token{TokenType: token.IDENTIFIER, Lexeme: "max", Line: 2}},
+				Paren:  token.Token{TokenType: token.RIGHT_PAREN, Lexeme: ")", Line: 2},
+				Arguments: []Expr{
+					&Literal{Value: 1.0},
+					&Variable{Name: token.Token{TokenType: token.IDENTIFIER, Lexeme: "x", Line: 2}},
+				},
+			},
+		},
+		{
+			name: "call without arguments",
+			expr: &Call{
+				Callee:    &Variable{Name: token.Token{TokenType: token.IDENTIFIER, Lexeme: "clock", Line: 1}},
+				Paren:     token.Token{TokenType: token.RIGHT_PAREN, Lexeme: ")", Line: 1},
+				Arguments: []Expr{},
+			},
+		},
+		{
+			name: "list",
+			expr: &ListLiteral{
+				Bracket:  token.Token{TokenType: token.LEFT_BRACKET, Lexeme: "[", Line: 1},
+				Elements: []Expr{&Literal{Value: 1.0}, &Literal{Value: "two"}},
+			},
+		},
+		{
+			name: "map",
+			expr: &MapLiteral{
+				Brace:  token.Token{TokenType: token.LEFT_BRACE, Lexeme: "{", Line: 1},
+				Keys:   []Expr{&Literal{Value: "a"}, &Literal{Value: 2.0}},
+				Values: []Expr{&Literal{Value: true}, &ListLiteral{Bracket: token.Token{TokenType: token.LEFT_BRACKET, Lexeme: "[", Line: 1}, Elements: []Expr{}}},
+			},
+		},
+		{
+			name: "index",
+			expr: &Index{
+				Object:  &Variable{Name: token.Token{TokenType: token.IDENTIFIER, Lexeme: "xs", Line: 1}},
+				Bracket: token.Token{TokenType: token.RIGHT_BRACKET, Lexeme: "]", Line: 1},
+				Index:   &Literal{Value: 0.0},
+			},
+		},
+		{
+			name: "set index",
+			expr: &SetIndex{
+				Object:  &Variable{Name: token.Token{TokenType: token.IDENTIFIER, Lexeme: "xs", Line: 1}},
+				Bracket: token.Token{TokenType: token.RIGHT_BRACKET, Lexeme: "]", Line: 1},
+				Index:   &Literal{Value: 0.0},
+				Value:   &Literal{Value: nil},
+			},
+		},
+		{
+			name: "property",
+			expr: &Property{
+				Object: &Variable{Name: token.Token{TokenType: token.IDENTIFIER, Lexeme: "point", Line: 1}},
+				Name:   token.Token{TokenType: token.IDENTIFIER, Lexeme: "x", Line: 1},
+			},
+		},
+		{
+			name: "set property",
+			expr: &SetProperty{
+				Object: &Variable{Name: token.Token{TokenType: token.IDENTIFIER, Lexeme: "point", Line: 1}},
+				Name:   token.Token{TokenType: token.IDENTIFIER, Lexeme: "x", Line: 1},
+				Value:  &Literal{Value: 1.0},
+			},
+		},
+		{
+			name: "nil expression",
+			expr: nil,
+		},
+		{
+			name: "nested tree",
+			expr: &Binary{
+				Left: &Unary{
+					Operator: token.Token{TokenType: token.MINUS, Lexeme: "-", Line: 1},
+					Right:    &Literal{Value: 123.0},
+				},
+				Operator: token.Token{TokenType: token.STAR, Lexeme: "*", Line: 2},
+				Right: &Grouping{
+					Expression: &Literal{Value: 45.67},
+				},
+			},
+		},
+		{
+			name: "token literal",
+			expr: &Unary{
+				Operator: token.Token{TokenType: token.STRING, Lexeme: "\"hi\"", Literal: "hi", Line: 3},
+				Right:    &Literal{Value: false},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := Marshal(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Unmarshal(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.expr, got); diff != "" {
+				t.Fatalf("round trip of %s mismatch (-want +got):\n%s", data, diff)
+			}
+		})
+	}
+}
+
+func TestMarshal_WritesTypeDiscriminator(t *testing.T) {
+	t.Parallel()
+
+	expr := &Unary{
+		Operator: token.Token{TokenType: token.BANG, Lexeme: "!", Line: 1},
+		Right:    &Literal{Value: true},
+	}
+	data, err := Marshal(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := fmt.Sprintf(`{"version":%d,"expr":{"type":"Unary","operator":{"type":"BANG","lexeme":"!","literal":null,"line":1},"right":{"type":"Literal","value":true}}}`, SchemaVersion)
+	if got := string(data); got != want {
+		t.Fatal(cmp.Diff(want, got))
+	}
+}
+
+func TestMarshal_NaNLiteralRoundTrips(t *testing.T) {
+	t.Parallel()
+
+	data, err := Marshal(&Literal{Value: math.NaN()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, ok := got.(*Literal).Value.(float64)
+	if !ok || !math.IsNaN(value) {
+		t.Fatalf("expected NaN literal, got %#v", got)
+	}
+}
+
+func TestMarshal_UnsupportedLiteralIsAnError(t *testing.T) {
+	t.Parallel()
+
+	_, err := Marshal(&Literal{Value: 123})
+	if err == nil {
+		t.Fatal("expected an error for an int literal")
+	}
+}
+
+func TestUnmarshal_RejectsStaleSchema(t *testing.T) {
+	t.Parallel()
+
+	_, err := Unmarshal(fmt.Appendf(nil, `{"version":%d,"expr":{"type":"Literal","value":1}}`, SchemaVersion-1))
+	if !errors.Is(err, ErrSchemaVersion) {
+		t.Fatalf("expected ErrSchemaVersion, got %v", err)
+	}
+}
+
+func TestUnmarshal_RejectsUnknownNodeType(t *testing.T) {
+	t.Parallel()
+
+	_, err := Unmarshal(fmt.Appendf(nil, `{"version":%d,"expr":{"type":"Lambda"}}`, SchemaVersion))
+	if err == nil {
+		t.Fatal("expected an error for an unknown node type")
+	}
+}
@@ -36,14 +36,39 @@ func WithStdout(w io.Writer) astOption {
 	}
 }
 
+func (a *AstPrinter) visitBadExpr(expr *BadExpr) any {
+	result := "(bad"
+	for _, t := range expr.Tokens {
+		result += " " + t.Lexeme
+	}
+	result += ")"
+	return result
+}
+
 func (a *AstPrinter) visitBinaryExpr(expr *Binary) any {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
 }
 
+func (a *AstPrinter) visitCallExpr(expr *Call) any {
+	return a.parenthesize("call", append([]Expr{expr.Callee}, expr.Arguments...)...)
+}
+
+func (a *AstPrinter) visitConditionalExpr(expr *Conditional) any {
+	return a.parenthesize("?:", expr.Condition, expr.ThenBranch, expr.ElseBranch)
+}
+
 func (a *AstPrinter) visitGroupingExpr(expr *Grouping) any {
 	return a.parenthesize("group", expr.Expression)
 }
 
+func (a *AstPrinter) visitIndexExpr(expr *Index) any {
+	return a.parenthesize("index", expr.Object, expr.Index)
+}
+
+func (a *AstPrinter) visitListLiteralExpr(expr *ListLiteral) any {
+	return a.parenthesize("list", expr.Elements...)
+}
+
 func (a *AstPrinter) visitLiteralExpr(expr *Literal) any {
 	if expr.Value == nil {
 		return nil
@@ -51,10 +76,34 @@ func (a *AstPrinter) visitLiteralExpr(expr *Literal) any {
 	return fmt.Sprint(expr.Value)
 }
 
+func (a *AstPrinter) visitMapLiteralExpr(expr *MapLiteral) any {
+	entries := make([]Expr, 0, 2*len(expr.Keys))
+	for i, key := range expr.Keys {
+		entries = append(entries, key, expr.Values[i])
+	}
+	return a.parenthesize("map", entries...)
+}
+
+func (a *AstPrinter) visitPropertyExpr(expr *Property) any {
+	return a.parenthesize("."+expr.Name.Lexeme, expr.Object)
+}
+
+func (a *AstPrinter) visitSetIndexExpr(expr *SetIndex) any {
+	return a.parenthesize("set", expr.Object, expr.Index, expr.Value)
+}
+
+func (a *AstPrinter) visitSetPropertyExpr(expr *SetProperty) any {
+	return a.parenthesize("set."+expr.Name.Lexeme, expr.Object, expr.Value)
+}
+
 func (a *AstPrinter) visitUnaryExpr(expr *Unary) any {
 	return a.parenthesize(expr.Operator.Lexeme, expr.Right)
 }
 
+func (a *AstPrinter) visitVariableExpr(expr *Variable) any {
+	return expr.Name.Lexeme
+}
+
 func (a *AstPrinter) PrintAst(expr Expr) string {
 	return fmt.Sprint(expr.accept(a))
 }
@@ -0,0 +1,324 @@
+package chunk
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"maps"
+	"math"
+	"slices"
+)
+
+// FormatVersion is the version of the .loxc format written by Marshal.
+// Bump it whenever the format or the meaning of an opcode changes,
+// so that files written by older versions are recompiled instead of misread.
+const FormatVersion = 6
+
+// Magic is the header every .loxc file starts with.
+const Magic = "LOXC"
+
+var (
+	// ErrBadMagic is returned when unmarshaling data that isn't a .loxc file.
+	ErrBadMagic = errors.New("not a compiled lox file")
+	// ErrFormatVersion is returned when unmarshaling a .loxc file written
+	// with a different FormatVersion.
+	ErrFormatVersion = errors.New("unsupported compiled lox file version")
+	// ErrCorrupt is returned when a .loxc file fails its checksum
+	// or doesn't hold a well formed chunk.
+	ErrCorrupt = errors.New("corrupt compiled lox file")
+)
+
+// SourceHash identifies the source a chunk was compiled from.
+type SourceHash [sha256.Size]byte
+
+// HashSource returns the SourceHash of a program's source.
+func HashSource(source []byte) SourceHash {
+	return sha256.Sum256(source)
+}
+
+func (h SourceHash) String() string {
+	return fmt.Sprintf("%x", h[:])
+}
+
+// constant tags in the .loxc constant pool
+const (
+	tagNil byte = iota
+	tagFalse
+	tagTrue
+	tagNumber
+	tagString
+)
+
+// Marshal encodes a chunk in the .loxc format:
+//
+//	magic       "LOXC"
+//	version     uint16
+//	source hash 32 bytes, the SHA-256 of the source
+//	code        uvarint length, then the bytes
+//	lines       uvarint count, then (offset, line) uvarint pairs
+//	constants   uvarint count, then a tag byte and payload for each
+//	checksum    uint32, the CRC-32 of everything before it
+//
+// Fixed width integers are big-endian, like the operands in the code.
+func Marshal(c *Chunk, hash SourceHash) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(Magic)
+	buf.Write(binary.BigEndian.AppendUint16(nil, FormatVersion))
+	buf.Write(hash[:])
+
+	buf.Write(binary.AppendUvarint(nil, uint64(len(c.Code))))
+	buf.Write(c.Code)
+
+	buf.Write(binary.AppendUvarint(nil, uint64(len(c.lines))))
+	for _, run := range c.lines {
+		buf.Write(binary.AppendUvarint(nil, uint64(run.offset)))
+		buf.Write(binary.AppendUvarint(nil, uint64(run.line)))
+	}
+
+	buf.Write(binary.AppendUvarint(nil, uint64(len(c.constants))))
+	for _, constant := range c.constants {
+		switch v := constant.(type) {
+		case nil:
+			buf.WriteByte(tagNil)
+		case bool:
+			if v {
+				buf.WriteByte(tagTrue)
+			} else {
+				buf.WriteByte(tagFalse)
+			}
+		case float64:
+			buf.WriteByte(tagNumber)
+			buf.Write(binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+		case string:
+			buf.WriteByte(tagString)
+			buf.Write(binary.AppendUvarint(nil, uint64(len(v))))
+			buf.WriteString(v)
+		default:
+			return nil, fmt.Errorf("cannot marshal constant of type %T", v)
+		}
+	}
+
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc32.ChecksumIEEE(buf.Bytes())))
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes a chunk in the .loxc format
+// and returns it with the hash of the source it was compiled from.
+// Besides checking the checksum, it checks that every instruction is complete,
+// refers to a constant that exists, and only jumps forward to the start of
+// another instruction, and that no instruction pops more values than are on
+// the stack on any path to it. Those are the mistakes the VM doesn't check for
+// as it runs, so a corrupt chunk can't crash it.
+func Unmarshal(data []byte) (*Chunk, SourceHash, error) {
+	var hash SourceHash
+	header := len(Magic) + 2 + len(hash)
+	if len(data) < len(Magic) || string(data[:len(Magic)]) != Magic {
+		return nil, hash, ErrBadMagic
+	}
+	if len(data) < len(Magic)+2 {
+		return nil, hash, ErrCorrupt
+	}
+	// the version comes first, since other versions may lay out the rest differently
+	if version := binary.BigEndian.Uint16(data[len(Magic):]); version != FormatVersion {
+		return nil, hash, fmt.Errorf("%w: %d", ErrFormatVersion, version)
+	}
+	if len(data) < header+4 {
+		return nil, hash, ErrCorrupt
+	}
+	body, sum := data[:len(data)-4], binary.BigEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, hash, fmt.Errorf("%w: checksum mismatch", ErrCorrupt)
+	}
+	copy(hash[:], data[len(Magic)+2:header])
+
+	d := decoder{data: body[header:]}
+	c := NewChunk()
+	c.Code = d.bytes(d.length())
+	for range d.length() {
+		c.lines = append(c.lines, lineRun{offset: d.int(), line: d.int()})
+	}
+	for range d.length() {
+		c.appendConstant(d.constant())
+	}
+	if d.err == nil && len(d.data) > 0 {
+		d.fail("trailing data")
+	}
+	if d.err != nil {
+		return nil, hash, d.err
+	}
+	if err := c.validate(); err != nil {
+		return nil, hash, err
+	}
+	return c, hash, nil
+}
+
+// decoder reads the fields of a .loxc body,
+// remembering the first error so callers can check once at the end.
+type decoder struct {
+	data []byte
+	err  error
+}
+
+func (d *decoder) fail(message string) {
+	if d.err == nil {
+		d.err = fmt.Errorf("%w: %s", ErrCorrupt, message)
+	}
+	d.data = nil
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.fail("bad integer")
+		return 0
+	}
+	d.data = d.data[n:]
+	return v
+}
+
+func (d *decoder) int() int {
+	v := d.uvarint()
+	if v > math.MaxInt32 {
+		d.fail("integer out of range")
+		return 0
+	}
+	return int(v)
+}
+
+// length reads a count of items that each take at least one byte,
+// so a corrupt count can't make the caller allocate more than the data holds.
+func (d *decoder) length() int {
+	n := d.int()
+	if n > len(d.data) {
+		d.fail("length out of range")
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) bytes(n int) []byte {
+	if n > len(d.data) {
+		d.fail("unexpected end of data")
+		return nil
+	}
+	b := bytes.Clone(d.data[:n])
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) constant() any {
+	tag := d.bytes(1)
+	if tag == nil {
+		return nil
+	}
+	switch tag[0] {
+	case tagNil:
+		return nil
+	case tagFalse:
+		return false
+	case tagTrue:
+		return true
+	case tagNumber:
+		b := d.bytes(8)
+		if b == nil {
+			return nil
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b))
+	case tagString:
+		return string(d.bytes(d.length()))
+	default:
+		d.fail(fmt.Sprintf("unknown constant tag %d", tag[0]))
+		return nil
+	}
+}
+
+// validate checks that a decoded chunk is one the compiler could have produced.
+func (c *Chunk) validate() error {
+	for i, run := range c.lines {
+		if run.offset >= len(c.Code) || i > 0 && run.offset <= c.lines[i-1].offset {
+			return fmt.Errorf("%w: bad line table", ErrCorrupt)
+		}
+	}
+	if len(c.lines) == 0 || c.lines[0].offset != 0 {
+		return fmt.Errorf("%w: bad line table", ErrCorrupt)
+	}
+
+	var op OpCode
+	// depth is the stack depth before the instruction at offset,
+	// if an instruction before it falls through to it.
+	// depths holds the depth before each jump target ahead, which needn't
+	// be reachable by falling through; jumps only go forward.
+	depth, reachable := 0, true
+	depths := map[int]int{}
+	// targets holds the jump targets ahead, to check they start instructions
+	targets := map[int]bool{}
+	for offset := 0; offset < len(c.Code); {
+		op = OpCode(c.Code[offset])
+		if op > OP_RETURN {
+			return fmt.Errorf("%w: unknown opcode %d at %d", ErrCorrupt, op, offset)
+		}
+		width := op.OperandWidth()
+		if offset+width >= len(c.Code) {
+			return fmt.Errorf("%w: truncated instruction at %d", ErrCorrupt, offset)
+		}
+		operand := 0
+		for _, b := range c.Code[offset+1 : offset+1+width] {
+			operand = operand<<8 | int(b)
+		}
+		next := offset + 1 + width
+		delete(targets, offset)
+		if d, ok := depths[offset]; ok {
+			if reachable && d != depth {
+				return fmt.Errorf("%w: stack depths %d and %d meet at %d", ErrCorrupt, depth, d, offset)
+			}
+			depth, reachable = d, true
+		}
+		if reachable {
+			pops, pushes := op.stackEffect(operand)
+			if pops > depth {
+				return fmt.Errorf("%w: stack underflow at %d", ErrCorrupt, offset)
+			}
+			depth += pushes - pops
+		}
+		switch op {
+		case OP_CONSTANT, OP_CONSTANT_LONG:
+			if operand >= len(c.constants) {
+				return fmt.Errorf("%w: constant %d out of range at %d", ErrCorrupt, operand, offset)
+			}
+		case OP_GET_GLOBAL, OP_GET_PROPERTY, OP_SET_PROPERTY:
+			if operand >= len(c.constants) {
+				return fmt.Errorf("%w: constant %d out of range at %d", ErrCorrupt, operand, offset)
+			}
+			if _, ok := c.constants[operand].(string); !ok {
+				return fmt.Errorf("%w: name isn't a string at %d", ErrCorrupt, offset)
+			}
+		case OP_JUMP, OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE:
+			target := next + operand
+			if target >= len(c.Code) {
+				return fmt.Errorf("%w: jump out of range at %d", ErrCorrupt, offset)
+			}
+			targets[target] = true
+			if reachable {
+				if d, ok := depths[target]; ok && d != depth {
+					return fmt.Errorf("%w: stack depths %d and %d meet at %d", ErrCorrupt, depth, d, target)
+				}
+				depths[target] = depth
+			}
+		}
+		if op == OP_JUMP || op == OP_RETURN {
+			reachable = false
+		}
+		offset = next
+	}
+	if len(targets) > 0 {
+		target := slices.Min(slices.Collect(maps.Keys(targets)))
+		return fmt.Errorf("%w: jump into the middle of an instruction at %d", ErrCorrupt, target)
+	}
+	if len(c.Code) == 0 || op != OP_RETURN {
+		return fmt.Errorf("%w: code doesn't end with %s", ErrCorrupt, OP_RETURN)
+	}
+	return nil
+}
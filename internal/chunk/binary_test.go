@@ -0,0 +1,235 @@
+package chunk_test
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/taylorlowery/lox/internal/chunk"
+)
+
+func testChunk() *chunk.Chunk {
+	c := chunk.NewChunk()
+	c.WriteConstant(1.5, 1)
+	c.WriteConstant(math.Copysign(0, -1), 1)
+	c.WriteOp(chunk.OP_JUMP_IF_FALSE, 2)
+	c.Write(0, 2)
+	c.Write(6, 2)
+	c.WriteOp(chunk.OP_POP, 2)
+	c.WriteConstant("str", 3)
+	c.WriteOp(chunk.OP_JUMP, 3)
+	c.Write(0, 3)
+	c.Write(0, 3)
+	c.WriteOp(chunk.OP_NIL, 3)
+	c.WriteOp(chunk.OP_TRUE, 3)
+	c.WriteOp(chunk.OP_FALSE, 3)
+	c.WriteOp(chunk.OP_RETURN, 4)
+	c.AddConstant(nil)
+	c.AddConstant(true)
+	return c
+}
+
+func TestMarshal_RoundTrips(t *testing.T) {
+	t.Parallel()
+
+	c := testChunk()
+	hash := chunk.HashSource([]byte("source"))
+	data, err := chunk.Marshal(c, hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, gotHash, err := chunk.Unmarshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gotHash != hash {
+		t.Errorf("want hash %s, got %s", hash, gotHash)
+	}
+	if diff := cmp.Diff(c.Code, got.Code); diff != "" {
+		t.Error(diff)
+	}
+	if len(got.Constants()) != len(c.Constants()) {
+		t.Fatalf("want %d constants, got %d", len(c.Constants()), len(got.Constants()))
+	}
+	for i, constant := range c.Constants() {
+		if got.AddConstant(constant) != i {
+			t.Errorf("constant %d: want %#v, got %#v", i, constant, got.Constants()[i])
+		}
+	}
+	for offset := range c.Code {
+		if got.Line(offset) != c.Line(offset) {
+			t.Errorf("offset %d: want line %d, got %d", offset, c.Line(offset), got.Line(offset))
+		}
+	}
+}
+
+func TestMarshal_RejectsUnknownConstants(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	c.WriteConstant([]int{1}, 1)
+	c.WriteOp(chunk.OP_RETURN, 1)
+	if _, err := chunk.Marshal(c, chunk.SourceHash{}); err == nil {
+		t.Fatal("expected an error for a constant with no encoding")
+	}
+}
+
+func TestUnmarshal_RejectsBadData(t *testing.T) {
+	t.Parallel()
+
+	valid, err := chunk.Marshal(testChunk(), chunk.SourceHash{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// reseal recomputes the checksum of data that was edited after marshaling,
+	// to get past the checksum to the structural checks
+	reseal := func(c *chunk.Chunk) []byte {
+		data, err := chunk.Marshal(c, chunk.SourceHash{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{
+			name: "empty",
+			data: nil,
+			want: chunk.ErrBadMagic,
+		},
+		{
+			name: "wrong magic",
+			data: append([]byte("LOXD"), valid[4:]...),
+			want: chunk.ErrBadMagic,
+		},
+		{
+			name: "wrong version",
+			data: binary.BigEndian.AppendUint16([]byte(chunk.Magic), chunk.FormatVersion+1),
+			want: chunk.ErrFormatVersion,
+		},
+		{
+			name: "truncated",
+			data: valid[:len(valid)-10],
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "flipped bit",
+			data: func() []byte {
+				data := append([]byte(nil), valid...)
+				data[len(data)/2] ^= 1
+				return data
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "unknown opcode",
+			data: func() []byte {
+				c := testChunk()
+				c.Code[len(c.Code)-1] = 0xfe
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "constant out of range",
+			data: func() []byte {
+				c := testChunk()
+				c.Code[1] = 100
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "global name isn't a string",
+			data: func() []byte {
+				c := testChunk()
+				c.Code[0] = byte(chunk.OP_GET_GLOBAL)
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "property name isn't a string",
+			data: func() []byte {
+				c := testChunk()
+				c.Code[2] = byte(chunk.OP_GET_PROPERTY)
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "jump out of range",
+			data: func() []byte {
+				c := testChunk()
+				c.Code[5] = 0xff
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "stack underflow",
+			data: func() []byte {
+				c := chunk.NewChunk()
+				c.WriteOp(chunk.OP_POP, 1)
+				c.WriteOp(chunk.OP_RETURN, 1)
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "paths with different stack depths",
+			data: func() []byte {
+				c := chunk.NewChunk()
+				c.WriteOp(chunk.OP_TRUE, 1)
+				c.WriteOp(chunk.OP_JUMP_IF_TRUE, 1)
+				c.Write(0, 1)
+				c.Write(1, 1)
+				c.WriteOp(chunk.OP_POP, 1)
+				c.WriteOp(chunk.OP_NEGATE, 1)
+				c.WriteOp(chunk.OP_RETURN, 1)
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "jump into an instruction",
+			data: func() []byte {
+				c := chunk.NewChunk()
+				c.WriteOp(chunk.OP_TRUE, 1)
+				c.WriteOp(chunk.OP_JUMP, 1)
+				c.Write(0, 1)
+				c.Write(1, 1)
+				c.WriteConstant(1.0, 1)
+				c.WriteOp(chunk.OP_RETURN, 1)
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+		{
+			name: "no return",
+			data: func() []byte {
+				c := chunk.NewChunk()
+				c.WriteOp(chunk.OP_NIL, 1)
+				return reseal(c)
+			}(),
+			want: chunk.ErrCorrupt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := chunk.Unmarshal(tt.data)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("want %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+// Package chunk defines the bytecode that golox's compiler produces
+// and its virtual machine runs.
+package chunk
+
+import (
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"sort"
+)
+
+//go:generate stringer -type=OpCode
+
+// OpCode is a single bytecode instruction.
+// Operands, if any, follow the opcode in the code.
+type OpCode byte
+
+const (
+	// OP_CONSTANT pushes the constant whose index is its 1 byte operand.
+	OP_CONSTANT OpCode = iota
+	// OP_CONSTANT_LONG pushes the constant whose index is its 3 byte big-endian operand.
+	OP_CONSTANT_LONG
+	OP_NIL
+	OP_TRUE
+	OP_FALSE
+	OP_POP
+
+	OP_EQUAL
+	OP_GREATER
+	OP_GREATER_EQUAL
+	OP_LESS
+	OP_LESS_EQUAL
+	OP_ADD
+	OP_SUBTRACT
+	OP_MULTIPLY
+	OP_DIVIDE
+	OP_NOT
+	OP_NEGATE
+
+	// OP_JUMP moves forward by its 2 byte big-endian operand.
+	OP_JUMP
+	// OP_JUMP_IF_FALSE moves forward by its 2 byte big-endian operand
+	// if the value on top of the stack is falsey. It leaves the value on the stack.
+	OP_JUMP_IF_FALSE
+	// OP_JUMP_IF_TRUE is OP_JUMP_IF_FALSE for truthy values.
+	OP_JUMP_IF_TRUE
+
+	// OP_GET_GLOBAL pushes the value of the global whose name is the string constant
+	// at the index given by its 1 byte operand.
+	OP_GET_GLOBAL
+	// OP_CALL calls a function with the number of arguments given by its 1 byte operand.
+	// The function sits on the stack below its arguments, and all of them are replaced by its result.
+	OP_CALL
+
+	// OP_BUILD_LIST replaces the number of values given by its 2 byte big-endian operand
+	// with a list of them, the deepest first.
+	OP_BUILD_LIST
+	// OP_BUILD_MAP replaces the number of key and value pairs given by its 2 byte big-endian operand
+	// with a map of them, each key below its value and the deepest pair first.
+	OP_BUILD_MAP
+	// OP_GET_INDEX replaces a list and an index above it with the element at the index.
+	OP_GET_INDEX
+	// OP_SET_INDEX replaces a list, an index and a value above them with the value,
+	// after storing it in the list at the index.
+	OP_SET_INDEX
+	// OP_GET_PROPERTY replaces an object with the value of its property whose name is
+	// the string constant at the index given by its 1 byte operand.
+	OP_GET_PROPERTY
+	// OP_SET_PROPERTY replaces an object and a value above it with the value,
+	// after storing it in the property named as for OP_GET_PROPERTY.
+	OP_SET_PROPERTY
+
+	// OP_RETURN pops the result of the program and stops.
+	// It must stay the last opcode, since Unmarshal rejects anything greater.
+	OP_RETURN
+)
+
+// OperandWidth returns the number of operand bytes that follow an opcode.
+func (op OpCode) OperandWidth() int {
+	switch op {
+	case OP_CONSTANT, OP_GET_GLOBAL, OP_CALL, OP_GET_PROPERTY, OP_SET_PROPERTY:
+		return 1
+	case OP_JUMP, OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE, OP_BUILD_LIST, OP_BUILD_MAP:
+		return 2
+	case OP_CONSTANT_LONG:
+		return 3
+	default:
+		return 0
+	}
+}
+
+// stackEffect returns the number of values an instruction pops
+// and the number it pushes, given its operand.
+func (op OpCode) stackEffect(operand int) (pops, pushes int) {
+	switch op {
+	case OP_CONSTANT, OP_CONSTANT_LONG, OP_NIL, OP_TRUE, OP_FALSE, OP_GET_GLOBAL:
+		return 0, 1
+	case OP_POP, OP_RETURN:
+		return 1, 0
+	case OP_NOT, OP_NEGATE, OP_GET_PROPERTY:
+		return 1, 1
+	case OP_JUMP:
+		return 0, 0
+	case OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE:
+		// the condition is only peeked at
+		return 1, 1
+	case OP_CALL:
+		return operand + 1, 1
+	case OP_BUILD_LIST:
+		return operand, 1
+	case OP_BUILD_MAP:
+		return 2 * operand, 1
+	case OP_SET_INDEX:
+		return 3, 1
+	default:
+		// the binary operators, OP_GET_INDEX and OP_SET_PROPERTY
+		return 2, 1
+	}
+}
+
+// lineRun records that the code from offset onwards came from line,
+// up to the offset of the next run.
+type lineRun struct {
+	offset int
+	line   int
+}
+
+// Chunk is a sequence of bytecode with the constants it refers to.
+// Source lines are kept in a run-length encoded table,
+// since consecutive instructions usually share a line.
+type Chunk struct {
+	Code []byte
+	// File is the path of the source the chunk was compiled from, if any,
+	// for error messages. It isn't part of the .loxc format,
+	// since the same compiled code can be loaded for scripts at different paths.
+	File  string
+	lines []lineRun
+	// constants only grows, so that index stays in step with it.
+	constants []any
+	// index maps the key of each constant to its first index in constants.
+	index map[any]int
+}
+
+// NewChunk returns an empty Chunk.
+func NewChunk() *Chunk {
+	return &Chunk{}
+}
+
+// Write appends a byte of code that came from the given source line.
+func (c *Chunk) Write(b byte, line int) {
+	if n := len(c.lines); n == 0 || c.lines[n-1].line != line {
+		c.lines = append(c.lines, lineRun{offset: len(c.Code), line: line})
+	}
+	c.Code = append(c.Code, b)
+}
+
+// WriteOp appends an opcode that came from the given source line.
+func (c *Chunk) WriteOp(op OpCode, line int) {
+	c.Write(byte(op), line)
+}
+
+// Constants returns the constant pool, which mustn't be changed.
+// Use AddConstant to add to it.
+func (c *Chunk) Constants() []any {
+	return c.constants
+}
+
+// AddConstant adds a value to the constant pool and returns its index.
+// A value already in the pool is reused.
+func (c *Chunk) AddConstant(v any) int {
+	key, ok := constantKey(v)
+	if i, seen := c.index[key]; ok && seen {
+		return i
+	}
+	return c.appendConstant(v)
+}
+
+// appendConstant adds a value to the end of the constant pool, even if it's already there,
+// and returns its index.
+func (c *Chunk) appendConstant(v any) int {
+	i := len(c.constants)
+	c.constants = append(c.constants, v)
+	if key, ok := constantKey(v); ok {
+		if _, seen := c.index[key]; !seen {
+			if c.index == nil {
+				c.index = map[any]int{}
+			}
+			c.index[key] = i
+		}
+	}
+	return i
+}
+
+// WithoutCode returns an empty chunk from the same file
+// with a copy of the constant pool, so that code written to it
+// can refer to the same constants.
+func (c *Chunk) WithoutCode() *Chunk {
+	return &Chunk{
+		File:      c.File,
+		constants: slices.Clone(c.constants),
+		index:     maps.Clone(c.index),
+	}
+}
+
+// floatKey is the constantKey of a number.
+type floatKey uint64
+
+// constantKey returns the key of a constant in the pool's index,
+// or false if it can't be a map key, in which case it's never reused.
+// Numbers are keyed by their bits, since == would make 0 and -0
+// the same constant, and NaN never equal to itself.
+func constantKey(v any) (any, bool) {
+	if n, ok := v.(float64); ok {
+		return floatKey(math.Float64bits(n)), true
+	}
+	if v != nil && !reflect.ValueOf(v).Comparable() {
+		return nil, false
+	}
+	return v, true
+}
+
+// WriteConstant appends the instruction to push a constant,
+// using OP_CONSTANT_LONG if the pool has outgrown a 1 byte index.
+func (c *Chunk) WriteConstant(v any, line int) {
+	index := c.AddConstant(v)
+	if index <= 0xff {
+		c.WriteOp(OP_CONSTANT, line)
+		c.Write(byte(index), line)
+		return
+	}
+	c.WriteOp(OP_CONSTANT_LONG, line)
+	c.Write(byte(index>>16), line)
+	c.Write(byte(index>>8), line)
+	c.Write(byte(index), line)
+}
+
+// Line returns the source line of the code at a given offset.
+func (c *Chunk) Line(offset int) int {
+	i := sort.Search(len(c.lines), func(i int) bool {
+		return c.lines[i].offset > offset
+	})
+	if i == 0 {
+		return 0
+	}
+	return c.lines[i-1].line
+}
@@ -0,0 +1,115 @@
+package chunk_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/chunk"
+)
+
+func TestChunk_LineReturnsLineOfEachOffset(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	lines := []int{1, 1, 1, 2, 4, 4, 3}
+	for _, line := range lines {
+		c.WriteOp(chunk.OP_NIL, line)
+	}
+
+	for offset, want := range lines {
+		if got := c.Line(offset); got != want {
+			t.Errorf("offset %d: want line %d, got %d", offset, want, got)
+		}
+	}
+}
+
+func TestChunk_AddConstantReusesEqualConstants(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	a := c.AddConstant(1.0)
+	b := c.AddConstant("one")
+	if c.AddConstant(1.0) != a || c.AddConstant("one") != b {
+		t.Fatal("expected equal constants to share an index")
+	}
+	if c.AddConstant(math.Copysign(0, -1)) == c.AddConstant(0.0) {
+		t.Fatal("expected 0 and -0 to be different constants")
+	}
+	if c.AddConstant(math.NaN()) != c.AddConstant(math.NaN()) {
+		t.Fatal("expected NaN to be reused")
+	}
+	if len(c.Constants()) != 5 {
+		t.Fatalf("expected 5 constants, got %d", len(c.Constants()))
+	}
+}
+
+func TestChunk_WithoutCodeCopiesTheConstantPool(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	c.File = "script.lox"
+	c.WriteConstant("a", 1)
+	c.AddConstant("b")
+
+	copied := c.WithoutCode()
+	if len(copied.Code) != 0 || copied.File != c.File {
+		t.Fatalf("want no code from %s, got %v from %s", c.File, copied.Code, copied.File)
+	}
+	if got := copied.AddConstant("b"); got != 1 {
+		t.Fatalf("want index 1, got %d", got)
+	}
+	if got := copied.AddConstant("c"); got != 2 {
+		t.Fatalf("want index 2, got %d", got)
+	}
+	if got := c.AddConstant("d"); got != 2 {
+		t.Fatalf("want the original pool unchanged, got index %d", got)
+	}
+}
+
+func TestChunk_WriteConstantUsesLongFormPast255(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	for i := range 256 {
+		c.WriteConstant(float64(i), 1)
+	}
+	if len(c.Code) != 256*2 {
+		t.Fatalf("expected 256 short constant instructions, got %d bytes", len(c.Code))
+	}
+
+	c.WriteConstant(256.0, 1)
+	code := c.Code[256*2:]
+	want := []byte{byte(chunk.OP_CONSTANT_LONG), 0, 1, 0}
+	if string(code) != string(want) {
+		t.Fatalf("want %v, got %v", want, code)
+	}
+}
+
+func TestOpCode_OperandWidth(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		op   chunk.OpCode
+		want int
+	}{
+		{chunk.OP_CONSTANT, 1},
+		{chunk.OP_CONSTANT_LONG, 3},
+		{chunk.OP_JUMP, 2},
+		{chunk.OP_JUMP_IF_FALSE, 2},
+		{chunk.OP_GET_GLOBAL, 1},
+		{chunk.OP_CALL, 1},
+		{chunk.OP_GET_PROPERTY, 1},
+		{chunk.OP_SET_PROPERTY, 1},
+		{chunk.OP_BUILD_LIST, 2},
+		{chunk.OP_BUILD_MAP, 2},
+		{chunk.OP_GET_INDEX, 0},
+		{chunk.OP_ADD, 0},
+		{chunk.OP_RETURN, 0},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.op.OperandWidth(); got != tc.want {
+			t.Errorf("%s: want %d, got %d", tc.op, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package chunk
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// Disassemble writes a human readable listing of every instruction in a chunk,
+// in the style of clox:
+//
+//	== name ==
+//	0000    1 OP_CONSTANT         0 '1'
+//	0002    | OP_NEGATE
+//
+// Each line shows the instruction's offset, its source line
+// ("|" if it's the same as the previous instruction's), the opcode,
+// and its operands: a constant's index and value, a jump's target,
+// or the number of values a call or list takes.
+func Disassemble(w io.Writer, c *Chunk, name string) {
+	fmt.Fprintf(w, "== %s ==\n", name)
+	for offset := 0; offset < len(c.Code); {
+		offset = DisassembleInstruction(w, c, offset)
+	}
+}
+
+// DisassembleInstruction writes the instruction at offset
+// and returns the offset of the next instruction.
+func DisassembleInstruction(w io.Writer, c *Chunk, offset int) int {
+	fmt.Fprintf(w, "%04d ", offset)
+	if offset > 0 && c.Line(offset) == c.Line(offset-1) {
+		fmt.Fprint(w, "   | ")
+	} else {
+		fmt.Fprintf(w, "%4d ", c.Line(offset))
+	}
+
+	op := OpCode(c.Code[offset])
+	width := op.OperandWidth()
+	if offset+width >= len(c.Code) {
+		fmt.Fprintf(w, "%s (truncated)\n", op)
+		return len(c.Code)
+	}
+	operand := 0
+	for _, b := range c.Code[offset+1 : offset+1+width] {
+		operand = operand<<8 | int(b)
+	}
+
+	switch op {
+	case OP_CONSTANT, OP_CONSTANT_LONG, OP_GET_GLOBAL, OP_GET_PROPERTY, OP_SET_PROPERTY:
+		constant := "<invalid constant>"
+		if operand < len(c.constants) {
+			constant = value.Stringify(c.constants[operand])
+		}
+		fmt.Fprintf(w, "%-16s %4d '%s'\n", op, operand, constant)
+	case OP_JUMP, OP_JUMP_IF_FALSE, OP_JUMP_IF_TRUE:
+		fmt.Fprintf(w, "%-16s %4d -> %d\n", op, offset, offset+1+width+operand)
+	case OP_CALL, OP_BUILD_LIST, OP_BUILD_MAP:
+		fmt.Fprintf(w, "%-16s %4d\n", op, operand)
+	default:
+		fmt.Fprintf(w, "%s\n", op)
+	}
+	return offset + 1 + width
+}
@@ -0,0 +1,78 @@
+package chunk_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/taylorlowery/lox/internal/chunk"
+)
+
+func TestDisassemble_PrintsEveryInstruction(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	c.WriteConstant(1.5, 1)
+	c.WriteOp(chunk.OP_NEGATE, 1)
+	c.WriteOp(chunk.OP_JUMP_IF_FALSE, 2)
+	c.Write(0, 2)
+	c.Write(2, 2)
+	c.WriteOp(chunk.OP_POP, 2)
+	c.WriteConstant("str", 3)
+	c.WriteOp(chunk.OP_RETURN, 3)
+
+	var output bytes.Buffer
+	chunk.Disassemble(&output, c, "test")
+
+	want := `== test ==
+0000    1 OP_CONSTANT         0 '1.5'
+0002    | OP_NEGATE
+0003    2 OP_JUMP_IF_FALSE    3 -> 8
+0006    | OP_POP
+0007    3 OP_CONSTANT         1 'str'
+0009    | OP_RETURN
+`
+	if got := output.String(); got != want {
+		t.Fatal(cmp.Diff(want, got))
+	}
+}
+
+func TestDisassembleInstruction_LongConstant(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	for i := range 300 {
+		c.AddConstant(float64(i))
+	}
+	c.WriteConstant(299.0, 7)
+
+	var output bytes.Buffer
+	next := chunk.DisassembleInstruction(&output, c, 0)
+
+	want := "0000    7 OP_CONSTANT_LONG  299 '299'\n"
+	if got := output.String(); got != want {
+		t.Fatal(cmp.Diff(want, got))
+	}
+	if next != 4 {
+		t.Fatalf("expected next instruction at 4, got %d", next)
+	}
+}
+
+func TestDisassembleInstruction_TruncatedOperand(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	c.WriteOp(chunk.OP_JUMP, 1)
+	c.Write(0, 1)
+
+	var output bytes.Buffer
+	next := chunk.DisassembleInstruction(&output, c, 0)
+
+	want := "0000    1 OP_JUMP (truncated)\n"
+	if got := output.String(); got != want {
+		t.Fatal(cmp.Diff(want, got))
+	}
+	if next != len(c.Code) {
+		t.Fatalf("expected to stop at the end of the code, got %d", next)
+	}
+}
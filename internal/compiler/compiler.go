@@ -0,0 +1,308 @@
+// Package compiler lowers a parsed Lox program to bytecode for the vm package.
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/taylorlowery/lox/internal/ast"
+	"github.com/taylorlowery/lox/internal/chunk"
+	"github.com/taylorlowery/lox/internal/token"
+)
+
+// CompileError is returned for a tree that can't be lowered to bytecode,
+// such as one containing an ast.BadExpr.
+type CompileError struct {
+	Line    int
+	Message string
+}
+
+func (e CompileError) Error() string {
+	return e.Message
+}
+
+type compiler struct {
+	chunk *chunk.Chunk
+	// line is the source line of the innermost token seen so far,
+	// used for nodes such as literals that don't carry one.
+	line int
+}
+
+// Compile lowers an expression to a chunk that evaluates it
+// and returns its value.
+func Compile(expr ast.Expr) (c *chunk.Chunk, err error) {
+	comp := &compiler{
+		chunk: chunk.NewChunk(),
+		line:  1,
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			compileErr, ok := r.(CompileError)
+			if !ok {
+				panic(r)
+			}
+			c = nil
+			err = compileErr
+		}
+	}()
+
+	comp.expression(expr)
+	comp.chunk.WriteOp(chunk.OP_RETURN, comp.line)
+	return comp.chunk, nil
+}
+
+func (c *compiler) error(message string) {
+	panic(CompileError{
+		Line:    c.line,
+		Message: message,
+	})
+}
+
+func (c *compiler) emit(op chunk.OpCode) {
+	c.chunk.WriteOp(op, c.line)
+}
+
+func (c *compiler) expression(expr ast.Expr) {
+	switch expr := expr.(type) {
+	case *ast.Literal:
+		c.literal(expr)
+	case *ast.Grouping:
+		c.expression(expr.Expression)
+	case *ast.Unary:
+		c.unary(expr)
+	case *ast.Binary:
+		c.binary(expr)
+	case *ast.Conditional:
+		c.conditional(expr)
+	case *ast.Variable:
+		c.variable(expr)
+	case *ast.Call:
+		c.call(expr)
+	case *ast.ListLiteral:
+		c.list(expr)
+	case *ast.MapLiteral:
+		c.mapLiteral(expr)
+	case *ast.Index:
+		c.index(expr)
+	case *ast.SetIndex:
+		c.setIndex(expr)
+	case *ast.Property:
+		c.property(expr)
+	case *ast.SetProperty:
+		c.setProperty(expr)
+	case *ast.BadExpr:
+		if len(expr.Tokens) > 0 {
+			c.line = expr.Tokens[0].Line
+		}
+		c.error("cannot compile an invalid expression")
+	case nil:
+		c.error("cannot compile an empty expression")
+	default:
+		c.error(fmt.Sprintf("cannot compile %T", expr))
+	}
+}
+
+func (c *compiler) literal(expr *ast.Literal) {
+	switch v := expr.Value.(type) {
+	case nil:
+		c.emit(chunk.OP_NIL)
+	case bool:
+		if v {
+			c.emit(chunk.OP_TRUE)
+		} else {
+			c.emit(chunk.OP_FALSE)
+		}
+	case float64, string:
+		c.chunk.WriteConstant(v, c.line)
+	default:
+		c.error(fmt.Sprintf("cannot compile literal of type %T", v))
+	}
+}
+
+func (c *compiler) unary(expr *ast.Unary) {
+	c.line = expr.Operator.Line
+	c.expression(expr.Right)
+
+	c.line = expr.Operator.Line
+	switch expr.Operator.TokenType {
+	case token.BANG:
+		c.emit(chunk.OP_NOT)
+	case token.MINUS:
+		c.emit(chunk.OP_NEGATE)
+	default:
+		c.error(fmt.Sprintf("unknown unary operator '%s'", expr.Operator.Lexeme))
+	}
+}
+
+func (c *compiler) binary(expr *ast.Binary) {
+	c.line = expr.Operator.Line
+	c.expression(expr.Left)
+
+	// the comma operator discards its left operand
+	if expr.Operator.TokenType == token.COMMA {
+		c.line = expr.Operator.Line
+		c.emit(chunk.OP_POP)
+		c.expression(expr.Right)
+		return
+	}
+
+	c.expression(expr.Right)
+
+	c.line = expr.Operator.Line
+	switch expr.Operator.TokenType {
+	case token.EQUAL_EQUAL:
+		c.emit(chunk.OP_EQUAL)
+	case token.BANG_EQUAL:
+		c.emit(chunk.OP_EQUAL)
+		c.emit(chunk.OP_NOT)
+	case token.GREATER:
+		c.emit(chunk.OP_GREATER)
+	case token.GREATER_EQUAL:
+		c.emit(chunk.OP_GREATER_EQUAL)
+	case token.LESS:
+		c.emit(chunk.OP_LESS)
+	case token.LESS_EQUAL:
+		c.emit(chunk.OP_LESS_EQUAL)
+	case token.PLUS:
+		c.emit(chunk.OP_ADD)
+	case token.MINUS:
+		c.emit(chunk.OP_SUBTRACT)
+	case token.STAR:
+		c.emit(chunk.OP_MULTIPLY)
+	case token.SLASH:
+		c.emit(chunk.OP_DIVIDE)
+	default:
+		c.error(fmt.Sprintf("unknown binary operator '%s'", expr.Operator.Lexeme))
+	}
+}
+
+// conditional only evaluates the branch the condition picks, as in C.
+func (c *compiler) conditional(expr *ast.Conditional) {
+	c.expression(expr.Condition)
+
+	elseJump := c.emitJump(chunk.OP_JUMP_IF_FALSE)
+	c.emit(chunk.OP_POP)
+	c.expression(expr.ThenBranch)
+	endJump := c.emitJump(chunk.OP_JUMP)
+
+	c.patchJump(elseJump)
+	c.emit(chunk.OP_POP)
+	c.expression(expr.ElseBranch)
+	c.patchJump(endJump)
+}
+
+func (c *compiler) variable(expr *ast.Variable) {
+	c.line = expr.Name.Line
+	c.emitName(chunk.OP_GET_GLOBAL, expr.Name, "global")
+}
+
+// emitName emits op with the index of the constant holding name as its operand.
+// kind says what the name refers to, for the error when there are too many constants.
+func (c *compiler) emitName(op chunk.OpCode, name token.Token, kind string) {
+	index := c.chunk.AddConstant(name.Lexeme)
+	if index > 0xff {
+		c.error(fmt.Sprintf("too many constants to refer to a %s by name", kind))
+	}
+	c.emit(op)
+	c.chunk.Write(byte(index), c.line)
+}
+
+func (c *compiler) call(expr *ast.Call) {
+	c.expression(expr.Callee)
+	for _, arg := range expr.Arguments {
+		c.expression(arg)
+	}
+
+	c.line = expr.Paren.Line
+	if len(expr.Arguments) > 0xff {
+		c.error("Can't have more than 255 arguments")
+	}
+	c.emit(chunk.OP_CALL)
+	c.chunk.Write(byte(len(expr.Arguments)), c.line)
+}
+
+func (c *compiler) list(expr *ast.ListLiteral) {
+	c.line = expr.Bracket.Line
+	for _, element := range expr.Elements {
+		c.expression(element)
+	}
+
+	c.line = expr.Bracket.Line
+	if len(expr.Elements) > 0xffff {
+		c.error("too many elements in a list literal")
+	}
+	c.emit(chunk.OP_BUILD_LIST)
+	c.chunk.Write(byte(len(expr.Elements)>>8), c.line)
+	c.chunk.Write(byte(len(expr.Elements)), c.line)
+}
+
+func (c *compiler) mapLiteral(expr *ast.MapLiteral) {
+	c.line = expr.Brace.Line
+	if len(expr.Keys) != len(expr.Values) {
+		c.error(fmt.Sprintf("map literal has %d keys but %d values", len(expr.Keys), len(expr.Values)))
+		return
+	}
+	for i, key := range expr.Keys {
+		c.expression(key)
+		c.expression(expr.Values[i])
+	}
+
+	c.line = expr.Brace.Line
+	if len(expr.Keys) > 0xffff {
+		c.error("too many entries in a map literal")
+	}
+	c.emit(chunk.OP_BUILD_MAP)
+	c.chunk.Write(byte(len(expr.Keys)>>8), c.line)
+	c.chunk.Write(byte(len(expr.Keys)), c.line)
+}
+
+func (c *compiler) index(expr *ast.Index) {
+	c.expression(expr.Object)
+	c.expression(expr.Index)
+
+	c.line = expr.Bracket.Line
+	c.emit(chunk.OP_GET_INDEX)
+}
+
+func (c *compiler) setIndex(expr *ast.SetIndex) {
+	c.expression(expr.Object)
+	c.expression(expr.Index)
+	c.expression(expr.Value)
+
+	c.line = expr.Bracket.Line
+	c.emit(chunk.OP_SET_INDEX)
+}
+
+func (c *compiler) property(expr *ast.Property) {
+	c.expression(expr.Object)
+
+	c.line = expr.Name.Line
+	c.emitName(chunk.OP_GET_PROPERTY, expr.Name, "property")
+}
+
+func (c *compiler) setProperty(expr *ast.SetProperty) {
+	c.expression(expr.Object)
+	c.expression(expr.Value)
+
+	c.line = expr.Name.Line
+	c.emitName(chunk.OP_SET_PROPERTY, expr.Name, "property")
+}
+
+// emitJump emits a jump instruction with a placeholder offset,
+// returning the offset of the placeholder for patchJump.
+func (c *compiler) emitJump(op chunk.OpCode) int {
+	c.emit(op)
+	c.chunk.Write(0xff, c.line)
+	c.chunk.Write(0xff, c.line)
+	return len(c.chunk.Code) - 2
+}
+
+// patchJump points the jump whose placeholder is at offset to the end of the code.
+func (c *compiler) patchJump(offset int) {
+	jump := len(c.chunk.Code) - offset - 2
+	if jump > 0xffff {
+		c.error("too much code to jump over")
+	}
+	c.chunk.Code[offset] = byte(jump >> 8)
+	c.chunk.Code[offset+1] = byte(jump)
+}
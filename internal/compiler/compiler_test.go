@@ -0,0 +1,162 @@
+package compiler_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/ast"
+	"github.com/taylorlowery/lox/internal/chunk"
+	"github.com/taylorlowery/lox/internal/compiler"
+	"github.com/taylorlowery/lox/internal/parser"
+	"github.com/taylorlowery/lox/internal/scanner"
+)
+
+func parse(t *testing.T, source string) ast.Expr {
+	t.Helper()
+	tokens, scanErr := scanner.NewScanner(source).ScanTokens()
+	if scanErr != nil {
+		t.Fatal(scanErr)
+	}
+	expr, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expr
+}
+
+func op(o chunk.OpCode) byte {
+	return byte(o)
+}
+
+func TestCompile_EmitsExpectedCode(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name      string
+		source    string
+		code      []byte
+		constants []any
+	}{
+		{
+			name:   "literals",
+			source: "nil",
+			code:   []byte{op(chunk.OP_NIL), op(chunk.OP_RETURN)},
+		},
+		{
+			name:      "arithmetic",
+			source:    "1 + 2 * 1",
+			code:      []byte{op(chunk.OP_CONSTANT), 0, op(chunk.OP_CONSTANT), 1, op(chunk.OP_CONSTANT), 0, op(chunk.OP_MULTIPLY), op(chunk.OP_ADD), op(chunk.OP_RETURN)},
+			constants: []any{1.0, 2.0},
+		},
+		{
+			name:   "not equal",
+			source: "true != false",
+			code:   []byte{op(chunk.OP_TRUE), op(chunk.OP_FALSE), op(chunk.OP_EQUAL), op(chunk.OP_NOT), op(chunk.OP_RETURN)},
+		},
+		{
+			name:      "unary",
+			source:    "-(!\"a\")",
+			code:      []byte{op(chunk.OP_CONSTANT), 0, op(chunk.OP_NOT), op(chunk.OP_NEGATE), op(chunk.OP_RETURN)},
+			constants: []any{"a"},
+		},
+		{
+			name:   "comma",
+			source: "true, false",
+			code:   []byte{op(chunk.OP_TRUE), op(chunk.OP_POP), op(chunk.OP_FALSE), op(chunk.OP_RETURN)},
+		},
+		{
+			name:   "conditional",
+			source: "nil ? true : false",
+			code: []byte{
+				op(chunk.OP_NIL),
+				op(chunk.OP_JUMP_IF_FALSE), 0, 5,
+				op(chunk.OP_POP),
+				op(chunk.OP_TRUE),
+				op(chunk.OP_JUMP), 0, 2,
+				op(chunk.OP_POP),
+				op(chunk.OP_FALSE),
+				op(chunk.OP_RETURN),
+			},
+		},
+		{
+			name:      "call",
+			source:    "f(1, 2)",
+			code:      []byte{op(chunk.OP_GET_GLOBAL), 0, op(chunk.OP_CONSTANT), 1, op(chunk.OP_CONSTANT), 2, op(chunk.OP_CALL), 2, op(chunk.OP_RETURN)},
+			constants: []any{"f", 1.0, 2.0},
+		},
+		{
+			name:      "list and index",
+			source:    "[nil, 1][1]",
+			code:      []byte{op(chunk.OP_NIL), op(chunk.OP_CONSTANT), 0, op(chunk.OP_BUILD_LIST), 0, 2, op(chunk.OP_CONSTANT), 0, op(chunk.OP_GET_INDEX), op(chunk.OP_RETURN)},
+			constants: []any{1.0},
+		},
+		{
+			name:      "map",
+			source:    `{"a": 1, nil: "a"}`,
+			code:      []byte{op(chunk.OP_CONSTANT), 0, op(chunk.OP_CONSTANT), 1, op(chunk.OP_NIL), op(chunk.OP_CONSTANT), 0, op(chunk.OP_BUILD_MAP), 0, 2, op(chunk.OP_RETURN)},
+			constants: []any{"a", 1.0},
+		},
+		{
+			name:   "set index",
+			source: "[][nil] = true",
+			code:   []byte{op(chunk.OP_BUILD_LIST), 0, 0, op(chunk.OP_NIL), op(chunk.OP_TRUE), op(chunk.OP_SET_INDEX), op(chunk.OP_RETURN)},
+		},
+		{
+			name:      "properties",
+			source:    "p.x = p.y",
+			code:      []byte{op(chunk.OP_GET_GLOBAL), 0, op(chunk.OP_GET_GLOBAL), 0, op(chunk.OP_GET_PROPERTY), 1, op(chunk.OP_SET_PROPERTY), 2, op(chunk.OP_RETURN)},
+			constants: []any{"p", "y", "x"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := compiler.Compile(parse(t, tc.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(c.Code, tc.code) {
+				t.Errorf("want code %v, got %v", tc.code, c.Code)
+			}
+			if !slices.Equal(c.Constants(), tc.constants) {
+				t.Errorf("want constants %v, got %v", tc.constants, c.Constants())
+			}
+		})
+	}
+}
+
+func TestCompile_RecordsLines(t *testing.T) {
+	t.Parallel()
+
+	c, err := compiler.Compile(parse(t, "1 +\n2 *\n3"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// CONSTANT 0, CONSTANT 1, CONSTANT 2, MULTIPLY, ADD, RETURN
+	wantLines := map[int]int{0: 1, 2: 2, 4: 2, 6: 2, 7: 1}
+	for offset, want := range wantLines {
+		if got := c.Line(offset); got != want {
+			t.Errorf("offset %d: want line %d, got %d", offset, want, got)
+		}
+	}
+}
+
+func TestCompile_RejectsBadExpressions(t *testing.T) {
+	t.Parallel()
+
+	tokens, scanErr := scanner.NewScanner("1 +\n)").ScanTokens()
+	if scanErr != nil {
+		t.Fatal(scanErr)
+	}
+	exprs, _ := parser.NewParser(tokens).ParseTolerant()
+
+	_, err := compiler.Compile(exprs[0])
+	var compileErr compiler.CompileError
+	if !errors.As(err, &compileErr) {
+		t.Fatalf("expected a CompileError, got %v", err)
+	}
+	if compileErr.Line != 2 {
+		t.Errorf("expected error on line 2, got %d", compileErr.Line)
+	}
+}
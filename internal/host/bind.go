@@ -0,0 +1,233 @@
+package host
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+var errorType = reflect.TypeFor[error]()
+
+// WithFunc implements lox.WithFunc.
+func WithFunc(name string, fn any) Option {
+	return func(c *Config) error {
+		native, err := bindFunc(name, fn)
+		if err != nil {
+			return err
+		}
+		return c.define(native)
+	}
+}
+
+// bindFunc wraps fn in a native that converts between Lox and Go values.
+func bindFunc(name string, fn any) (*value.Native, error) {
+	rv := reflect.ValueOf(fn)
+	if rv.Kind() != reflect.Func || rv.IsNil() {
+		return nil, fmt.Errorf("native function %s is %T, not a function", name, fn)
+	}
+	ft := rv.Type()
+	switch {
+	case ft.NumOut() > 2,
+		ft.NumOut() == 2 && ft.Out(1) != errorType:
+		return nil, fmt.Errorf("native function %s must return at most a value and an error", name)
+	}
+
+	arity := ft.NumIn()
+	if ft.IsVariadic() {
+		arity = -1
+	}
+	// paramType is the type of the ith argument, following the variadic parameter
+	paramType := func(i int) reflect.Type {
+		if ft.IsVariadic() && i >= ft.NumIn()-1 {
+			return ft.In(ft.NumIn() - 1).Elem()
+		}
+		return ft.In(i)
+	}
+
+	return &value.Native{
+		Name:  name,
+		Arity: arity,
+		Fn: func(alloc value.Allocator, args []any) (result any, err error) {
+			if ft.IsVariadic() && len(args) < ft.NumIn()-1 {
+				return nil, fmt.Errorf("Expected at least %d arguments but got %d.", ft.NumIn()-1, len(args))
+			}
+			in := make([]reflect.Value, len(args))
+			for i, arg := range args {
+				in[i] = reflect.New(paramType(i)).Elem()
+				if err := setValue(in[i], arg); err != nil {
+					return nil, fmt.Errorf("Bad argument %d to %s: %w.", i+1, name, err)
+				}
+			}
+
+			defer func() {
+				if r := recover(); r != nil {
+					result = nil
+					err = fmt.Errorf("Native function %s failed: %v.", name, r)
+				}
+			}()
+			return results(alloc, name, rv.Call(in))
+		},
+	}, nil
+}
+
+// results converts what a bound function returned to a Lox value and an error.
+func results(alloc value.Allocator, name string, out []reflect.Value) (any, error) {
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, err
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	raw := out[0].Interface()
+	v, err := ToValue(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Bad result from %s: %w.", name, err)
+	}
+	if err := allocateResult(alloc, raw, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// allocateResult accounts for v, the Lox value a host function's result raw
+// converted to, as the VM does for the values natives build.
+// A *List or *Map raw is one the program already holds, so isn't counted again.
+func allocateResult(alloc value.Allocator, raw any, v any) error {
+	switch raw.(type) {
+	case *value.List, *value.Map:
+		return nil
+	}
+	return alloc.Allocate(size(v, map[any]bool{}))
+}
+
+// size returns the bytes v takes, counting each list or map in it once.
+func size(v any, seen map[any]bool) int64 {
+	if seen[v] {
+		return 0
+	}
+	switch v := v.(type) {
+	case string:
+		return int64(len(v))
+	case *value.List:
+		seen[v] = true
+		n := int64(value.ListSize + len(v.Elements)*value.SlotSize)
+		for _, e := range v.Elements {
+			n += size(e, seen)
+		}
+		return n
+	case *value.Map:
+		seen[v] = true
+		n := int64(value.MapSize + v.Len()*value.EntrySize)
+		for _, key := range v.Keys() {
+			element, _ := v.Get(key)
+			n += size(key, seen) + size(element, seen)
+		}
+		return n
+	default:
+		return 0
+	}
+}
+
+// BindType implements lox.BindType.
+func BindType[T any]() Option {
+	return func(c *Config) error {
+		t := reflect.TypeFor[T]()
+		if t.Kind() != reflect.Struct {
+			return fmt.Errorf("bound type %s is not a struct", t)
+		}
+		fields := exportedFields(t)
+		return c.define(&value.Native{
+			Name:  t.Name(),
+			Arity: len(fields),
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				ptr := reflect.New(t)
+				for i, arg := range args {
+					if err := setValue(ptr.Elem().Field(fields[i].Index[0]), arg); err != nil {
+						return nil, fmt.Errorf("Bad argument %d to %s: %w.", i+1, t.Name(), err)
+					}
+				}
+				if err := alloc.Allocate(int64(t.Size())); err != nil {
+					return nil, err
+				}
+				return instance{ptr.Interface()}, nil
+			},
+		})
+	}
+}
+
+// exportedFields returns the exported fields declared in the struct type t.
+func exportedFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := range t.NumField() {
+		if f := t.Field(i); f.IsExported() {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// instance is a Lox instance of a Go struct. ptr is a pointer to the struct,
+// so that instances of the same struct are equal, however often it's converted.
+type instance struct {
+	ptr any
+}
+
+// isInstanceType reports whether a Go value of type t converts to an instance:
+// whether it's a pointer to a named struct type.
+func isInstanceType(t reflect.Type) bool {
+	return t.Kind() == reflect.Pointer && t.Elem().Kind() == reflect.Struct && t.Elem().Name() != ""
+}
+
+// field returns the exported field called name.
+func (o instance) field(name string) (reflect.Value, bool) {
+	s := reflect.ValueOf(o.ptr).Elem()
+	f, ok := s.Type().FieldByName(name)
+	if !ok || !f.IsExported() || len(f.Index) != 1 {
+		return reflect.Value{}, false
+	}
+	return s.Field(f.Index[0]), true
+}
+
+// GetProperty returns the value of an exported field, or an exported method as a function.
+func (o instance) GetProperty(alloc value.Allocator, name string) (any, error) {
+	if f, ok := o.field(name); ok {
+		raw := f.Interface()
+		v, err := ToValue(raw)
+		if err != nil {
+			return nil, fmt.Errorf("Bad value of %s.%s: %w.", o.className(), name, err)
+		}
+		if err := allocateResult(alloc, raw, v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+	if m := reflect.ValueOf(o.ptr).MethodByName(name); m.IsValid() {
+		return bindFunc(name, m.Interface())
+	}
+	return nil, fmt.Errorf("Undefined property '%s'.", name)
+}
+
+// SetProperty sets an exported field to v.
+func (o instance) SetProperty(name string, v any) error {
+	f, ok := o.field(name)
+	if !ok {
+		return fmt.Errorf("Undefined field '%s'.", name)
+	}
+	if err := setValue(f, v); err != nil {
+		return fmt.Errorf("Bad value for %s.%s: %w.", o.className(), name, err)
+	}
+	return nil
+}
+
+func (o instance) className() string {
+	return reflect.TypeOf(o.ptr).Elem().Name()
+}
+
+// String formats an instance the way Lox prints it, e.g. Point instance.
+func (o instance) String() string {
+	return o.className() + " instance"
+}
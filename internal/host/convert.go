@@ -0,0 +1,288 @@
+package host
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// ConversionError is returned when a value has no equivalent in the other language,
+// such as a Go channel, or the Lox number 1.5 as a Go int.
+type ConversionError struct {
+	// Value is the value that couldn't be converted.
+	Value any
+	// To describes what it was being converted to.
+	To string
+	// Reason explains why it couldn't be converted.
+	Reason string
+}
+
+func (e ConversionError) Error() string {
+	return fmt.Sprintf("cannot convert %#v to %s: %s", e.Value, e.To, e.Reason)
+}
+
+// maxSafeInteger is the largest integer such that it and every smaller integer
+// can be represented exactly as a float64.
+const maxSafeInteger = 1 << 53
+
+// ToValue implements lox.ToValue.
+func ToValue(v any) (any, error) {
+	switch v.(type) {
+	case nil:
+		return nil, nil
+	case *value.List, *value.Map, *value.Native, value.Object:
+		return v, nil
+	}
+	rv := reflect.ValueOf(v)
+	if isInstanceType(rv.Type()) {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return instance{v}, nil
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := rv.Int()
+		if n > maxSafeInteger || n < -maxSafeInteger {
+			return nil, ConversionError{Value: v, To: "a Lox number", Reason: "integer is too large to represent exactly"}
+		}
+		return float64(n), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n := rv.Uint()
+		if n > maxSafeInteger {
+			return nil, ConversionError{Value: v, To: "a Lox number", Reason: "integer is too large to represent exactly"}
+		}
+		return float64(n), nil
+	case reflect.Slice, reflect.Array:
+		elements := make([]any, rv.Len())
+		for i := range elements {
+			element, err := ToValue(rv.Index(i).Interface())
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return value.NewList(elements...), nil
+	case reflect.Map:
+		return toMap(v, rv)
+	default:
+		return nil, ConversionError{Value: v, To: "a Lox value", Reason: fmt.Sprintf("Lox has no equivalent of %s", rv.Kind())}
+	}
+}
+
+// toMap converts the Go map v, whose reflect.Value is rv, to a Lox map.
+func toMap(v any, rv reflect.Value) (any, error) {
+	type entry struct{ key, value any }
+	entries := make([]entry, 0, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		key, err := ToValue(iter.Key().Interface())
+		if err != nil {
+			return nil, err
+		}
+		if err := value.CheckKey(key); err != nil {
+			return nil, ConversionError{Value: v, To: "a Lox map", Reason: fmt.Sprintf("key %s can't be a map key", value.Stringify(key))}
+		}
+		val, err := ToValue(iter.Value().Interface())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry{key, val})
+	}
+	slices.SortFunc(entries, func(a, b entry) int {
+		return compareKeys(a.key, b.key)
+	})
+
+	m := value.NewMap()
+	for _, e := range entries {
+		if _, ok := m.Get(e.key); ok {
+			return nil, ConversionError{Value: v, To: "a Lox map", Reason: fmt.Sprintf("more than one key converts to %s", value.Stringify(e.key))}
+		}
+		if err := m.Set(e.key, e.value); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// compareKeys orders map keys: nil, then false and true,
+// then numbers in increasing order, then strings.
+func compareKeys(a, b any) int {
+	rank := func(key any) int {
+		switch key.(type) {
+		case nil:
+			return 0
+		case bool:
+			return 1
+		case float64:
+			return 2
+		default:
+			return 3
+		}
+	}
+	if c := cmp.Compare(rank(a), rank(b)); c != 0 {
+		return c
+	}
+	switch a := a.(type) {
+	case bool:
+		if a == b.(bool) {
+			return 0
+		}
+		if a {
+			return 1
+		}
+		return -1
+	case float64:
+		return cmp.Compare(a, b.(float64))
+	case string:
+		return strings.Compare(a, b.(string))
+	default:
+		return 0
+	}
+}
+
+// FromValue implements lox.FromValue.
+func FromValue[T any](v any) (T, error) {
+	var result T
+	err := setValue(reflect.ValueOf(&result).Elem(), v)
+	return result, err
+}
+
+// setValue converts a Lox value as FromValue does, storing it in out.
+// out is left unchanged if v can't be converted.
+func setValue(out reflect.Value, v any) error {
+	to := out.Type()
+	fail := func(reason string) error {
+		return ConversionError{Value: v, To: to.String(), Reason: reason}
+	}
+
+	if v == nil {
+		switch to.Kind() {
+		case reflect.Interface, reflect.Pointer, reflect.Slice, reflect.Map:
+			out.SetZero()
+			return nil
+		default:
+			return fail("nil has no value of this type")
+		}
+	}
+
+	if o, ok := v.(instance); ok {
+		ptr := reflect.ValueOf(o.ptr)
+		switch {
+		case ptr.Type().AssignableTo(to):
+			out.Set(ptr)
+			return nil
+		case ptr.Type().Elem() == to:
+			out.Set(ptr.Elem())
+			return nil
+		}
+	}
+
+	switch to.Kind() {
+	case reflect.Interface:
+		if !reflect.TypeOf(v).Implements(to) {
+			return fail("value doesn't implement the interface")
+		}
+		out.Set(reflect.ValueOf(v))
+	case reflect.Pointer:
+		if reflect.TypeOf(v) != to {
+			return fail("not a value of this type")
+		}
+		out.Set(reflect.ValueOf(v))
+	case reflect.Slice, reflect.Array:
+		l, ok := v.(*value.List)
+		if !ok {
+			return fail("not a list")
+		}
+		var elements reflect.Value
+		switch {
+		case to.Kind() == reflect.Slice:
+			elements = reflect.MakeSlice(to, len(l.Elements), len(l.Elements))
+		case to.Len() != len(l.Elements):
+			return fail(fmt.Sprintf("list has %d elements, not %d", len(l.Elements), to.Len()))
+		default:
+			elements = reflect.New(to).Elem()
+		}
+		for i, e := range l.Elements {
+			if err := setValue(elements.Index(i), e); err != nil {
+				return err
+			}
+		}
+		out.Set(elements)
+	case reflect.Map:
+		m, ok := v.(*value.Map)
+		if !ok {
+			return fail("not a map")
+		}
+		result := reflect.MakeMapWithSize(to, m.Len())
+		for _, k := range m.Keys() {
+			key := reflect.New(to.Key()).Elem()
+			if err := setValue(key, k); err != nil {
+				return err
+			}
+			val := reflect.New(to.Elem()).Elem()
+			element, _ := m.Get(k)
+			if err := setValue(val, element); err != nil {
+				return err
+			}
+			result.SetMapIndex(key, val)
+		}
+		out.Set(result)
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return fail("not a boolean")
+		}
+		out.SetBool(b)
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return fail("not a string")
+		}
+		out.SetString(s)
+	case reflect.Float32, reflect.Float64:
+		n, ok := v.(float64)
+		if !ok {
+			return fail("not a number")
+		}
+		out.SetFloat(n)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.(float64)
+		if !ok {
+			return fail("not a number")
+		}
+		if n != math.Trunc(n) {
+			return fail("not a whole number")
+		}
+		if n < math.MinInt64 || n >= math.MaxInt64 || out.OverflowInt(int64(n)) {
+			return fail("out of range")
+		}
+		out.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n, ok := v.(float64)
+		if !ok {
+			return fail("not a number")
+		}
+		if n != math.Trunc(n) {
+			return fail("not a whole number")
+		}
+		if n < 0 || n >= math.MaxUint64 || out.OverflowUint(uint64(n)) {
+			return fail("out of range")
+		}
+		out.SetUint(uint64(n))
+	default:
+		return fail(fmt.Sprintf("Lox has no equivalent of %s", to.Kind()))
+	}
+	return nil
+}
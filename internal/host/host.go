@@ -0,0 +1,276 @@
+// Package host holds what a Go program configures to run Lox:
+// the natives and types it gives programs, the limits they run under,
+// and the conversions between Go and Lox values.
+// The lox package makes it public, and golox's command line runs programs with it.
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/taylorlowery/lox/internal/ast"
+	"github.com/taylorlowery/lox/internal/chunk"
+	"github.com/taylorlowery/lox/internal/compiler"
+	"github.com/taylorlowery/lox/internal/optimizer"
+	"github.com/taylorlowery/lox/internal/parser"
+	"github.com/taylorlowery/lox/internal/peephole"
+	"github.com/taylorlowery/lox/internal/scanner"
+	"github.com/taylorlowery/lox/internal/stdlib"
+	"github.com/taylorlowery/lox/internal/token"
+	"github.com/taylorlowery/lox/internal/value"
+	"github.com/taylorlowery/lox/internal/vm"
+)
+
+// Config is how programs are compiled and run.
+type Config struct {
+	// Optimize turns on the optimizer and the peephole optimizer. It's on by default.
+	Optimize bool
+	// Context, if set, stops programs when it's done.
+	Context context.Context
+	// MaxSteps, MaxCallDepth and MaxMemory, if positive, are the VM's limits.
+	MaxSteps     int
+	MaxCallDepth int
+	MaxMemory    int64
+	// Memory counts what programs allocate against MaxMemory.
+	Memory *vm.Memory
+	// Globals holds the natives and constants programs can refer to by name.
+	Globals map[string]any
+	// random is the source of the standard library's random numbers
+	random *stdlib.Source
+}
+
+// Option configures a Config.
+type Option func(c *Config) error
+
+// NewConfig returns a Config with the builtins and the standard library,
+// and the optimizer on, changed by opts.
+func NewConfig(opts ...Option) (*Config, error) {
+	c := &Config{
+		Optimize: true,
+		Globals:  map[string]any{},
+		random:   stdlib.NewSource(rand.Uint64()),
+	}
+	for _, n := range builtins {
+		c.Globals[n.Name] = n
+	}
+	for _, m := range stdlib.Modules(c.random) {
+		for _, constant := range m.Constants {
+			c.Globals[constant.Name] = constant.Value
+		}
+		for _, f := range m.Functions {
+			c.Globals[f.Name] = f.Native()
+		}
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	memory, err := vm.NewMemory(c.MaxMemory)
+	if err != nil {
+		return nil, err
+	}
+	c.Memory = memory
+	return c, nil
+}
+
+// builtins are the natives every Config starts with,
+// besides the standard library.
+var builtins = []*value.Native{
+	{
+		Name:  "clock",
+		Arity: 0,
+		Fn: func(alloc value.Allocator, args []any) (any, error) {
+			return float64(time.Now().UnixNano()) / float64(time.Second), nil
+		},
+	},
+}
+
+// WithOptimizer turns the optimizers on or off.
+func WithOptimizer(enabled bool) Option {
+	return func(c *Config) error {
+		c.Optimize = enabled
+		return nil
+	}
+}
+
+// WithContext sets the context that stops programs.
+func WithContext(ctx context.Context) Option {
+	return func(c *Config) error {
+		if ctx == nil {
+			return errors.New("nil context")
+		}
+		c.Context = ctx
+		return nil
+	}
+}
+
+// WithMaxSteps sets the number of instructions a program may run.
+func WithMaxSteps(n int) Option {
+	return func(c *Config) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid step limit %d", n)
+		}
+		c.MaxSteps = n
+		return nil
+	}
+}
+
+// WithMaxCallDepth sets the number of frames that may run at once.
+func WithMaxCallDepth(n int) Option {
+	return func(c *Config) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid call depth limit %d", n)
+		}
+		c.MaxCallDepth = n
+		return nil
+	}
+}
+
+// WithMaxMemory sets the number of bytes programs may allocate.
+func WithMaxMemory(n int64) Option {
+	return func(c *Config) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid memory limit %d", n)
+		}
+		c.MaxMemory = n
+		return nil
+	}
+}
+
+// WithNative gives programs fn as a native called name, converting its result with ToValue.
+// A panic in fn is raised as a runtime error.
+func WithNative(name string, arity int, fn func(args []any) (any, error)) Option {
+	return func(c *Config) error {
+		if fn == nil {
+			return fmt.Errorf("nil native function %s", name)
+		}
+		return c.define(&value.Native{
+			Name:  name,
+			Arity: arity,
+			Fn: func(alloc value.Allocator, args []any) (result any, err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						result = nil
+						err = fmt.Errorf("Native function %s failed: %v.", name, r)
+					}
+				}()
+				raw, err := fn(args)
+				if err != nil {
+					return nil, err
+				}
+				result, err = ToValue(raw)
+				if err != nil {
+					return nil, err
+				}
+				if err := allocateResult(alloc, raw, result); err != nil {
+					return nil, err
+				}
+				return result, nil
+			},
+		})
+	}
+}
+
+// WithSeed seeds the standard library's random numbers.
+func WithSeed(seed uint64) Option {
+	return func(c *Config) error {
+		c.random.Seed(seed)
+		return nil
+	}
+}
+
+// define makes a native available to programs under its name.
+func (c *Config) define(native *value.Native) error {
+	if !IsIdentifier(native.Name) {
+		return fmt.Errorf("invalid native function name %q", native.Name)
+	}
+	if native.Arity < -1 || native.Arity > 255 {
+		return fmt.Errorf("invalid arity %d for native function %s", native.Arity, native.Name)
+	}
+	c.Globals[native.Name] = native
+	return nil
+}
+
+// IsIdentifier reports whether name scans as a single Lox identifier.
+func IsIdentifier(name string) bool {
+	tokens, err := scanner.NewScanner(name).ScanTokens()
+	return err == nil && len(tokens) == 2 && tokens[0].TokenType == token.IDENTIFIER
+}
+
+// SyntaxError is returned for source that isn't a valid Lox program.
+// Line is 0 if the error couldn't be placed.
+type SyntaxError struct {
+	Line    int
+	Message string
+}
+
+func (e SyntaxError) Error() string {
+	if e.Line == 0 {
+		return e.Message
+	}
+	return fmt.Sprintf("[line %d] %s", e.Line, e.Message)
+}
+
+// Parse scans and parses source, returning a SyntaxError if it's invalid.
+func Parse(source string) (ast.Expr, error) {
+	tokens, scanErr := scanner.NewScanner(source).ScanTokens()
+	if scanErr != nil {
+		return nil, SyntaxError{Line: scanErr.Line, Message: scanErr.Message}
+	}
+	expr, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		// the line is the first error's, when there are several
+		syntaxErr := SyntaxError{Message: err.Error()}
+		var parseErr parser.ParseError
+		if errors.As(err, &parseErr) {
+			syntaxErr.Line = parseErr.Line
+		}
+		return nil, syntaxErr
+	}
+	return expr, nil
+}
+
+// OptimizeTree optimizes a parsed program, if the optimizers are on.
+func (c *Config) OptimizeTree(expr ast.Expr) ast.Expr {
+	switch {
+	case c.Optimize && c.MaxMemory > 0:
+		// folded strings would escape the memory limit
+		return optimizer.OptimizeWithoutConcatenation(expr)
+	case c.Optimize:
+		return optimizer.Optimize(expr)
+	default:
+		return expr
+	}
+}
+
+// Compile compiles an optimized program to bytecode.
+func (c *Config) Compile(expr ast.Expr) (*chunk.Chunk, error) {
+	code, err := compiler.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if c.Optimize {
+		code = peephole.Optimize(code)
+	}
+	return code, nil
+}
+
+// NewVM returns a VM that runs programs with the Config's globals, memory and limits,
+// and any extra options.
+func (c *Config) NewVM(extra ...vm.Option) (*vm.VM, error) {
+	opts := []vm.Option{vm.WithMemory(c.Memory), vm.WithGlobals(c.Globals)}
+	if c.Context != nil {
+		opts = append(opts, vm.WithContext(c.Context))
+	}
+	if c.MaxSteps > 0 {
+		opts = append(opts, vm.WithMaxSteps(c.MaxSteps))
+	}
+	if c.MaxCallDepth > 0 {
+		opts = append(opts, vm.WithMaxCallDepth(c.MaxCallDepth))
+	}
+	return vm.NewVM(append(opts, extra...)...)
+}
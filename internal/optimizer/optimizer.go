@@ -0,0 +1,266 @@
+// Package optimizer rewrites parsed Lox programs into cheaper equivalents
+// before they are run.
+package optimizer
+
+import (
+	"github.com/taylorlowery/lox/internal/ast"
+	"github.com/taylorlowery/lox/internal/token"
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// Optimize returns a copy of expr with constant subexpressions folded
+// and identities such as x * 1 simplified. The input tree isn't modified.
+//
+// The result behaves exactly like the input: it produces the same value,
+// and raises the same runtime error from the same line.
+// An operation that would raise an error, such as "a" - 1, is left for the
+// program to raise when it runs.
+func Optimize(expr ast.Expr) ast.Expr {
+	return optimizer{foldConcatenation: true}.optimize(expr)
+}
+
+// OptimizeWithoutConcatenation is Optimize, except that string concatenation
+// isn't folded, so that the strings it builds are allocated, and accounted for,
+// when the program runs. Use it for programs run with a memory limit.
+func OptimizeWithoutConcatenation(expr ast.Expr) ast.Expr {
+	return optimizer{}.optimize(expr)
+}
+
+// optimizer holds the settings of an optimization pass.
+type optimizer struct {
+	foldConcatenation bool
+}
+
+func (o optimizer) optimize(expr ast.Expr) ast.Expr {
+	switch expr := expr.(type) {
+	case *ast.Grouping:
+		return o.grouping(expr)
+	case *ast.Unary:
+		return o.unary(expr)
+	case *ast.Binary:
+		return o.binary(expr)
+	case *ast.Conditional:
+		return o.conditional(expr)
+	case *ast.Call:
+		return o.call(expr)
+	case *ast.ListLiteral:
+		return &ast.ListLiteral{Bracket: expr.Bracket, Elements: o.optimizeAll(expr.Elements)}
+	case *ast.MapLiteral:
+		return &ast.MapLiteral{Brace: expr.Brace, Keys: o.optimizeAll(expr.Keys), Values: o.optimizeAll(expr.Values)}
+	case *ast.Index:
+		return &ast.Index{Object: o.optimize(expr.Object), Bracket: expr.Bracket, Index: o.optimize(expr.Index)}
+	case *ast.SetIndex:
+		return &ast.SetIndex{
+			Object:  o.optimize(expr.Object),
+			Bracket: expr.Bracket,
+			Index:   o.optimize(expr.Index),
+			Value:   o.optimize(expr.Value),
+		}
+	case *ast.Property:
+		return &ast.Property{Object: o.optimize(expr.Object), Name: expr.Name}
+	case *ast.SetProperty:
+		return &ast.SetProperty{Object: o.optimize(expr.Object), Name: expr.Name, Value: o.optimize(expr.Value)}
+	default:
+		return expr
+	}
+}
+
+func literal(expr ast.Expr) (any, bool) {
+	l, ok := expr.(*ast.Literal)
+	if !ok {
+		return nil, false
+	}
+	return l.Value, true
+}
+
+func (o optimizer) grouping(expr *ast.Grouping) ast.Expr {
+	inner := o.optimize(expr.Expression)
+	if _, ok := inner.(*ast.Literal); ok {
+		return inner
+	}
+	return &ast.Grouping{Expression: inner}
+}
+
+func (o optimizer) unary(expr *ast.Unary) ast.Expr {
+	right := o.optimize(expr.Right)
+	if v, ok := literal(right); ok {
+		switch expr.Operator.TokenType {
+		case token.BANG:
+			return &ast.Literal{Value: value.Not(v)}
+		case token.MINUS:
+			if result, err := value.Negate(v); err == nil {
+				return &ast.Literal{Value: result}
+			}
+		}
+	}
+
+	// --x is x and !!!x is !x, as long as x already has the type the outer operator produces
+	if inner, ok := unwrap(right).(*ast.Unary); ok && inner.Operator.TokenType == expr.Operator.TokenType {
+		switch expr.Operator.TokenType {
+		case token.MINUS:
+			if isNumber(inner.Right) {
+				return inner.Right
+			}
+		case token.BANG:
+			if isBool(inner.Right) {
+				return inner.Right
+			}
+		}
+	}
+	return &ast.Unary{Operator: expr.Operator, Right: right}
+}
+
+// binaryOps maps the operators that can be folded to their implementation.
+var binaryOps = map[token.TokenType]func(a, b any) (any, error){
+	token.PLUS:          value.Add,
+	token.MINUS:         value.Subtract,
+	token.STAR:          value.Multiply,
+	token.SLASH:         value.Divide,
+	token.GREATER:       value.Greater,
+	token.GREATER_EQUAL: value.GreaterEqual,
+	token.LESS:          value.Less,
+	token.LESS_EQUAL:    value.LessEqual,
+	token.EQUAL_EQUAL: func(a, b any) (any, error) {
+		return value.IsEqual(a, b), nil
+	},
+	token.BANG_EQUAL: func(a, b any) (any, error) {
+		return !value.IsEqual(a, b), nil
+	},
+}
+
+func (o optimizer) binary(expr *ast.Binary) ast.Expr {
+	left := o.optimize(expr.Left)
+	right := o.optimize(expr.Right)
+
+	// a literal has no effects, so the comma operator can drop it
+	if expr.Operator.TokenType == token.COMMA {
+		if _, ok := literal(left); ok {
+			return right
+		}
+	}
+
+	a, leftOK := literal(left)
+	b, rightOK := literal(right)
+	if op, ok := binaryOps[expr.Operator.TokenType]; ok && leftOK && rightOK {
+		if result, err := op(a, b); err == nil {
+			if _, isString := result.(string); !isString || o.foldConcatenation {
+				return &ast.Literal{Value: result}
+			}
+		}
+	}
+
+	// x + 0 and 0 + x aren't simplified: -0 + 0 is 0, not -0
+	switch expr.Operator.TokenType {
+	case token.MINUS:
+		if isNumber(left) && isNumberLiteral(b, rightOK, 0) {
+			return left
+		}
+	case token.STAR:
+		if isNumber(left) && isNumberLiteral(b, rightOK, 1) {
+			return left
+		}
+		if isNumberLiteral(a, leftOK, 1) && isNumber(right) {
+			return right
+		}
+	case token.SLASH:
+		if isNumber(left) && isNumberLiteral(b, rightOK, 1) {
+			return left
+		}
+	}
+	return &ast.Binary{Left: left, Operator: expr.Operator, Right: right}
+}
+
+func (o optimizer) conditional(expr *ast.Conditional) ast.Expr {
+	condition := o.optimize(expr.Condition)
+	// only the branch a constant condition picks can run
+	if v, ok := literal(condition); ok {
+		if value.IsTruthy(v) {
+			return o.optimize(expr.ThenBranch)
+		}
+		return o.optimize(expr.ElseBranch)
+	}
+	return &ast.Conditional{
+		Condition:  condition,
+		ThenBranch: o.optimize(expr.ThenBranch),
+		ElseBranch: o.optimize(expr.ElseBranch),
+	}
+}
+
+// call optimizes the callee and arguments.
+// The call itself can't be folded, since natives may have effects.
+func (o optimizer) call(expr *ast.Call) ast.Expr {
+	return &ast.Call{
+		Callee:    o.optimize(expr.Callee),
+		Paren:     expr.Paren,
+		Arguments: o.optimizeAll(expr.Arguments),
+	}
+}
+
+func (o optimizer) optimizeAll(exprs []ast.Expr) []ast.Expr {
+	optimized := make([]ast.Expr, len(exprs))
+	for i, e := range exprs {
+		optimized[i] = o.optimize(e)
+	}
+	return optimized
+}
+
+func isNumberLiteral(v any, ok bool, want float64) bool {
+	n, isNumber := v.(float64)
+	return ok && isNumber && n == want
+}
+
+func unwrap(expr ast.Expr) ast.Expr {
+	for {
+		g, ok := expr.(*ast.Grouping)
+		if !ok {
+			return expr
+		}
+		expr = g.Expression
+	}
+}
+
+// isNumber reports whether expr either evaluates to a number or raises an error,
+// in which case an arithmetic identity around it can be dropped
+// without changing what the program does.
+func isNumber(expr ast.Expr) bool {
+	switch expr := unwrap(expr).(type) {
+	case *ast.Literal:
+		_, ok := expr.Value.(float64)
+		return ok
+	case *ast.Unary:
+		return expr.Operator.TokenType == token.MINUS
+	case *ast.Binary:
+		switch expr.Operator.TokenType {
+		case token.MINUS, token.STAR, token.SLASH:
+			return true
+		case token.PLUS:
+			return isNumber(expr.Left) && isNumber(expr.Right)
+		case token.COMMA:
+			return isNumber(expr.Right)
+		}
+	case *ast.Conditional:
+		return isNumber(expr.ThenBranch) && isNumber(expr.ElseBranch)
+	}
+	return false
+}
+
+// isBool is isNumber for booleans.
+func isBool(expr ast.Expr) bool {
+	switch expr := unwrap(expr).(type) {
+	case *ast.Literal:
+		_, ok := expr.Value.(bool)
+		return ok
+	case *ast.Unary:
+		return expr.Operator.TokenType == token.BANG
+	case *ast.Binary:
+		switch expr.Operator.TokenType {
+		case token.EQUAL_EQUAL, token.BANG_EQUAL, token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+			return true
+		case token.COMMA:
+			return isBool(expr.Right)
+		}
+	case *ast.Conditional:
+		return isBool(expr.ThenBranch) && isBool(expr.ElseBranch)
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package optimizer_test
+
+import (
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/ast"
+	"github.com/taylorlowery/lox/internal/compiler"
+	"github.com/taylorlowery/lox/internal/optimizer"
+	"github.com/taylorlowery/lox/internal/parser"
+	"github.com/taylorlowery/lox/internal/scanner"
+	"github.com/taylorlowery/lox/internal/value"
+	"github.com/taylorlowery/lox/internal/vm"
+)
+
+func parse(t *testing.T, source string) ast.Expr {
+	t.Helper()
+	tokens, scanErr := scanner.NewScanner(source).ScanTokens()
+	if scanErr != nil {
+		t.Fatal(scanErr)
+	}
+	expr, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expr
+}
+
+func printTree(t *testing.T, expr ast.Expr) string {
+	t.Helper()
+	printer, err := ast.NewAstPrinter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return printer.PrintAst(expr)
+}
+
+var sources = []struct {
+	name   string
+	source string
+	want   string
+}{
+	{name: "arithmetic", source: "60 * 60 * 24", want: "86400"},
+	{name: "grouping", source: "(1 + 2) * (3 - -4)", want: "21"},
+	{name: "not", source: "!true", want: "false"},
+	{name: "not nil", source: "!!nil", want: "false"},
+	{name: "concatenation", source: "\"a\" + \"b\" + \"c\"", want: "abc"},
+	{name: "comparison", source: "1 < 2 == !false", want: "true"},
+	{name: "not equal", source: "1 != \"1\"", want: "true"},
+	{name: "comma", source: "1, 2, 3", want: "3"},
+	{name: "true condition", source: "1 < 2 ? \"yes\" : \"a\" - 1", want: "yes"},
+	{name: "false condition", source: "nil ? \"a\" - 1 : 2", want: "2"},
+	{name: "keeps type errors", source: "\"a\" - 1", want: "(- a 1)"},
+	{name: "folds around type errors", source: "(1 + 2) * (\"a\" - 1)", want: "(* 3 (group (- a 1)))"},
+	{name: "division by zero", source: "1 / 0", want: "+Inf"},
+	{name: "keeps negated strings", source: "-\"a\"", want: "(- a)"},
+	{name: "subtract zero", source: "-\"a\" - 0", want: "(- a)"},
+	{name: "multiply by one", source: "1 * (\"a\" - 1) * 1", want: "(group (- a 1))"},
+	{name: "divide by one", source: "(\"a\" * 2) / 1", want: "(group (* a 2))"},
+	{name: "keeps add zero", source: "-\"a\" + 0", want: "(+ (- a) 0)"},
+	{name: "keeps multiply string by one", source: "(\"a\" - 1, \"a\") * 1", want: "(* (group (, (- a 1) a)) 1)"},
+	{name: "double negation", source: "-(-(\"a\" - 1))", want: "(group (- a 1))"},
+	{name: "keeps double negation of strings", source: "-(-\"a\")", want: "(- (group (- a)))"},
+	{name: "double not", source: "!!(1 > \"a\")", want: "(group (> 1 a))"},
+	{name: "keeps double not of non booleans", source: "!!(\"a\" - 1)", want: "(! (! (group (- a 1))))"},
+	{name: "drops literal comma operands", source: "1, \"a\" - 1", want: "(- a 1)"},
+	{name: "folds in properties", source: "(1 + 2).x = (3 * 4).y", want: "(set.x 3 (.y 12))"},
+}
+
+func TestOptimize_FoldsAndSimplifies(t *testing.T) {
+	t.Parallel()
+
+	for _, tt := range sources {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			expr := parse(t, tt.source)
+			before := printTree(t, expr)
+
+			got := printTree(t, optimizer.Optimize(expr))
+			if got != tt.want {
+				t.Errorf("want %s, got %s", tt.want, got)
+			}
+			if after := printTree(t, expr); after != before {
+				t.Errorf("input tree changed from %s to %s", before, after)
+			}
+		})
+	}
+}
+
+func TestOptimizeWithoutConcatenation_LeavesStringsToBuild(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source string
+		want   string
+	}{
+		{"\"a\" + \"b\" + \"c\"", "(+ (+ a b) c)"},
+		{"1 + 2 * 3", "7"},
+		{"\"a\" == \"a\"", "true"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+			if got := printTree(t, optimizer.OptimizeWithoutConcatenation(parse(t, tc.source))); got != tc.want {
+				t.Errorf("want %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+// run compiles and runs expr, returning what it prints or the error it raises.
+func run(t *testing.T, expr ast.Expr) string {
+	t.Helper()
+	c, err := compiler.Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine, err := vm.NewVM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := machine.Run(c)
+	if err != nil {
+		return err.Error()
+	}
+	return value.Stringify(result)
+}
+
+func TestOptimize_PreservesBehavior(t *testing.T) {
+	t.Parallel()
+
+	cases := []string{
+		"0 * -1",
+		"-0 - 0",
+		"-(0) * 1",
+		"\"a\" - 1 - 0",
+		"(1 / 0) * 1",
+	}
+	for _, tt := range sources {
+		cases = append(cases, tt.source)
+	}
+
+	for _, source := range cases {
+		t.Run(source, func(t *testing.T) {
+			t.Parallel()
+			expr := parse(t, source)
+			want := run(t, expr)
+			if got := run(t, optimizer.Optimize(expr)); got != want {
+				t.Errorf("want %s, got %s", want, got)
+			}
+		})
+	}
+}
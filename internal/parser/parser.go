@@ -3,18 +3,47 @@
 
 Grammar rules:
 
-expression     → equality ;
+expression     → comma ;
+comma          → assignment ( "," assignment )* ;
+assignment     → call ( "[" expression "]" | "." IDENTIFIER ) "=" assignment | conditional ;
+conditional    → equality ( "?" expression ":" conditional )? ;
 equality       → comparison ( ( "!=" | "==" ) comparison )* ;
 comparison     → term ( ( ">" | ">=" | "<" | "<=" ) term )* ;
 term           → factor ( ( "-" | "+" ) factor )* ;
 factor         → unary ( ( "/" | "*" ) unary )* ;
-unary          → ( "!" | "-" ) unary | primary ;
-primary        → NUMBER | STRING | "true" | "false" | "nil" | "(" expression ")" ;
+unary          → ( "!" | "-" ) unary | call ;
+call           → primary ( "(" arguments? ")" | "[" expression "]" | "." IDENTIFIER )* ;
+arguments      → assignment ( "," assignment )* ;
+primary        → NUMBER | STRING | "true" | "false" | "nil" | "(" expression ")" | IDENTIFIER | "[" arguments? "]" | "{" entries? "}" ;
+entries        → assignment ":" assignment ( "," assignment ":" assignment )* ;
+
+Arguments, list elements and map entries are parsed below the comma operator,
+whose commas would be ambiguous with the ones separating them.
+
+Lox has no statements yet, so a '{' can only begin a map literal.
+When blocks arrive, a '{' at the start of a statement will begin a block,
+as in JavaScript, and a map literal will only be parsed where an expression is expected.
+
+Error productions catch binary operators missing their left-hand operand.
+The operand on the right is parsed and discarded:
+
+primary        → "," assignment ;
+primary        → "=" assignment ;
+primary        → "?" expression ":" conditional ;
+primary        → ( "!=" | "==" ) comparison ;
+primary        → ( ">" | ">=" | "<" | "<=" ) term ;
+primary        → "+" factor ;
+primary        → ( "/" | "*" ) unary ;
+
+A Parser created by NewPrattParser parses the same grammar,
+but handles the operator rules from comma down to unary with a Pratt parser
+driven by an OperatorTable, so operators can be added without new methods.
 */
 package parser
 
 import (
 	"errors"
+	"fmt"
 	"slices"
 
 	"github.com/taylorlowery/lox/internal/ast"
@@ -22,8 +51,41 @@ import (
 )
 
 type Parser struct {
-	tokens  []token.Token
-	current int
+	tokens    []token.Token
+	current   int
+	operators *OperatorTable
+
+	// tolerant is set by ParseTolerant. Instead of panicking on a syntax error,
+	// the parser records it in errors and carries on.
+	tolerant bool
+	// errors holds the syntax errors the parser recovered from.
+	errors []error
+	// panicMode suppresses the cascade of errors that follows the first one,
+	// until the parser starts on the next expression.
+	panicMode bool
+	// depth is how deeply the expression being parsed nests, as counted by nest.
+	depth int
+}
+
+// maxDepth is how deeply an expression can nest.
+// Each operator in a chain such as 1 + 2 + 3 counts as a level too,
+// since the tree nests as deeply as if it were parenthesized.
+// It keeps the parser, and the passes that walk its trees, from overflowing the Go stack.
+const maxDepth = 1000
+
+// nest moves the parser a level deeper into an expression, panicking with a ParseError,
+// even in tolerant mode, if it's too deep.
+// The caller restores the depth with leave when it returns.
+func (p *Parser) nest() {
+	p.depth++
+	if p.depth > maxDepth {
+		panic(p.parseError(p.peek(), "Expression nesting too deep."))
+	}
+}
+
+// leave restores the depth a parsing method started at.
+func (p *Parser) leave(depth int) {
+	p.depth = depth
 }
 
 // NewParser creates a new Parser instance with the given tokens
@@ -35,13 +97,95 @@ func NewParser(tokens []token.Token) *Parser {
 }
 
 func (p *Parser) expression() ast.Expr {
-	return p.equality()
+	if p.operators != nil {
+		return p.ParsePrecedence(0)
+	}
+	return p.comma()
+}
+
+// comma parses the comma operator, which evaluates both operands
+// and produces the right one, as in C.
+func (p *Parser) comma() ast.Expr {
+	defer p.leave(p.depth)
+	expr := p.assignment()
+
+	for p.match(token.COMMA) {
+		p.nest()
+		operator := p.previous()
+		right := p.assignment()
+		expr = &ast.Binary{
+			Left:     expr,
+			Operator: operator,
+			Right:    right,
+		}
+	}
+	return expr
+}
+
+// assignment parses the right associative '=' operator.
+// Its target is parsed as an ordinary expression, then checked.
+func (p *Parser) assignment() ast.Expr {
+	defer p.leave(p.depth)
+	p.nest()
+	expr := p.conditional()
+
+	if p.match(token.EQUAL) {
+		equals := p.previous()
+		value := p.assignment()
+		return p.assign(expr, equals, value)
+	}
+	return expr
+}
+
+// assign turns an element of a list or a property into the target of an assignment.
+// Any other target is reported, without panicking, since the parser isn't confused.
+func (p *Parser) assign(target ast.Expr, equals token.Token, value ast.Expr) ast.Expr {
+	switch target := target.(type) {
+	case *ast.Index:
+		return &ast.SetIndex{
+			Object:  target.Object,
+			Bracket: target.Bracket,
+			Index:   target.Index,
+			Value:   value,
+		}
+	case *ast.Property:
+		return &ast.SetProperty{
+			Object: target.Object,
+			Name:   target.Name,
+			Value:  value,
+		}
+	default:
+		p.report(p.parseError(equals, "Invalid assignment target"))
+		return target
+	}
+}
+
+// conditional parses the right associative ternary operator.
+// As in C, the middle operand can be any expression, comma included.
+func (p *Parser) conditional() ast.Expr {
+	expr := p.equality()
+
+	if p.match(token.QUESTION) {
+		defer p.leave(p.depth)
+		p.nest()
+		thenBranch := p.expression()
+		p.consume(token.COLON, "Expect ':' after then branch of conditional expression")
+		elseBranch := p.conditional()
+		expr = &ast.Conditional{
+			Condition:  expr,
+			ThenBranch: thenBranch,
+			ElseBranch: elseBranch,
+		}
+	}
+	return expr
 }
 
 func (p *Parser) equality() ast.Expr {
+	defer p.leave(p.depth)
 	expr := p.comparison()
 
 	for p.match(token.BANG_EQUAL, token.EQUAL_EQUAL) {
+		p.nest()
 		operator := p.previous()
 		right := p.comparison()
 		expr = &ast.Binary{
@@ -90,9 +234,11 @@ func (p *Parser) previous() token.Token {
 }
 
 func (p *Parser) comparison() ast.Expr {
+	defer p.leave(p.depth)
 	expr := p.term()
 
 	for p.match(token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL) {
+		p.nest()
 		operator := p.previous()
 		right := p.term()
 		expr = &ast.Binary{
@@ -105,9 +251,11 @@ func (p *Parser) comparison() ast.Expr {
 }
 
 func (p *Parser) term() ast.Expr {
+	defer p.leave(p.depth)
 	expr := p.factor()
 
 	for p.match(token.MINUS, token.PLUS) {
+		p.nest()
 		operator := p.previous()
 		right := p.factor()
 		expr = &ast.Binary{
@@ -120,9 +268,11 @@ func (p *Parser) term() ast.Expr {
 }
 
 func (p *Parser) factor() ast.Expr {
+	defer p.leave(p.depth)
 	expr := p.unary()
 
 	for p.match(token.SLASH, token.STAR) {
+		p.nest()
 		operator := p.previous()
 		right := p.unary()
 		expr = &ast.Binary{
@@ -136,6 +286,8 @@ func (p *Parser) factor() ast.Expr {
 
 func (p *Parser) unary() ast.Expr {
 	if p.match(token.BANG, token.MINUS) {
+		defer p.leave(p.depth)
+		p.nest()
 		operator := p.previous()
 		right := p.unary()
 		return &ast.Unary{
@@ -143,7 +295,128 @@ func (p *Parser) unary() ast.Expr {
 			Right:    right,
 		}
 	}
-	return p.primary()
+	return p.call()
+}
+
+// maxArguments is the most arguments a call can pass,
+// since the VM's call instruction stores the count in a byte.
+const maxArguments = 255
+
+func (p *Parser) call() ast.Expr {
+	defer p.leave(p.depth)
+	expr := p.primary()
+
+	for {
+		switch {
+		case p.match(token.LEFT_PAREN):
+			p.nest()
+			expr = p.finishCall(expr)
+		case p.match(token.LEFT_BRACKET):
+			p.nest()
+			expr = p.finishIndex(expr)
+		case p.match(token.DOT):
+			p.nest()
+			expr = p.finishProperty(expr)
+		default:
+			return expr
+		}
+	}
+}
+
+// finishCall parses the arguments of a call to callee,
+// after the opening parenthesis has been consumed.
+func (p *Parser) finishCall(callee ast.Expr) ast.Expr {
+	var arguments []ast.Expr
+	if !p.check(token.RIGHT_PAREN) {
+		for {
+			if len(arguments) == maxArguments {
+				// keep parsing: the call is still well formed
+				p.report(p.parseError(p.peek(), fmt.Sprintf("Can't have more than %d arguments", maxArguments)))
+			}
+			arguments = append(arguments, p.argument())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	paren := p.consume(token.RIGHT_PAREN, "Expect ')' after arguments")
+	return &ast.Call{
+		Callee:    callee,
+		Paren:     paren,
+		Arguments: arguments,
+	}
+}
+
+// finishIndex parses the index of an element of object,
+// after the opening bracket has been consumed.
+func (p *Parser) finishIndex(object ast.Expr) ast.Expr {
+	index := p.expression()
+	bracket := p.consume(token.RIGHT_BRACKET, "Expect ']' after index")
+	return &ast.Index{
+		Object:  object,
+		Bracket: bracket,
+		Index:   index,
+	}
+}
+
+// finishProperty parses the name of a property of object,
+// after the dot has been consumed.
+func (p *Parser) finishProperty(object ast.Expr) ast.Expr {
+	name := p.consume(token.IDENTIFIER, "Expect property name after '.'")
+	return &ast.Property{
+		Object: object,
+		Name:   name,
+	}
+}
+
+// argument parses an argument, a list element, or a map key or value.
+func (p *Parser) argument() ast.Expr {
+	if p.operators != nil {
+		return p.ParsePrecedence(PowerAssignment)
+	}
+	return p.assignment()
+}
+
+// list parses the elements of a list literal,
+// after the opening bracket has been consumed.
+func (p *Parser) list() ast.Expr {
+	bracket := p.previous()
+	var elements []ast.Expr
+	if !p.check(token.RIGHT_BRACKET) {
+		for {
+			elements = append(elements, p.argument())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(token.RIGHT_BRACKET, "Expect ']' after list elements")
+	return &ast.ListLiteral{
+		Bracket:  bracket,
+		Elements: elements,
+	}
+}
+
+// mapLiteral parses the entries of a map literal after its '{'.
+func (p *Parser) mapLiteral() ast.Expr {
+	brace := p.previous()
+	var keys, values []ast.Expr
+	if !p.check(token.RIGHT_BRACE) {
+		for {
+			keys = append(keys, p.argument())
+			p.consume(token.COLON, "Expect ':' after map key")
+			values = append(values, p.argument())
+			if !p.match(token.COMMA) {
+				break
+			}
+		}
+	}
+	p.consume(token.RIGHT_BRACE, "Expect '}' after map entries")
+	return &ast.MapLiteral{
+		Brace:  brace,
+		Keys:   keys,
+		Values: values,
+	}
 }
 
 func (p *Parser) primary() ast.Expr {
@@ -170,6 +443,12 @@ func (p *Parser) primary() ast.Expr {
 		}
 	}
 
+	if p.match(token.IDENTIFIER) {
+		return &ast.Variable{
+			Name: p.previous(),
+		}
+	}
+
 	if p.match(token.LEFT_PAREN) {
 		expr := p.expression()
 		p.consume(token.RIGHT_PAREN, "Expect ')' after expression")
@@ -178,7 +457,89 @@ func (p *Parser) primary() ast.Expr {
 		}
 	}
 
-	panic(p.parseError(p.peek(), "expect expression"))
+	if p.match(token.LEFT_BRACKET) {
+		return p.list()
+	}
+
+	if p.match(token.LEFT_BRACE) {
+		return p.mapLiteral()
+	}
+
+	if p.operators == nil {
+		// ParsePrecedence looks for these itself
+		if operator, ok := p.matchMissingLeftOperand(); ok {
+			return p.missingLeftOperand(operator)
+		}
+	}
+
+	err := p.parseError(p.peek(), "expect expression")
+	if p.tolerant {
+		return p.badExpr(err)
+	}
+	panic(err)
+}
+
+// matchMissingLeftOperand consumes a binary operator found where an operand should start.
+// '-' is left out, since it is also a unary operator.
+func (p *Parser) matchMissingLeftOperand() (token.Token, bool) {
+	if p.operators != nil {
+		rule, ok := p.operators.infix[p.peek().TokenType]
+		if !ok || rule.postfix != nil || p.isAtEnd() {
+			return token.Token{}, false
+		}
+		return p.advance(), true
+	}
+	if p.match(
+		token.COMMA,
+		token.EQUAL,
+		token.QUESTION,
+		token.BANG_EQUAL, token.EQUAL_EQUAL,
+		token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL,
+		token.PLUS,
+		token.SLASH, token.STAR,
+	) {
+		return p.previous(), true
+	}
+	return token.Token{}, false
+}
+
+// missingLeftOperand reports a binary operator with no left-hand operand,
+// then parses and discards its right-hand operand so that parsing can carry on.
+// The operator and the discarded operand are returned in a BadExpr.
+func (p *Parser) missingLeftOperand(operator token.Token) ast.Expr {
+	start := p.current - 1
+	p.report(p.parseError(operator, fmt.Sprintf("missing left-hand operand for '%s'", operator.Lexeme)))
+
+	if p.operators != nil {
+		// the operator's handler isn't called, since it would need a left operand
+		rule := p.operators.infix[operator.TokenType]
+		if operator.TokenType == token.QUESTION {
+			p.ParsePrecedence(0)
+			p.consume(token.COLON, "Expect ':' after then branch of conditional expression")
+		}
+		p.ParsePrecedence(rule.rightPower())
+	} else {
+		switch operator.TokenType {
+		case token.COMMA, token.EQUAL:
+			p.assignment()
+		case token.QUESTION:
+			p.expression()
+			p.consume(token.COLON, "Expect ':' after then branch of conditional expression")
+			p.conditional()
+		case token.BANG_EQUAL, token.EQUAL_EQUAL:
+			p.comparison()
+		case token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL:
+			p.term()
+		case token.PLUS:
+			p.factor()
+		case token.SLASH, token.STAR:
+			p.unary()
+		}
+	}
+
+	return &ast.BadExpr{
+		Tokens: slices.Clone(p.tokens[start:p.current]),
+	}
 }
 
 func (p *Parser) consume(tokenType token.TokenType, message string) token.Token {
@@ -186,14 +547,60 @@ func (p *Parser) consume(tokenType token.TokenType, message string) token.Token
 		return p.advance()
 	}
 
-	panic(p.parseError(p.peek(), message))
+	err := p.parseError(p.peek(), message)
+	if p.tolerant {
+		// carry on as if the missing token had been there
+		p.report(err)
+		return token.Token{TokenType: tokenType, Line: p.peek().Line}
+	}
+	panic(err)
+}
+
+// report records a syntax error in tolerant mode,
+// unless it follows an earlier error in the same expression.
+func (p *Parser) report(err error) {
+	if p.panicMode {
+		return
+	}
+	p.errors = append(p.errors, err)
+}
+
+// badExpr reports err, then synchronizes and returns a BadExpr
+// holding the tokens that were skipped.
+func (p *Parser) badExpr(err error) *ast.BadExpr {
+	p.report(err)
+	p.panicMode = true
+	start := p.current
+	p.synchronize()
+	return &ast.BadExpr{
+		Tokens: slices.Clone(p.tokens[start:p.current]),
+	}
+}
+
+// ParseError is a syntax error, found at a token.
+type ParseError struct {
+	Line int
+	// Lexeme is the text of the token, unless AtEnd is set
+	// because the error was found at the end of the source.
+	Lexeme  string
+	AtEnd   bool
+	Message string
+}
+
+func (e ParseError) Error() string {
+	if e.AtEnd {
+		return "Parser error at end: " + e.Message
+	}
+	return fmt.Sprintf("Parser error at '%s': %s", e.Lexeme, e.Message)
 }
 
 func (p *Parser) parseError(t token.Token, message string) error {
-	// TODO: Figure out how to pass this error back to golox
-	// figure out what kind of panic they're talking about in consume()
-	// golox.TokenError(t, message)
-	return errors.New("Parser error: " + message)
+	return ParseError{
+		Line:    t.Line,
+		Lexeme:  t.Lexeme,
+		AtEnd:   t.TokenType == token.EOF,
+		Message: message,
+	}
 }
 
 func (p *Parser) synchronize() {
@@ -213,6 +620,64 @@ func (p *Parser) synchronize() {
 	}
 }
 
-func (p *Parser) Parse() (ast.Expr, error) {
-	return p.expression(), nil
+// Parse parses the tokens as a single expression, which must use them all.
+// It stops at the first syntax error it can't recover from.
+// Errors it can recover from, such as a binary operator missing its left-hand operand,
+// are all returned, joined, alongside the tree.
+func (p *Parser) Parse() (expr ast.Expr, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			parseErr, ok := r.(ParseError)
+			if !ok {
+				panic(r)
+			}
+			expr = nil
+			err = errors.Join(append(p.errors, parseErr)...)
+		}
+	}()
+
+	expr = p.expression()
+	if !p.isAtEnd() {
+		panic(p.parseError(p.peek(), "expect end of expression"))
+	}
+	return expr, errors.Join(p.errors...)
+}
+
+// ParseTolerant parses the tokens without ever aborting, for tools such as editors
+// that need a partial tree for broken input.
+// Wherever the syntax is broken, the tree holds an ast.BadExpr with the skipped tokens,
+// and parsing resumes at the next synchronization point.
+// Tokens left over after a complete expression are reported and skipped the same way,
+// and any expressions after them are returned as further trees.
+// The returned errors are the syntax errors found, in order.
+//
+// The exception is an expression that nests too deeply to parse:
+// it's reported, and the rest of the tokens are returned in a last BadExpr.
+func (p *Parser) ParseTolerant() (exprs []ast.Expr, errs []error) {
+	p.tolerant = true
+	start := p.current
+	defer func() {
+		if r := recover(); r != nil {
+			parseErr, ok := r.(ParseError)
+			if !ok {
+				panic(r)
+			}
+			p.report(parseErr)
+			for !p.isAtEnd() {
+				p.advance()
+			}
+			exprs = append(exprs, &ast.BadExpr{Tokens: slices.Clone(p.tokens[start:p.current])})
+			errs = p.errors
+		}
+	}()
+
+	for !p.isAtEnd() {
+		p.panicMode = false
+		start = p.current
+		exprs = append(exprs, p.expression())
+		if !p.isAtEnd() && !p.panicMode {
+			exprs = append(exprs, p.badExpr(p.parseError(p.peek(), "expect end of expression")))
+		}
+	}
+	return exprs, p.errors
 }
@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"errors"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/taylorlowery/lox/internal/ast"
@@ -110,8 +112,8 @@ func TestParser_PrimaryExpressions(t *testing.T) {
 			parser := NewParser(tc.tokens)
 			result := parser.expression()
 
-			if !reflect.DeepEqual(result, tc.expected) {
-				t.Errorf("Expected %+v, got %+v", tc.expected, result)
+			if diff := ast.Diff(tc.expected, result); diff != "" {
+				t.Error(diff)
 			}
 		})
 	}
@@ -175,8 +177,8 @@ func TestParser_UnaryExpressions(t *testing.T) {
 			parser := NewParser(tc.tokens)
 			result := parser.expression()
 
-			if !reflect.DeepEqual(result, tc.expected) {
-				t.Errorf("Expected %+v, got %+v", tc.expected, result)
+			if diff := ast.Diff(tc.expected, result); diff != "" {
+				t.Error(diff)
 			}
 		})
 	}
@@ -256,8 +258,8 @@ func TestParser_BinaryExpressions_Factor(t *testing.T) {
 			parser := NewParser(tc.tokens)
 			result := parser.expression()
 
-			if !reflect.DeepEqual(result, tc.expected) {
-				t.Errorf("Expected %+v, got %+v", tc.expected, result)
+			if diff := ast.Diff(tc.expected, result); diff != "" {
+				t.Error(diff)
 			}
 		})
 	}
@@ -337,8 +339,8 @@ func TestParser_BinaryExpressions_Term(t *testing.T) {
 			parser := NewParser(tc.tokens)
 			result := parser.expression()
 
-			if !reflect.DeepEqual(result, tc.expected) {
-				t.Errorf("Expected %+v, got %+v", tc.expected, result)
+			if diff := ast.Diff(tc.expected, result); diff != "" {
+				t.Error(diff)
 			}
 		})
 	}
@@ -427,8 +429,8 @@ func TestParser_BinaryExpressions_Comparison(t *testing.T) {
 			parser := NewParser(tc.tokens)
 			result := parser.expression()
 
-			if !reflect.DeepEqual(result, tc.expected) {
-				t.Errorf("Expected %+v, got %+v", tc.expected, result)
+			if diff := ast.Diff(tc.expected, result); diff != "" {
+				t.Error(diff)
 			}
 		})
 	}
@@ -483,8 +485,8 @@ func TestParser_BinaryExpressions_Equality(t *testing.T) {
 			parser := NewParser(tc.tokens)
 			result := parser.expression()
 
-			if !reflect.DeepEqual(result, tc.expected) {
-				t.Errorf("Expected %+v, got %+v", tc.expected, result)
+			if diff := ast.Diff(tc.expected, result); diff != "" {
+				t.Error(diff)
 			}
 		})
 	}
@@ -605,8 +607,8 @@ func TestParser_OperatorPrecedence(t *testing.T) {
 			parser := NewParser(tc.tokens)
 			result := parser.expression()
 
-			if !reflect.DeepEqual(result, tc.expected) {
-				t.Errorf("Expected %+v, got %+v", tc.expected, result)
+			if diff := ast.Diff(tc.expected, result); diff != "" {
+				t.Error(diff)
 			}
 		})
 	}
@@ -758,8 +760,12 @@ func TestParser_ErrorHandling(t *testing.T) {
 		if err == nil {
 			t.Error("Expected parseError to return an error")
 		}
-		if err.Error() != "Parser error: test message" {
-			t.Errorf("Expected 'Parser error: test message', got %s", err.Error())
+		want := ParseError{Line: 1, Lexeme: "42", Message: "test message"}
+		if err != want {
+			t.Errorf("Expected %#v, got %#v", want, err)
+		}
+		if err.Error() != "Parser error at '42': test message" {
+			t.Errorf("Expected 'Parser error at '42': test message', got %s", err.Error())
 		}
 	})
 
@@ -822,8 +828,8 @@ func TestParser_EdgeCases(t *testing.T) {
 			},
 		}
 
-		if !reflect.DeepEqual(result, expected) {
-			t.Errorf("Expected %+v, got %+v", expected, result)
+		if diff := ast.Diff(expected, result); diff != "" {
+			t.Error(diff)
 		}
 	})
 
@@ -893,8 +899,8 @@ func TestParser_ComplexExpressions(t *testing.T) {
 			},
 		}
 
-		if !reflect.DeepEqual(result, expected) {
-			t.Errorf("Expected %+v, got %+v", expected, result)
+		if diff := ast.Diff(expected, result); diff != "" {
+			t.Error(diff)
 		}
 	})
 
@@ -936,8 +942,8 @@ func TestParser_ComplexExpressions(t *testing.T) {
 			},
 		}
 
-		if !reflect.DeepEqual(result, expected) {
-			t.Errorf("Expected %+v, got %+v", expected, result)
+		if diff := ast.Diff(expected, result); diff != "" {
+			t.Error(diff)
 		}
 	})
 }
@@ -1027,8 +1033,8 @@ func TestParser_AdditionalEdgeCases(t *testing.T) {
 			},
 		}
 
-		if !reflect.DeepEqual(result, expected) {
-			t.Errorf("Expected %+v, got %+v", expected, result)
+		if diff := ast.Diff(expected, result); diff != "" {
+			t.Error(diff)
 		}
 	})
 
@@ -1055,8 +1061,8 @@ func TestParser_AdditionalEdgeCases(t *testing.T) {
 			},
 		}
 
-		if !reflect.DeepEqual(result, expected) {
-			t.Errorf("Expected %+v, got %+v", expected, result)
+		if diff := ast.Diff(expected, result); diff != "" {
+			t.Error(diff)
 		}
 	})
 
@@ -1079,8 +1085,8 @@ func TestParser_AdditionalEdgeCases(t *testing.T) {
 			},
 		}
 
-		if !reflect.DeepEqual(result, expected) {
-			t.Errorf("Expected %+v, got %+v", expected, result)
+		if diff := ast.Diff(expected, result); diff != "" {
+			t.Error(diff)
 		}
 	})
 
@@ -1105,8 +1111,8 @@ func TestParser_AdditionalEdgeCases(t *testing.T) {
 					Value: tc.expected,
 				}
 
-				if !reflect.DeepEqual(result, expected) {
-					t.Errorf("Expected %+v, got %+v", expected, result)
+				if diff := ast.Diff(expected, result); diff != "" {
+					t.Error(diff)
 				}
 			})
 		}
@@ -1140,8 +1146,8 @@ func TestParser_AdditionalEdgeCases(t *testing.T) {
 					},
 				}
 
-				if !reflect.DeepEqual(result, expected) {
-					t.Errorf("Expected %+v, got %+v", expected, result)
+				if diff := ast.Diff(expected, result); diff != "" {
+					t.Error(diff)
 				}
 			})
 		}
@@ -1184,8 +1190,697 @@ func TestParser_AdditionalEdgeCases(t *testing.T) {
 			},
 		}
 
-		if !reflect.DeepEqual(result, expected) {
-			t.Errorf("Expected %+v, got %+v", expected, result)
+		if diff := ast.Diff(expected, result); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
+
+func TestParser_ParseTolerant(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   []string
+		errors []string
+	}{
+		{
+			name:   "valid expression",
+			source: "1 + 2",
+			want:   []string{"(+ 1 2)"},
+		},
+		{
+			name:   "empty input",
+			source: "",
+		},
+		{
+			name:   "missing operand resyncs at semicolon",
+			source: "1 + ; 2 * 3",
+			want:   []string{"(+ 1 (bad ;))", "(* 2 3)"},
+			errors: []string{"Parser error at ';': expect expression"},
+		},
+		{
+			name:   "cascading errors are suppressed",
+			source: "(1 + ) * 2",
+			want:   []string{"(group (+ 1 (bad ) * 2)))"},
+			errors: []string{"Parser error at ')': expect expression"},
+		},
+		{
+			name:   "missing closing paren",
+			source: "(1",
+			want:   []string{"(group 1)"},
+			errors: []string{"Parser error at end: Expect ')' after expression"},
+		},
+		{
+			name:   "trailing tokens",
+			source: "1 2; 3",
+			want:   []string{"1", "(bad 2 ;)", "3"},
+			errors: []string{"Parser error at '2': expect end of expression"},
+		},
+		{
+			name:   "resyncs at statement keyword",
+			source: "-) print 4",
+			want:   []string{"(- (bad )))", "(bad print 4)"},
+			errors: []string{"Parser error at ')': expect expression", "Parser error at 'print': expect expression"},
+		},
+		{
+			name:   "missing left operand",
+			source: "= 1",
+			want:   []string{"(bad = 1)"},
+			errors: []string{"Parser error at '=': missing left-hand operand for '='"},
+		},
+		{
+			name:   "missing condition",
+			source: "? 1 : 2",
+			want:   []string{"(bad ? 1 : 2)"},
+			errors: []string{"Parser error at '?': missing left-hand operand for '?'"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsers := map[string]*Parser{
+				"recursive descent": NewParser(scan(t, tc.source)),
+				"pratt":             NewPrattParser(scan(t, tc.source), LoxOperators()),
+			}
+			for name, parser := range parsers {
+				exprs, errs := parser.ParseTolerant()
+
+				printer := ast.AstPrinter{}
+				var got []string
+				for _, expr := range exprs {
+					got = append(got, printer.PrintAst(expr))
+				}
+				if !reflect.DeepEqual(got, tc.want) {
+					t.Errorf("%s: want trees %q, got %q", name, tc.want, got)
+				}
+
+				var gotErrs []string
+				for _, err := range errs {
+					gotErrs = append(gotErrs, err.Error())
+				}
+				if !reflect.DeepEqual(gotErrs, tc.errors) {
+					t.Errorf("%s: want errors %q, got %q", name, tc.errors, gotErrs)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ConditionalAndComma(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "conditional",
+			source: "true ? 1 : 2",
+			want:   "(?: true 1 2)",
+		},
+		{
+			name:   "conditional is right associative",
+			source: "1 ? 2 : 3 ? 4 : 5",
+			want:   "(?: 1 2 (?: 3 4 5))",
+		},
+		{
+			name:   "conditional binds looser than equality",
+			source: "1 == 2 ? 3 : 4 != 5",
+			want:   "(?: (== 1 2) 3 (!= 4 5))",
+		},
+		{
+			name:   "nested conditional in then branch",
+			source: "1 ? 2 ? 3 : 4 : 5",
+			want:   "(?: 1 (?: 2 3 4) 5)",
+		},
+		{
+			name:   "comma",
+			source: "1, 2",
+			want:   "(, 1 2)",
+		},
+		{
+			name:   "comma is left associative",
+			source: "1, 2, 3",
+			want:   "(, (, 1 2) 3)",
+		},
+		{
+			name:   "comma binds loosest",
+			source: "1 ? 2 : 3, 4",
+			want:   "(, (?: 1 2 3) 4)",
+		},
+		{
+			name:   "comma allowed in then branch",
+			source: "1 ? 2, 3 : 4",
+			want:   "(?: 1 (, 2 3) 4)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			printer := ast.AstPrinter{}
+			got := printer.PrintAst(NewParser(scan(t, tc.source)).expression())
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("missing colon", func(t *testing.T) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Error("Expected panic for missing ':'")
+			}
+		}()
+		NewParser(scan(t, "1 ? 2")).expression()
+	})
+}
+
+func TestParser_Calls(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "variable",
+			source: "clock",
+			want:   "clock",
+		},
+		{
+			name:   "no arguments",
+			source: "clock()",
+			want:   "(call clock)",
+		},
+		{
+			name:   "arguments",
+			source: "max(1, 2 + 3)",
+			want:   "(call max 1 (+ 2 3))",
+		},
+		{
+			name:   "conditional argument",
+			source: "f(a ? b : c, d)",
+			want:   "(call f (?: a b c) d)",
+		},
+		{
+			name:   "comma needs parentheses",
+			source: "f((a, b))",
+			want:   "(call f (group (, a b)))",
+		},
+		{
+			name:   "chained calls",
+			source: "f(1)(2)",
+			want:   "(call (call f 1) 2)",
+		},
+		{
+			name:   "grouping called",
+			source: "(1) (2)",
+			want:   "(call (group 1) 2)",
+		},
+		{
+			name:   "call binds tighter than unary",
+			source: "-f()",
+			want:   "(- (call f))",
+		},
+		{
+			name:   "call binds tighter than binary",
+			source: "1 + f(2) * 3",
+			want:   "(+ 1 (* (call f 2) 3))",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			printer := ast.AstPrinter{}
+			got := printer.PrintAst(NewParser(scan(t, tc.source)).expression())
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	t.Run("missing closing parenthesis", func(t *testing.T) {
+		_, err := NewParser(scan(t, "f(1, 2")).Parse()
+		if err == nil {
+			t.Error("expected an error for a missing ')'")
+		}
+	})
+
+	t.Run("too many arguments", func(t *testing.T) {
+		source := "f(" + strings.Repeat("1, ", maxArguments) + "1)"
+		expr, err := NewParser(scan(t, source)).Parse()
+		if err == nil || !strings.Contains(err.Error(), "Can't have more than 255 arguments") {
+			t.Fatalf("expected an error for too many arguments, got %v", err)
+		}
+		if call, ok := expr.(*ast.Call); !ok || len(call.Arguments) != maxArguments+1 {
+			t.Errorf("expected the call to still be parsed, got %#v", expr)
+		}
+	})
+}
+
+func TestParser_Lists(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "empty list",
+			source: "[]",
+			want:   "(list)",
+		},
+		{
+			name:   "elements",
+			source: "[1, 2 + 3, \"a\"]",
+			want:   "(list 1 (+ 2 3) a)",
+		},
+		{
+			name:   "nested lists",
+			source: "[[1], []]",
+			want:   "(list (list 1) (list))",
+		},
+		{
+			name:   "index",
+			source: "xs[0]",
+			want:   "(index xs 0)",
+		},
+		{
+			name:   "index can be any expression",
+			source: "xs[1, 2]",
+			want:   "(index xs (, 1 2))",
+		},
+		{
+			name:   "chained index and call",
+			source: "f()[0][1](2)",
+			want:   "(call (index (index (call f) 0) 1) 2)",
+		},
+		{
+			name:   "index binds tighter than unary",
+			source: "-xs[0]",
+			want:   "(- (index xs 0))",
+		},
+		{
+			name:   "assignment",
+			source: "xs[0] = 1",
+			want:   "(set xs 0 1)",
+		},
+		{
+			name:   "assignment is right associative",
+			source: "xs[0] = ys[1] = 2",
+			want:   "(set xs 0 (set ys 1 2))",
+		},
+		{
+			name:   "assignment binds looser than conditional",
+			source: "xs[0] = a ? b : c",
+			want:   "(set xs 0 (?: a b c))",
+		},
+		{
+			name:   "assignment binds tighter than comma",
+			source: "xs[0] = 1, 2",
+			want:   "(, (set xs 0 1) 2)",
+		},
+		{
+			name:   "assignment in arguments",
+			source: "f(xs[0] = 1, [ys[0] = 2])",
+			want:   "(call f (set xs 0 1) (list (set ys 0 2)))",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			printer := ast.AstPrinter{}
+			got := printer.PrintAst(NewParser(scan(t, tc.source)).expression())
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	for _, source := range []string{"x = 1", "(xs[0]) = 1", "f() = 1", "1 + xs[0] = 2"} {
+		t.Run("invalid target "+source, func(t *testing.T) {
+			_, err := NewParser(scan(t, source)).Parse()
+			if err == nil || !strings.Contains(err.Error(), "Invalid assignment target") {
+				t.Fatalf("expected an invalid assignment target error, got %v", err)
+			}
+		})
+	}
+
+	for _, source := range []string{"[1, 2", "xs[0"} {
+		t.Run("unclosed "+source, func(t *testing.T) {
+			_, err := NewParser(scan(t, source)).Parse()
+			if err == nil {
+				t.Fatal("expected an error for a missing ']'")
+			}
+		})
+	}
+}
+
+func TestParser_Maps(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "empty map",
+			source: "{}",
+			want:   "(map)",
+		},
+		{
+			name:   "entries",
+			source: `{"a": 1, 2: 1 + 2, nil: [true]}`,
+			want:   "(map a 1 2 (+ 1 2) <nil> (list true))",
+		},
+		{
+			name:   "nested maps",
+			source: `{"a": {"b": {}}}`,
+			want:   "(map a (map b (map)))",
+		},
+		{
+			name:   "conditional key",
+			source: `{x ? "a" : "b": 1}`,
+			want:   "(map (?: x a b) 1)",
+		},
+		{
+			name:   "conditional value",
+			source: `{"a": x ? 1 : 2}`,
+			want:   "(map a (?: x 1 2))",
+		},
+		{
+			name:   "index and assignment",
+			source: `{"a": 1}["a"] = m["b"]`,
+			want:   "(set (map a 1) a (index m b))",
+		},
+		{
+			name:   "argument",
+			source: `f({"a": 1}, 2)`,
+			want:   "(call f (map a 1) 2)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			printer := ast.AstPrinter{}
+			got := printer.PrintAst(NewParser(scan(t, tc.source)).expression())
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	for _, source := range []string{`{"a": 1`, `{"a" 1}`, `{"a"}`, `{"a": 1,}`, `{x ? 1 : 2}`} {
+		t.Run("invalid "+source, func(t *testing.T) {
+			if _, err := NewParser(scan(t, source)).Parse(); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestParser_Properties(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "property",
+			source: "point.x",
+			want:   "(.x point)",
+		},
+		{
+			name:   "chained properties, calls and indexes",
+			source: "a.b(1).c[0].d",
+			want:   "(.d (index (.c (call (.b a) 1)) 0))",
+		},
+		{
+			name:   "property binds tighter than unary",
+			source: "-point.x * 2",
+			want:   "(* (- (.x point)) 2)",
+		},
+		{
+			name:   "assignment",
+			source: "point.x = 1",
+			want:   "(set.x point 1)",
+		},
+		{
+			name:   "assignment is right associative",
+			source: "a.x = b.y = 2",
+			want:   "(set.x a (set.y b 2))",
+		},
+		{
+			name:   "assignment to a property of an element",
+			source: "xs[0].x = 1, 2",
+			want:   "(, (set.x (index xs 0) 1) 2)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			printer := ast.AstPrinter{}
+			got := printer.PrintAst(NewParser(scan(t, tc.source)).expression())
+			if got != tc.want {
+				t.Errorf("want %q, got %q", tc.want, got)
+			}
+		})
+	}
+
+	for _, source := range []string{"point.", "point.1", "point.(x)"} {
+		t.Run("invalid "+source, func(t *testing.T) {
+			_, err := NewParser(scan(t, source)).Parse()
+			if err == nil || !strings.Contains(err.Error(), "Expect property name after '.'") {
+				t.Fatalf("expected a missing property name error, got %v", err)
+			}
+		})
+	}
+
+	t.Run("invalid target", func(t *testing.T) {
+		_, err := NewParser(scan(t, "(point.x) = 1")).Parse()
+		if err == nil || !strings.Contains(err.Error(), "Invalid assignment target") {
+			t.Fatalf("expected an invalid assignment target error, got %v", err)
 		}
 	})
 }
+
+func TestParser_MissingLeftOperand(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   string
+		errors string
+	}{
+		{
+			name:   "multiplication",
+			source: "* 3",
+			want:   "(bad * 3)",
+			errors: "Parser error at '*': missing left-hand operand for '*'",
+		},
+		{
+			name:   "equality discards a whole comparison",
+			source: "== 2 < 3",
+			want:   "(bad == 2 < 3)",
+			errors: "Parser error at '==': missing left-hand operand for '=='",
+		},
+		{
+			name:   "addition discards only a factor",
+			source: "+ 2 * 3 - 4",
+			want:   "(- (bad + 2 * 3) 4)",
+			errors: "Parser error at '+': missing left-hand operand for '+'",
+		},
+		{
+			name:   "parsing carries on after the error",
+			source: "1 + (/ 2) + 3",
+			want:   "(+ (+ 1 (group (bad / 2))) 3)",
+			errors: "Parser error at '/': missing left-hand operand for '/'",
+		},
+		{
+			name:   "conditional",
+			source: "? 1 : 2",
+			want:   "(bad ? 1 : 2)",
+			errors: "Parser error at '?': missing left-hand operand for '?'",
+		},
+		{
+			name:   "every error is reported",
+			source: "(> 1) , (!= 2)",
+			want:   "(, (group (bad > 1)) (group (bad != 2)))",
+			errors: "Parser error at '>': missing left-hand operand for '>'\nParser error at '!=': missing left-hand operand for '!='",
+		},
+		{
+			name:   "minus is still unary",
+			source: "- 3",
+			want:   "(- 3)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			parsers := map[string]*Parser{
+				"recursive descent": NewParser(scan(t, tc.source)),
+				"pratt":             NewPrattParser(scan(t, tc.source), LoxOperators()),
+			}
+			for name, parser := range parsers {
+				expr, err := parser.Parse()
+
+				printer := ast.AstPrinter{}
+				if got := printer.PrintAst(expr); got != tc.want {
+					t.Errorf("%s: want %q, got %q", name, tc.want, got)
+				}
+				gotErr := ""
+				if err != nil {
+					gotErr = err.Error()
+				}
+				if gotErr != tc.errors {
+					t.Errorf("%s: want errors %q, got %q", name, tc.errors, gotErr)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ParseReturnsUnrecoverableErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{
+			name:   "recoverable errors come first",
+			source: "* 1 + (2",
+			want:   "Parser error at '*': missing left-hand operand for '*'\nParser error at end: Expect ')' after expression",
+		},
+		{
+			name:   "trailing number",
+			source: "1 2",
+			want:   "Parser error at '2': expect end of expression",
+		},
+		{
+			name:   "trailing number after a grouping",
+			source: "(1) 2",
+			want:   "Parser error at '2': expect end of expression",
+		},
+		{
+			name:   "trailing tokens after a call",
+			source: "(1) (2) 3",
+			want:   "Parser error at '3': expect end of expression",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			parsers := map[string]*Parser{
+				"recursive descent": NewParser(scan(t, tc.source)),
+				"pratt":             NewPrattParser(scan(t, tc.source), LoxOperators()),
+			}
+			for name, parser := range parsers {
+				expr, err := parser.Parse()
+				if expr != nil {
+					t.Errorf("%s: expected nil expression, got %+v", name, expr)
+				}
+				if err == nil || err.Error() != tc.want {
+					t.Errorf("%s: expected error %q, got %v", name, tc.want, err)
+				}
+			}
+		})
+	}
+}
+
+func TestParser_ErrorsHaveTheirPosition(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		want   ParseError
+	}{
+		{
+			name:   "at a token",
+			source: "1 +\n\n*",
+			want:   ParseError{Line: 3, Lexeme: "*", Message: "missing left-hand operand for '*'"},
+		},
+		{
+			name:   "at end",
+			source: "(1\n+ 2",
+			want:   ParseError{Line: 2, AtEnd: true, Message: "Expect ')' after expression"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewParser(scan(t, tc.source)).Parse()
+			var got ParseError
+			if !errors.As(err, &got) {
+				t.Fatalf("want a ParseError, got %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("want %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParser_LimitsNesting(t *testing.T) {
+	t.Parallel()
+
+	nested := func(depth int) map[string]string {
+		return map[string]string{
+			"parentheses": strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth),
+			"negations":   strings.Repeat("-", depth) + "1",
+			"lists":       strings.Repeat("[", depth) + strings.Repeat("]", depth),
+			"additions":   strings.Repeat("1 + ", depth) + "1",
+			"assignments": strings.Repeat("a.b = ", depth) + "1",
+			"calls":       "f" + strings.Repeat("()", depth),
+		}
+	}
+
+	// the parsers count levels differently, e.g. a property and its assignment are 1 or 3 levels
+	for name, source := range nested(maxDepth / 4) {
+		parsers := map[string]*Parser{
+			"recursive descent": NewParser(scan(t, source)),
+			"pratt":             NewPrattParser(scan(t, source), LoxOperators()),
+		}
+		for parserName, parser := range parsers {
+			if _, err := parser.Parse(); err != nil {
+				t.Errorf("%s %s: %v", parserName, name, err)
+			}
+		}
+	}
+
+	for name, source := range nested(maxDepth + 1) {
+		parsers := map[string]*Parser{
+			"recursive descent": NewParser(scan(t, source)),
+			"pratt":             NewPrattParser(scan(t, source), LoxOperators()),
+		}
+		for parserName, parser := range parsers {
+			_, err := parser.Parse()
+			var parseErr ParseError
+			if !errors.As(err, &parseErr) || parseErr.Message != "Expression nesting too deep." {
+				t.Errorf("%s %s: expected a nesting error, got %v", parserName, name, err)
+			}
+		}
+	}
+
+	source := "1 + " + nested(maxDepth + 1)["parentheses"]
+	exprs, errs := NewParser(scan(t, source)).ParseTolerant()
+	if len(exprs) != 1 || len(errs) != 1 {
+		t.Fatalf("want 1 tree and 1 error, got %v and %v", exprs, errs)
+	}
+	if bad, ok := exprs[0].(*ast.BadExpr); !ok || len(bad.Tokens) != 2*(maxDepth+1)+3 {
+		t.Errorf("want all the tokens in a BadExpr, got %#v", exprs[0])
+	}
+}
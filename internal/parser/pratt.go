@@ -0,0 +1,219 @@
+package parser
+
+import (
+	"github.com/taylorlowery/lox/internal/ast"
+	"github.com/taylorlowery/lox/internal/token"
+)
+
+// Associativity determines how a chain of operators with the same binding power groups.
+type Associativity int
+
+const (
+	// LeftAssoc groups a - b - c as (a - b) - c.
+	LeftAssoc Associativity = iota
+	// RightAssoc groups a = b = c as a = (b = c).
+	RightAssoc
+)
+
+// Binding powers of the Lox operators, weakest first.
+// A binding power of 0 is reserved for "parse any expression".
+const (
+	PowerComma = iota + 1
+	PowerAssignment
+	PowerConditional
+	PowerEquality
+	PowerComparison
+	PowerTerm
+	PowerFactor
+	PowerUnary
+	PowerCall
+)
+
+// PrefixHandler parses an operator that starts an expression, e.g. the '-' in -a.
+// The operator has already been consumed.
+// rightPower is the binding power to parse the operand with.
+type PrefixHandler func(p *Parser, operator token.Token, rightPower int) ast.Expr
+
+// InfixHandler parses an operator that sits between two operands, e.g. the '+' in a + b.
+// The left operand and the operator have already been consumed.
+// rightPower is the binding power to parse the right operand with,
+// already adjusted for the operator's associativity.
+type InfixHandler func(p *Parser, left ast.Expr, operator token.Token, rightPower int) ast.Expr
+
+// PostfixHandler parses an operator that follows its operand.
+// The operand and the operator have already been consumed.
+type PostfixHandler func(p *Parser, left ast.Expr, operator token.Token) ast.Expr
+
+type prefixRule struct {
+	power   int
+	handler PrefixHandler
+}
+
+type infixRule struct {
+	power   int
+	assoc   Associativity
+	infix   InfixHandler
+	postfix PostfixHandler
+}
+
+// rightPower is the binding power to parse an infix operator's right operand with.
+func (r infixRule) rightPower() int {
+	if r.assoc == RightAssoc {
+		return r.power
+	}
+	return r.power + 1
+}
+
+// OperatorTable maps token types to the operators a Pratt parser understands.
+// A token type can have both a prefix rule and an infix or postfix rule,
+// as '-' does in Lox.
+type OperatorTable struct {
+	prefix map[token.TokenType]prefixRule
+	infix  map[token.TokenType]infixRule
+}
+
+// NewOperatorTable returns an empty OperatorTable.
+func NewOperatorTable() *OperatorTable {
+	return &OperatorTable{
+		prefix: map[token.TokenType]prefixRule{},
+		infix:  map[token.TokenType]infixRule{},
+	}
+}
+
+// Prefix registers a prefix operator, replacing any previous prefix rule for the token type.
+func (t *OperatorTable) Prefix(tokenType token.TokenType, power int, handler PrefixHandler) {
+	t.prefix[tokenType] = prefixRule{power: power, handler: handler}
+}
+
+// Infix registers an infix operator,
+// replacing any previous infix or postfix rule for the token type.
+func (t *OperatorTable) Infix(tokenType token.TokenType, power int, assoc Associativity, handler InfixHandler) {
+	t.infix[tokenType] = infixRule{power: power, assoc: assoc, infix: handler}
+}
+
+// Postfix registers a postfix operator,
+// replacing any previous infix or postfix rule for the token type.
+func (t *OperatorTable) Postfix(tokenType token.TokenType, power int, handler PostfixHandler) {
+	t.infix[tokenType] = infixRule{power: power, postfix: handler}
+}
+
+// LoxOperators returns the operator table for the Lox expression grammar,
+// producing the same trees as the recursive descent methods.
+func LoxOperators() *OperatorTable {
+	t := NewOperatorTable()
+	t.Infix(token.COMMA, PowerComma, LeftAssoc, BinaryHandler)
+	t.Infix(token.EQUAL, PowerAssignment, RightAssoc, AssignmentHandler)
+	t.Infix(token.QUESTION, PowerConditional, RightAssoc, ConditionalHandler)
+	for _, tt := range []token.TokenType{token.BANG_EQUAL, token.EQUAL_EQUAL} {
+		t.Infix(tt, PowerEquality, LeftAssoc, BinaryHandler)
+	}
+	for _, tt := range []token.TokenType{token.GREATER, token.GREATER_EQUAL, token.LESS, token.LESS_EQUAL} {
+		t.Infix(tt, PowerComparison, LeftAssoc, BinaryHandler)
+	}
+	for _, tt := range []token.TokenType{token.MINUS, token.PLUS} {
+		t.Infix(tt, PowerTerm, LeftAssoc, BinaryHandler)
+	}
+	for _, tt := range []token.TokenType{token.SLASH, token.STAR} {
+		t.Infix(tt, PowerFactor, LeftAssoc, BinaryHandler)
+	}
+	for _, tt := range []token.TokenType{token.BANG, token.MINUS} {
+		t.Prefix(tt, PowerUnary, UnaryHandler)
+	}
+	t.Postfix(token.LEFT_PAREN, PowerCall, CallHandler)
+	t.Postfix(token.LEFT_BRACKET, PowerCall, IndexHandler)
+	t.Postfix(token.DOT, PowerCall, PropertyHandler)
+	return t
+}
+
+// BinaryHandler is an InfixHandler that produces an ast.Binary.
+func BinaryHandler(p *Parser, left ast.Expr, operator token.Token, rightPower int) ast.Expr {
+	return &ast.Binary{
+		Left:     left,
+		Operator: operator,
+		Right:    p.ParsePrecedence(rightPower),
+	}
+}
+
+// AssignmentHandler is an InfixHandler for '=' that produces an ast.SetIndex
+// or an ast.SetProperty. Its left operand must be an element of a list or a property.
+func AssignmentHandler(p *Parser, left ast.Expr, operator token.Token, rightPower int) ast.Expr {
+	return p.assign(left, operator, p.ParsePrecedence(rightPower))
+}
+
+// ConditionalHandler is an InfixHandler for the ternary '?' operator
+// that produces an ast.Conditional. Its middle operand can be any expression.
+func ConditionalHandler(p *Parser, left ast.Expr, operator token.Token, rightPower int) ast.Expr {
+	thenBranch := p.ParsePrecedence(0)
+	p.consume(token.COLON, "Expect ':' after then branch of conditional expression")
+	return &ast.Conditional{
+		Condition:  left,
+		ThenBranch: thenBranch,
+		ElseBranch: p.ParsePrecedence(rightPower),
+	}
+}
+
+// UnaryHandler is a PrefixHandler that produces an ast.Unary.
+func UnaryHandler(p *Parser, operator token.Token, rightPower int) ast.Expr {
+	return &ast.Unary{
+		Operator: operator,
+		Right:    p.ParsePrecedence(rightPower),
+	}
+}
+
+// CallHandler is a PostfixHandler for the '(' of a call that produces an ast.Call.
+func CallHandler(p *Parser, left ast.Expr, operator token.Token) ast.Expr {
+	return p.finishCall(left)
+}
+
+// IndexHandler is a PostfixHandler for the '[' of an index that produces an ast.Index.
+func IndexHandler(p *Parser, left ast.Expr, operator token.Token) ast.Expr {
+	return p.finishIndex(left)
+}
+
+// PropertyHandler is a PostfixHandler for the '.' of a property that produces an ast.Property.
+func PropertyHandler(p *Parser, left ast.Expr, operator token.Token) ast.Expr {
+	return p.finishProperty(left)
+}
+
+// NewPrattParser creates a new Parser with the given tokens
+// whose expressions are parsed by a Pratt parser driven by the given operator table,
+// instead of the recursive descent methods.
+// Operands that aren't operators are parsed by primary, as before.
+func NewPrattParser(tokens []token.Token, operators *OperatorTable) *Parser {
+	p := NewParser(tokens)
+	p.operators = operators
+	return p
+}
+
+// ParsePrecedence parses an expression whose operators all bind
+// at least as tightly as minPower. It is meant to be called from operator handlers.
+// Like the rest of the parser, it panics on syntax errors.
+// An infix operator with no left operand is reported, and its right operand
+// parsed and returned in an ast.BadExpr, as the recursive descent parser does.
+func (p *Parser) ParsePrecedence(minPower int) ast.Expr {
+	defer p.leave(p.depth)
+	p.nest()
+	var left ast.Expr
+	if rule, ok := p.operators.prefix[p.peek().TokenType]; ok && !p.isAtEnd() {
+		operator := p.advance()
+		left = rule.handler(p, operator, rule.power)
+	} else if operator, ok := p.matchMissingLeftOperand(); ok {
+		left = p.missingLeftOperand(operator)
+	} else {
+		left = p.primary()
+	}
+
+	for {
+		rule, ok := p.operators.infix[p.peek().TokenType]
+		if !ok || p.isAtEnd() || rule.power < minPower {
+			return left
+		}
+		operator := p.advance()
+		p.nest()
+		if rule.postfix != nil {
+			left = rule.postfix(p, left, operator)
+			continue
+		}
+		left = rule.infix(p, left, operator, rule.rightPower())
+	}
+}
@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/ast"
+	"github.com/taylorlowery/lox/internal/scanner"
+	"github.com/taylorlowery/lox/internal/token"
+)
+
+func scan(t *testing.T, source string) []token.Token {
+	t.Helper()
+	tokens, err := scanner.NewScanner(source).ScanTokens()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tokens
+}
+
+// parserCases are the expressions both parsers are tested on:
+// every one from the recursive descent tests, plus a few more,
+// with the tree each parses to, as AstPrinter prints it.
+var parserCases = []struct {
+	source string
+	want   string
+}{
+	{"42", "42"},
+	{`"hello"`, "hello"},
+	{"true", "true"},
+	{"false", "false"},
+	{"nil", "<nil>"},
+	{"(42)", "(group 42)"},
+	{"-42", "(- 42)"},
+	{"!true", "(! true)"},
+	{"--42", "(- (- 42))"},
+	{"6 * 7", "(* 6 7)"},
+	{"10 / 2", "(/ 10 2)"},
+	{"2 * 3 * 4", "(* (* 2 3) 4)"},
+	{"1 + 2", "(+ 1 2)"},
+	{"5 - 3", "(- 5 3)"},
+	{"1 + 2 + 3", "(+ (+ 1 2) 3)"},
+	{"5 > 3", "(> 5 3)"},
+	{"5 >= 5", "(>= 5 5)"},
+	{"3 < 5", "(< 3 5)"},
+	{"3 <= 5", "(<= 3 5)"},
+	{"1 == 1", "(== 1 1)"},
+	{"1 != 2", "(!= 1 2)"},
+	{"2 + 3 * 4", "(+ 2 (* 3 4))"},
+	{"-2 * 3", "(* (- 2) 3)"},
+	{"1 < 2 == true", "(== (< 1 2) true)"},
+	{"(1 + 2) * 3", "(* (group (+ 1 2)) 3)"},
+	{"((42))", "(group (group 42))"},
+	{"1 + 2 * 3 - 4 / 2", "(- (+ 1 (* 2 3)) (/ 4 2))"},
+	{"1 + 2 > 3 == false", "(== (> (+ 1 2) 3) false)"},
+	{"!!-42", "(! (! (- 42)))"},
+	{"(((42)))", "(group (group (group 42)))"},
+	{`"hello" + 42`, "(+ hello 42)"},
+	{"1 == 2 != 3 == 4", "(== (!= (== 1 2) 3) 4)"},
+	{"1 - -2", "(- 1 (- 2))"},
+	{"-1 - 2 / -(3 + 4) >= !nil != 5 < 6", "(!= (>= (- (- 1) (/ 2 (- (group (+ 3 4))))) (! <nil>)) (< 5 6))"},
+	{"1, 2", "(, 1 2)"},
+	{"1, 2, 3", "(, (, 1 2) 3)"},
+	{"true ? 1 : 2", "(?: true 1 2)"},
+	{"1 ? 2 : 3 ? 4 : 5", "(?: 1 2 (?: 3 4 5))"},
+	{"1 == 2 ? 3 + 4 : 5 * 6", "(?: (== 1 2) (+ 3 4) (* 5 6))"},
+	{"1 ? 2, 3 : 4, 5", "(, (?: 1 (, 2 3) 4) 5)"},
+	{"1 ? 2 ? 3 : 4 : 5", "(?: 1 (?: 2 3 4) 5)"},
+	{"-1 ? !2 : 3 == 4", "(?: (- 1) (! 2) (== 3 4))"},
+	{"clock", "clock"},
+	{"clock()", "(call clock)"},
+	{"max(1, 2 + 3)", "(call max 1 (+ 2 3))"},
+	{"f(a ? b : c, (d, e))", "(call f (?: a b c) (group (, d e)))"},
+	{"f(1)(2)", "(call (call f 1) 2)"},
+	{"-f() * g(!x)", "(* (- (call f)) (call g (! x)))"},
+	{"[]", "(list)"},
+	{"[1, [2, 3], f(4)]", "(list 1 (list 2 3) (call f 4))"},
+	{"xs[0][1]", "(index (index xs 0) 1)"},
+	{"-xs[i + 1] * 2", "(* (- (index xs (+ i 1))) 2)"},
+	{"xs[0] = ys[1] = 2, 3", "(, (set xs 0 (set ys 1 2)) 3)"},
+	{"xs[0] = a ? b : c", "(set xs 0 (?: a b c))"},
+	{"f(xs[0] = 1, [ys[0] = 2])", "(call f (set xs 0 1) (list (set ys 0 2)))"},
+	{"{}", "(map)"},
+	{`{"a": 1 + 2, (x ? 1 : 2): {"b": []}}`, "(map a (+ 1 2) (group (?: x 1 2)) (map b (list)))"},
+	{`-{"a": 1}["a"] * 2`, "(* (- (index (map a 1) a)) 2)"},
+	{"a.b(1).c[0].d", "(.d (index (.c (call (.b a) 1)) 0))"},
+	{"-point.x * 2", "(* (- (.x point)) 2)"},
+	{"a.x = xs[0].y = 2, 3", "(, (set.x a (set.y (index xs 0) 2)) 3)"},
+}
+
+func TestPrattParser_MatchesRecursiveDescent(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range parserCases {
+		t.Run(tc.source, func(t *testing.T) {
+			printer := ast.AstPrinter{}
+			want := NewParser(scan(t, tc.source)).expression()
+			if got := printer.PrintAst(want); got != tc.want {
+				t.Fatalf("recursive descent: want %q, got %q", tc.want, got)
+			}
+			got := NewPrattParser(scan(t, tc.source), LoxOperators()).expression()
+			if !ast.Equal(want, got) {
+				t.Errorf("the parsers disagree: %s", ast.Diff(want, got))
+			}
+		})
+	}
+}
+
+func TestPrattParser_PanicsOnSyntaxErrors(t *testing.T) {
+	t.Parallel()
+
+	sources := []string{
+		"(42",
+		"1 +",
+		"-",
+		")",
+		"1 ? 2",
+		"1 ? 2 : ",
+	}
+
+	for _, source := range sources {
+		t.Run(source, func(t *testing.T) {
+			defer func() {
+				if r := recover(); r == nil {
+					t.Errorf("expected panic for %q", source)
+				}
+			}()
+			NewPrattParser(scan(t, source), LoxOperators()).expression()
+		})
+	}
+}
+
+func TestPrattParser_RightAssociativeOperator(t *testing.T) {
+	t.Parallel()
+
+	operators := LoxOperators()
+	operators.Infix(token.STAR, PowerFactor, RightAssoc, BinaryHandler)
+
+	got := NewPrattParser(scan(t, "2 * 3 * 4"), operators).expression()
+	want := &ast.Binary{
+		Left:     &ast.Literal{Value: 2.0},
+		Operator: token.Token{TokenType: token.STAR, Lexeme: "*", Line: 1},
+		Right: &ast.Binary{
+			Left:     &ast.Literal{Value: 3.0},
+			Operator: token.Token{TokenType: token.STAR, Lexeme: "*", Line: 1},
+			Right:    &ast.Literal{Value: 4.0},
+		},
+	}
+	if diff := ast.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestPrattParser_PostfixOperator(t *testing.T) {
+	t.Parallel()
+
+	operators := LoxOperators()
+	operators.Postfix(token.BANG, PowerUnary+1, func(p *Parser, left ast.Expr, operator token.Token) ast.Expr {
+		return &ast.Unary{
+			Operator: operator,
+			Right:    left,
+		}
+	})
+
+	// !3! * 2 parses as (!(3!)) * 2: postfix binds tighter than prefix.
+	got := NewPrattParser(scan(t, "!3! * 2"), operators).expression()
+	bang := token.Token{TokenType: token.BANG, Lexeme: "!", Line: 1}
+	want := &ast.Binary{
+		Left: &ast.Unary{
+			Operator: bang,
+			Right: &ast.Unary{
+				Operator: bang,
+				Right:    &ast.Literal{Value: 3.0},
+			},
+		},
+		Operator: token.Token{TokenType: token.STAR, Lexeme: "*", Line: 1},
+		Right:    &ast.Literal{Value: 2.0},
+	}
+	if diff := ast.Diff(want, got); diff != "" {
+		t.Error(diff)
+	}
+}
+
+func TestPrattParser_ParseRepanicsHandlerErrors(t *testing.T) {
+	t.Parallel()
+
+	bug := errors.New("handler bug")
+	operators := LoxOperators()
+	operators.Prefix(token.BANG, PowerUnary, func(p *Parser, operator token.Token, rightPower int) ast.Expr {
+		panic(bug)
+	})
+
+	defer func() {
+		if r := recover(); r != bug {
+			t.Errorf("want the handler's panic %v, got %v", bug, r)
+		}
+	}()
+	NewPrattParser(scan(t, "!true"), operators).Parse()
+}
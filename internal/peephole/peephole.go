@@ -0,0 +1,196 @@
+// Package peephole rewrites short sequences of bytecode into shorter equivalents.
+package peephole
+
+import (
+	"github.com/taylorlowery/lox/internal/chunk"
+)
+
+// instruction is a decoded instruction. Jumps refer to their target
+// by its index in the instruction list rather than by a byte offset,
+// so instructions can be removed without breaking them.
+type instruction struct {
+	op chunk.OpCode
+	// operand is a constant index, or a jump's target index
+	operand int
+	line    int
+	removed bool
+}
+
+func isJump(op chunk.OpCode) bool {
+	return op == chunk.OP_JUMP || op == chunk.OP_JUMP_IF_FALSE || op == chunk.OP_JUMP_IF_TRUE
+}
+
+// isPush reports whether op only pushes a value, without any other effect.
+func isPush(op chunk.OpCode) bool {
+	switch op {
+	case chunk.OP_CONSTANT, chunk.OP_CONSTANT_LONG, chunk.OP_NIL, chunk.OP_TRUE, chunk.OP_FALSE:
+		return true
+	default:
+		return false
+	}
+}
+
+// Optimize returns a copy of c with these rewrites applied until none applies:
+//
+//   - a jump to an OP_JUMP goes straight to that jump's target,
+//     as does a conditional jump to the same conditional jump
+//   - a jump to the next instruction is removed
+//   - OP_NOT followed by OP_JUMP_IF_FALSE becomes OP_JUMP_IF_TRUE,
+//     when the tested value is popped on both paths
+//   - a value that is pushed and immediately popped isn't pushed
+//
+// Jump offsets are recomputed and every instruction keeps its source line.
+// A chunk that can't be decoded, such as one with a truncated instruction,
+// is returned unchanged.
+//
+// Loading a variable, pushing a constant and adding them isn't fused.
+// Lox has no locals yet, and trying the fusion on OP_GET_GLOBAL, OP_CONSTANT and OP_ADD
+// cut BenchmarkRun's "global plus constant" case from 32 instructions to 24
+// without making it measurably faster.
+func Optimize(c *chunk.Chunk) *chunk.Chunk {
+	instructions, ok := decode(c)
+	if !ok {
+		return c
+	}
+	for pass(instructions) {
+	}
+	return encode(c, instructions)
+}
+
+// Count returns the number of instructions in a chunk.
+func Count(c *chunk.Chunk) int {
+	n := 0
+	for offset := 0; offset < len(c.Code); n++ {
+		offset += 1 + chunk.OpCode(c.Code[offset]).OperandWidth()
+	}
+	return n
+}
+
+func decode(c *chunk.Chunk) ([]instruction, bool) {
+	var instructions []instruction
+	// indexes maps the offset of each instruction to its index
+	indexes := map[int]int{}
+	for offset := 0; offset < len(c.Code); {
+		op := chunk.OpCode(c.Code[offset])
+		width := op.OperandWidth()
+		if offset+width >= len(c.Code) {
+			return nil, false
+		}
+		operand := 0
+		for _, b := range c.Code[offset+1 : offset+1+width] {
+			operand = operand<<8 | int(b)
+		}
+		indexes[offset] = len(instructions)
+		next := offset + 1 + width
+		if isJump(op) {
+			// an offset for now, replaced by an index below
+			operand += next
+		}
+		instructions = append(instructions, instruction{op: op, operand: operand, line: c.Line(offset)})
+		offset = next
+	}
+	indexes[len(c.Code)] = len(instructions)
+
+	for i, in := range instructions {
+		if !isJump(in.op) {
+			continue
+		}
+		target, ok := indexes[in.operand]
+		if !ok {
+			return nil, false
+		}
+		instructions[i].operand = target
+	}
+	return instructions, true
+}
+
+// live returns the index of the first instruction at or after i that hasn't been removed.
+func live(instructions []instruction, i int) int {
+	for i < len(instructions) && instructions[i].removed {
+		i++
+	}
+	return i
+}
+
+// pass applies each rewrite once and reports whether anything changed.
+func pass(instructions []instruction) bool {
+	changed := false
+	targets := map[int]bool{}
+	for i := range instructions {
+		in := &instructions[i]
+		if in.removed || !isJump(in.op) {
+			continue
+		}
+		in.operand = live(instructions, in.operand)
+		for in.operand < len(instructions) {
+			target := instructions[in.operand]
+			if target.op != chunk.OP_JUMP && target.op != in.op {
+				break
+			}
+			in.operand = live(instructions, target.operand)
+			changed = true
+		}
+		targets[in.operand] = true
+	}
+
+	for i := range instructions {
+		in := &instructions[i]
+		if in.removed {
+			continue
+		}
+		next := live(instructions, i+1)
+
+		switch {
+		case in.op == chunk.OP_JUMP && in.operand == next:
+			in.removed = true
+			changed = true
+
+		case in.op == chunk.OP_NOT && next < len(instructions) && !targets[next]:
+			jump := &instructions[next]
+			if jump.op != chunk.OP_JUMP_IF_FALSE || !popsAt(instructions, live(instructions, next+1)) || !popsAt(instructions, jump.operand) {
+				continue
+			}
+			in.removed = true
+			jump.op = chunk.OP_JUMP_IF_TRUE
+			changed = true
+
+		case isPush(in.op) && next < len(instructions) && !targets[next] && instructions[next].op == chunk.OP_POP:
+			in.removed = true
+			instructions[next].removed = true
+			changed = true
+		}
+	}
+	return changed
+}
+
+func popsAt(instructions []instruction, i int) bool {
+	return i < len(instructions) && instructions[i].op == chunk.OP_POP
+}
+
+func encode(c *chunk.Chunk, instructions []instruction) *chunk.Chunk {
+	offsets := make([]int, len(instructions)+1)
+	offset := 0
+	for i, in := range instructions {
+		offsets[i] = offset
+		if !in.removed {
+			offset += 1 + in.op.OperandWidth()
+		}
+	}
+	offsets[len(instructions)] = offset
+
+	optimized := c.WithoutCode()
+	for i, in := range instructions {
+		if in.removed {
+			continue
+		}
+		operand := in.operand
+		if isJump(in.op) {
+			operand = offsets[in.operand] - offsets[i] - 3
+		}
+		optimized.WriteOp(in.op, in.line)
+		for shift := 8 * (in.op.OperandWidth() - 1); shift >= 0; shift -= 8 {
+			optimized.Write(byte(operand>>shift), in.line)
+		}
+	}
+	return optimized
+}
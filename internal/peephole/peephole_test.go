@@ -0,0 +1,246 @@
+package peephole_test
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/chunk"
+	"github.com/taylorlowery/lox/internal/compiler"
+	"github.com/taylorlowery/lox/internal/parser"
+	"github.com/taylorlowery/lox/internal/peephole"
+	"github.com/taylorlowery/lox/internal/scanner"
+	"github.com/taylorlowery/lox/internal/value"
+	"github.com/taylorlowery/lox/internal/vm"
+)
+
+func compile(t testing.TB, source string) *chunk.Chunk {
+	t.Helper()
+	tokens, scanErr := scanner.NewScanner(source).ScanTokens()
+	if scanErr != nil {
+		t.Fatal(scanErr)
+	}
+	expr, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := compiler.Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func run(t testing.TB, c *chunk.Chunk) string {
+	t.Helper()
+	machine, err := vm.NewVM(vm.WithGlobals(map[string]any{"pi": 3.14}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := machine.Run(c)
+	if err != nil {
+		return err.Error()
+	}
+	return value.Stringify(result)
+}
+
+func op(o chunk.OpCode) byte {
+	return byte(o)
+}
+
+func TestOptimize_RewritesSequences(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		code   []byte
+	}{
+		{
+			name:   "push then pop",
+			source: "nil, true",
+			code:   []byte{op(chunk.OP_TRUE), op(chunk.OP_RETURN)},
+		},
+		{
+			name:   "chained push then pop",
+			source: "1, 2, 3",
+			code:   []byte{op(chunk.OP_CONSTANT), 2, op(chunk.OP_RETURN)},
+		},
+		{
+			name:   "not then jump",
+			source: "!nil ? 1 : 2",
+			code: []byte{
+				op(chunk.OP_NIL),
+				op(chunk.OP_JUMP_IF_TRUE), 0, 6,
+				op(chunk.OP_POP),
+				op(chunk.OP_CONSTANT), 0,
+				op(chunk.OP_JUMP), 0, 3,
+				op(chunk.OP_POP),
+				op(chunk.OP_CONSTANT), 1,
+				op(chunk.OP_RETURN),
+			},
+		},
+		{
+			name:   "jump to jump",
+			source: "nil ? (nil ? 1 : 2) : 3",
+			code: []byte{
+				op(chunk.OP_NIL),
+				op(chunk.OP_JUMP_IF_FALSE), 0, 17,
+				op(chunk.OP_POP),
+				op(chunk.OP_NIL),
+				op(chunk.OP_JUMP_IF_FALSE), 0, 6,
+				op(chunk.OP_POP),
+				op(chunk.OP_CONSTANT), 0,
+				// straight to the end instead of to the outer jump
+				op(chunk.OP_JUMP), 0, 9,
+				op(chunk.OP_POP),
+				op(chunk.OP_CONSTANT), 1,
+				op(chunk.OP_JUMP), 0, 3,
+				op(chunk.OP_POP),
+				op(chunk.OP_CONSTANT), 2,
+				op(chunk.OP_RETURN),
+			},
+		},
+		{
+			name:   "leaves other code alone",
+			source: "-(1 + 2)",
+			code:   []byte{op(chunk.OP_CONSTANT), 0, op(chunk.OP_CONSTANT), 1, op(chunk.OP_ADD), op(chunk.OP_NEGATE), op(chunk.OP_RETURN)},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			c := compile(t, tt.source)
+			before := slices.Clone(c.Code)
+
+			got := peephole.Optimize(c)
+			if !bytes.Equal(got.Code, tt.code) {
+				var want, gotListing bytes.Buffer
+				wantChunk := got.WithoutCode()
+				wantChunk.Code = tt.code
+				chunk.Disassemble(&want, wantChunk, "want")
+				chunk.Disassemble(&gotListing, got, "got")
+				t.Errorf("%s\n%s", want.String(), gotListing.String())
+			}
+			if !bytes.Equal(c.Code, before) {
+				t.Error("input chunk changed")
+			}
+			if want, got := run(t, c), run(t, got); got != want {
+				t.Errorf("want result %s, got %s", want, got)
+			}
+		})
+	}
+}
+
+func TestOptimize_ThreadsConditionalJumps(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	c.WriteOp(chunk.OP_FALSE, 1)
+	c.WriteOp(chunk.OP_JUMP_IF_FALSE, 1)
+	c.Write(0, 1)
+	c.Write(1, 1)
+	c.WriteOp(chunk.OP_NIL, 1)
+	// the false that jumped here is still on the stack, so this jumps too
+	c.WriteOp(chunk.OP_JUMP_IF_FALSE, 1)
+	c.Write(0, 1)
+	c.Write(1, 1)
+	c.WriteOp(chunk.OP_TRUE, 1)
+	c.WriteOp(chunk.OP_RETURN, 1)
+
+	got := peephole.Optimize(c)
+	want := []byte{
+		op(chunk.OP_FALSE),
+		op(chunk.OP_JUMP_IF_FALSE), 0, 5,
+		op(chunk.OP_NIL),
+		op(chunk.OP_JUMP_IF_FALSE), 0, 1,
+		op(chunk.OP_TRUE),
+		op(chunk.OP_RETURN),
+	}
+	if !bytes.Equal(got.Code, want) {
+		t.Fatalf("want %v, got %v", want, got.Code)
+	}
+	if result := run(t, got); result != "false" {
+		t.Fatalf("want false, got %s", result)
+	}
+}
+
+func TestOptimize_KeepsLines(t *testing.T) {
+	t.Parallel()
+
+	c := compile(t, "!true\n?\n1\n:\n\"a\"\n-\n2")
+	got := peephole.Optimize(c)
+
+	// OP_TRUE, OP_NOT, then the jump that gets fused with it
+	if got.Code[1] != op(chunk.OP_JUMP_IF_TRUE) {
+		t.Fatalf("expected the jumps to be fused, got %v", got.Code)
+	}
+	if want, line := c.Line(2), got.Line(1); line != want {
+		t.Errorf("want the jump on line %d, got %d", want, line)
+	}
+	for i := range got.Code {
+		if got.Line(i) == 0 {
+			t.Errorf("no line for offset %d", i)
+		}
+	}
+
+	machine, err := vm.NewVM(vm.WithGlobals(map[string]any{"pi": 3.14}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = machine.Run(got)
+	runtimeErr, ok := err.(vm.RuntimeError)
+	if !ok || runtimeErr.Line != 6 {
+		t.Fatalf("want a runtime error on line 6, got %v", err)
+	}
+}
+
+func TestOptimize_LeavesTruncatedCodeAlone(t *testing.T) {
+	t.Parallel()
+
+	c := chunk.NewChunk()
+	c.WriteOp(chunk.OP_JUMP, 1)
+	c.Write(0, 1)
+	if got := peephole.Optimize(c); got != c {
+		t.Fatal("expected the chunk back unchanged")
+	}
+}
+
+var benchmarks = []struct {
+	name   string
+	source string
+}{
+	{name: "comma", source: "1, 2, 3, 4, 5, 6, 7, 8, \"a\" + \"b\""},
+	{name: "global plus constant", source: "pi + 1, pi + 2, pi + 3, pi + 4, pi + 5, pi + 6, pi + 7, pi + 8"},
+	{name: "nested conditionals", source: "!nil ? (!false ? (nil ? 1 : 2) : 3) : (!true ? 4 : 5)"},
+}
+
+// BenchmarkRun runs programs with and without the peephole pass,
+// reporting how many instructions each chunk has.
+func BenchmarkRun(b *testing.B) {
+	for _, bm := range benchmarks {
+		unoptimized := compile(b, bm.source)
+		optimized := peephole.Optimize(unoptimized)
+		for _, variant := range []struct {
+			name  string
+			chunk *chunk.Chunk
+		}{
+			{name: "before", chunk: unoptimized},
+			{name: "after", chunk: optimized},
+		} {
+			b.Run(bm.name+"/"+variant.name, func(b *testing.B) {
+				machine, err := vm.NewVM(vm.WithGlobals(map[string]any{"pi": 3.14}))
+				if err != nil {
+					b.Fatal(err)
+				}
+				for b.Loop() {
+					if _, err := machine.Run(variant.chunk); err != nil {
+						b.Fatal(err)
+					}
+				}
+				b.ReportMetric(float64(peephole.Count(variant.chunk)), "instructions")
+			})
+		}
+	}
+}
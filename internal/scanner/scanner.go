@@ -201,6 +201,10 @@ func (s *Scanner) scanToken() *ScannerError {
 		s.addToken(token.LEFT_BRACE, nil)
 	case '}':
 		s.addToken(token.RIGHT_BRACE, nil)
+	case '[':
+		s.addToken(token.LEFT_BRACKET, nil)
+	case ']':
+		s.addToken(token.RIGHT_BRACKET, nil)
 	case ',':
 		s.addToken(token.COMMA, nil)
 	case '.':
@@ -213,6 +217,10 @@ func (s *Scanner) scanToken() *ScannerError {
 		s.addToken(token.SEMICOLON, nil)
 	case '*':
 		s.addToken(token.STAR, nil)
+	case '?':
+		s.addToken(token.QUESTION, nil)
+	case ':':
+		s.addToken(token.COLON, nil)
 	case '!':
 		if s.match('=') {
 			s.addToken(token.BANG_EQUAL, nil)
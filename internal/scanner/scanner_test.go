@@ -29,6 +29,12 @@ func TestScanner_SingleCharacterTokens_CorrectlyScanned(t *testing.T) {
 			output:    "LEFT_BRACE RIGHT_BRACE EOF",
 			expectErr: false,
 		},
+		{
+			name:      "brackets",
+			source:    "[]",
+			output:    "LEFT_BRACKET RIGHT_BRACKET EOF",
+			expectErr: false,
+		},
 		{
 			name:      "comma",
 			source:    ",",
@@ -65,10 +71,22 @@ func TestScanner_SingleCharacterTokens_CorrectlyScanned(t *testing.T) {
 			output:    "STAR EOF",
 			expectErr: false,
 		},
+		{
+			name:      "question",
+			source:    "?",
+			output:    "QUESTION EOF",
+			expectErr: false,
+		},
+		{
+			name:      "colon",
+			source:    ":",
+			output:    "COLON EOF",
+			expectErr: false,
+		},
 		{
 			name:      "all single characters",
-			source:    "(){},.-+;*",
-			output:    "LEFT_PAREN RIGHT_PAREN LEFT_BRACE RIGHT_BRACE COMMA DOT MINUS PLUS SEMICOLON STAR EOF",
+			source:    "(){}[],.-+;*?:",
+			output:    "LEFT_PAREN RIGHT_PAREN LEFT_BRACE RIGHT_BRACE LEFT_BRACKET RIGHT_BRACKET COMMA DOT MINUS PLUS SEMICOLON STAR QUESTION COLON EOF",
 			expectErr: false,
 		},
 	}
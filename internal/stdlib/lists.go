@@ -0,0 +1,129 @@
+package stdlib
+
+import (
+	"slices"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// Lists is the module of list functions.
+// Those that change a list change it in place.
+var Lists = Module{
+	Name: "Lists",
+	Doc:  "List functions. Indexes start at 0. Functions that add to or remove from a list change it in place.",
+	Functions: []Function{
+		{
+			Name:   "push",
+			Params: []string{"list", "value"},
+			Doc:    "Adds `value` to the end of `list`, and returns the list.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				l, err := listArg("push", args, 0)
+				if err != nil {
+					return nil, err
+				}
+				if err := alloc.Allocate(value.SlotSize); err != nil {
+					return nil, err
+				}
+				l.Elements = append(l.Elements, args[1])
+				return l, nil
+			},
+		},
+		{
+			Name:   "pop",
+			Params: []string{"list"},
+			Doc:    "Removes the last element of `list` and returns it.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				l, err := listArg("pop", args, 0)
+				if err != nil {
+					return nil, err
+				}
+				if len(l.Elements) == 0 {
+					return nil, argError("pop", 0, "the list is empty")
+				}
+				last := l.Elements[len(l.Elements)-1]
+				l.Elements = l.Elements[:len(l.Elements)-1]
+				return last, nil
+			},
+		},
+		{
+			Name:   "insert",
+			Params: []string{"list", "index", "value"},
+			Doc:    "Inserts `value` into `list` at `index`, moving the elements from there on up by one, and returns the list.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				l, err := listArg("insert", args, 0)
+				if err != nil {
+					return nil, err
+				}
+				index, err := indexArg("insert", args, 1, len(l.Elements))
+				if err != nil {
+					return nil, err
+				}
+				if err := alloc.Allocate(value.SlotSize); err != nil {
+					return nil, err
+				}
+				l.Elements = slices.Insert(l.Elements, index, args[2])
+				return l, nil
+			},
+		},
+		{
+			Name:   "remove",
+			Params: []string{"list", "index"},
+			Doc:    "Removes the element at `index` from `list`, moving the elements after it down by one, and returns it.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				l, err := listArg("remove", args, 0)
+				if err != nil {
+					return nil, err
+				}
+				if len(l.Elements) == 0 {
+					return nil, argError("remove", 0, "the list is empty")
+				}
+				index, err := indexArg("remove", args, 1, len(l.Elements)-1)
+				if err != nil {
+					return nil, err
+				}
+				removed := l.Elements[index]
+				l.Elements = slices.Delete(l.Elements, index, index+1)
+				return removed, nil
+			},
+		},
+		{
+			Name:   "slice",
+			Params: []string{"list", "start", "end?"},
+			Doc:    "Returns a new list of the elements of `list` from index `start` up to but not including `end`, which defaults to the length of `list`.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				l, err := listArg("slice", args, 0)
+				if err != nil {
+					return nil, err
+				}
+				start, end, err := bounds("slice", args, len(l.Elements))
+				if err != nil {
+					return nil, err
+				}
+				if err := allocateList(alloc, end-start); err != nil {
+					return nil, err
+				}
+				return value.NewList(slices.Clone(l.Elements[start:end])...), nil
+			},
+		},
+	},
+}
+
+// bounds checks the start and optional end arguments of a function like substring,
+// which follow the thing of length n they index.
+func bounds(name string, args []any, n int) (int, int, error) {
+	start, err := indexArg(name, args, 1, n)
+	if err != nil {
+		return 0, 0, err
+	}
+	end := n
+	if len(args) > 2 {
+		end, err = indexArg(name, args, 2, n)
+		if err != nil {
+			return 0, 0, err
+		}
+		if end < start {
+			return 0, 0, argError(name, 2, "end %d is before start %d", end, start)
+		}
+	}
+	return start, end, nil
+}
@@ -0,0 +1,70 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+func TestLists(t *testing.T) {
+	t.Parallel()
+
+	runCalls(t, []callCase{
+		{name: "len", args: []any{value.NewList(1.0, 2.0)}, want: 2.0},
+		{name: "len", args: []any{value.NewList()}, want: 0.0},
+		{name: "push", args: []any{value.NewList(1.0), 2.0}, want: value.NewList(1.0, 2.0)},
+		{name: "push", args: []any{"a", 2.0}, err: `Bad argument 1 to push: expected a list but got "a".`},
+		{name: "pop", args: []any{value.NewList(1.0, 2.0)}, want: 2.0},
+		{name: "pop", args: []any{value.NewList()}, err: "Bad argument 1 to pop: the list is empty."},
+		{name: "insert", args: []any{value.NewList(1.0, 3.0), 1.0, 2.0}, want: value.NewList(1.0, 2.0, 3.0)},
+		{name: "insert", args: []any{value.NewList(1.0), 1.0, 2.0}, want: value.NewList(1.0, 2.0)},
+		{name: "insert", args: []any{value.NewList(1.0), 2.0, 2.0}, err: "Bad argument 2 to insert: index 2 is out of range 0 to 1."},
+		{name: "remove", args: []any{value.NewList(1.0, 2.0, 3.0), 1.0}, want: 2.0},
+		{name: "remove", args: []any{value.NewList(1.0), 1.0}, err: "Bad argument 2 to remove: index 1 is out of range 0 to 0."},
+		{name: "remove", args: []any{value.NewList(), 0.0}, err: "Bad argument 1 to remove: the list is empty."},
+		{name: "slice", args: []any{value.NewList(1.0, 2.0, 3.0), 1.0}, want: value.NewList(2.0, 3.0)},
+		{name: "slice", args: []any{value.NewList(1.0, 2.0, 3.0), 0.0, 2.0}, want: value.NewList(1.0, 2.0)},
+		{name: "slice", args: []any{value.NewList(1.0), 1.0, 0.0}, err: "Bad argument 3 to slice: end 0 is before start 1."},
+	})
+}
+
+func TestLists_ChangeListsInPlace(t *testing.T) {
+	t.Parallel()
+
+	list := value.NewList(1.0, 2.0, 3.0)
+	for _, step := range []struct {
+		name string
+		args []any
+	}{
+		{"push", []any{list, 4.0}},
+		{"pop", []any{list}},
+		{"remove", []any{list, 0.0}},
+		{"insert", []any{list, 0.0, 0.0}},
+	} {
+		if _, err := call(t, step.name, step.args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := list.String(); got != "[0, 2, 3]" {
+		t.Fatalf("want [0, 2, 3], got %s", got)
+	}
+
+	sliced, err := call(t, "slice", list, 0.0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sliced.(*value.List).Elements[0] = 9.0
+	if list.Elements[0] != 0.0 {
+		t.Fatal("changing a slice changed the list it came from")
+	}
+}
+
+func TestLists_ChargeMemory(t *testing.T) {
+	t.Parallel()
+
+	runQuotas(t, []quotaCase{
+		{name: "push", args: []any{value.NewList(), 1.0}, limit: value.SlotSize - 1},
+		{name: "insert", args: []any{value.NewList(), 0.0, 1.0}, limit: value.SlotSize - 1},
+		{name: "slice", args: []any{value.NewList(1.0, 2.0, 3.0), 1.0}, limit: value.ListSize + value.SlotSize},
+	})
+}
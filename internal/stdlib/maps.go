@@ -0,0 +1,78 @@
+package stdlib
+
+import "github.com/taylorlowery/lox/internal/value"
+
+// Maps is the module of map functions.
+var Maps = Module{
+	Name: "Maps",
+	Doc:  "Map functions. Keys must be strings, numbers, booleans or nil, and maps keep their keys in the order they were first added. `m[key]` gets the value of a key, raising an error if `m` doesn't have it, and `m[key] = value` sets it.",
+	Functions: []Function{
+		{
+			Name:   "has",
+			Params: []string{"map", "key"},
+			Doc:    "Returns whether `map` has `key`.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				m, key, err := mapAndKey("has", args)
+				if err != nil {
+					return nil, err
+				}
+				_, ok := m.Get(key)
+				return ok, nil
+			},
+		},
+		{
+			Name:   "delete",
+			Params: []string{"map", "key"},
+			Doc:    "Removes `key` and its value from `map`, and returns whether `map` had it.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				m, key, err := mapAndKey("delete", args)
+				if err != nil {
+					return nil, err
+				}
+				return m.Delete(key), nil
+			},
+		},
+		{
+			Name:   "keys",
+			Params: []string{"map"},
+			Doc:    "Returns a new list of the keys of `map`, in order.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				m, err := mapArg("keys", args, 0)
+				if err != nil {
+					return nil, err
+				}
+				if err := allocateList(alloc, m.Len()); err != nil {
+					return nil, err
+				}
+				return value.NewList(m.Keys()...), nil
+			},
+		},
+		{
+			Name:   "values",
+			Params: []string{"map"},
+			Doc:    "Returns a new list of the values of `map`, in the order of their keys.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				m, err := mapArg("values", args, 0)
+				if err != nil {
+					return nil, err
+				}
+				if err := allocateList(alloc, m.Len()); err != nil {
+					return nil, err
+				}
+				return value.NewList(m.Values()...), nil
+			},
+		},
+	},
+}
+
+func mapAndKey(name string, args []any) (*value.Map, any, error) {
+	m, err := mapArg(name, args, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := keyArg(name, args, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, key, nil
+}
@@ -0,0 +1,64 @@
+package stdlib_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// newMap returns a map of the given keys and values, which alternate.
+func newMap(t *testing.T, entries ...any) *value.Map {
+	t.Helper()
+	m := value.NewMap()
+	for i := 0; i < len(entries); i += 2 {
+		if err := m.Set(entries[i], entries[i+1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m
+}
+
+func TestMaps(t *testing.T) {
+	t.Parallel()
+
+	runCalls(t, []callCase{
+		{name: "len", args: []any{newMap(t, "a", 1.0, "b", 2.0)}, want: 2.0},
+		{name: "len", args: []any{nil}, err: "Bad argument 1 to len: expected a string, a list or a map but got nil."},
+		{name: "has", args: []any{newMap(t, "a", nil), "a"}, want: true},
+		{name: "has", args: []any{newMap(t, 1.0, nil), "1"}, want: false},
+		{name: "has", args: []any{newMap(t), value.NewList()}, err: "Bad argument 2 to has: [] can't be a map key."},
+		{name: "has", args: []any{newMap(t), math.NaN()}, err: "Bad argument 2 to has: NaN can't be a map key."},
+		{name: "has", args: []any{value.NewList(), "a"}, err: "Bad argument 1 to has: expected a map but got []."},
+		{name: "delete", args: []any{newMap(t, "a", 1.0), "a"}, want: true},
+		{name: "delete", args: []any{newMap(t), "a"}, want: false},
+		{name: "keys", args: []any{newMap(t, "b", 1.0, false, 2.0)}, want: value.NewList("b", false)},
+		{name: "values", args: []any{newMap(t, "b", 1.0, false, 2.0)}, want: value.NewList(1.0, 2.0)},
+		{name: "keys", args: []any{"ab"}, err: `Bad argument 1 to keys: expected a map but got "ab".`},
+	})
+}
+
+func TestMaps_DeleteKeepsOrder(t *testing.T) {
+	t.Parallel()
+
+	m := newMap(t, "a", 1.0, "b", 2.0, "c", 3.0)
+	if _, err := call(t, "delete", m, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Set("b", 4.0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.String(), "{a: 1, c: 3, b: 4}"; got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
+
+func TestMaps_ChargeMemory(t *testing.T) {
+	t.Parallel()
+
+	m := newMap(t, "a", 1.0, "b", 2.0, "c", 3.0)
+	runQuotas(t, []quotaCase{
+		{name: "keys", args: []any{m}, limit: value.ListSize + 2*value.SlotSize},
+		{name: "values", args: []any{m}, limit: value.ListSize + 2*value.SlotSize},
+	})
+}
@@ -0,0 +1,111 @@
+package stdlib
+
+import (
+	"math"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// Math is the module of mathematical functions and constants.
+// Like Lox's arithmetic, they follow IEEE 754: sqrt(-1) is NaN rather than an error.
+var Math = Module{
+	Name: "Math",
+	Doc:  "Mathematical functions and constants. Angles are in radians.",
+	Constants: []Constant{
+		{Name: "pi", Doc: "The ratio of a circle's circumference to its diameter.", Value: math.Pi},
+		{Name: "inf", Doc: "Positive infinity.", Value: math.Inf(1)},
+		{Name: "nan", Doc: "Not a number. It isn't equal to anything, itself included.", Value: math.NaN()},
+	},
+	Functions: []Function{
+		numberFunction("floor", "Returns the greatest whole number less than or equal to `x`.", math.Floor),
+		numberFunction("ceil", "Returns the least whole number greater than or equal to `x`.", math.Ceil),
+		numberFunction("round", "Returns the whole number nearest `x`, rounding halves away from zero.", math.Round),
+		numberFunction("abs", "Returns the absolute value of `x`.", math.Abs),
+		numberFunction("sqrt", "Returns the square root of `x`.", math.Sqrt),
+		numberFunction("sin", "Returns the sine of `x`.", math.Sin),
+		numberFunction("cos", "Returns the cosine of `x`.", math.Cos),
+		numberFunction("log", "Returns the natural logarithm of `x`.", math.Log),
+		{
+			Name:   "pow",
+			Params: []string{"x", "y"},
+			Doc:    "Returns `x` to the power of `y`.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				ns, err := numberArgs("pow", args)
+				if err != nil {
+					return nil, err
+				}
+				return math.Pow(ns[0], ns[1]), nil
+			},
+		},
+		{
+			Name:   "atan2",
+			Params: []string{"y", "x"},
+			Doc:    "Returns the angle between the positive x axis and the point (`x`, `y`).",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				ns, err := numberArgs("atan2", args)
+				if err != nil {
+					return nil, err
+				}
+				return math.Atan2(ns[0], ns[1]), nil
+			},
+		},
+		{
+			Name:   "min",
+			Params: []string{"x", "xs..."},
+			Doc:    "Returns the least of its arguments, or NaN if any is NaN.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				return fold("min", args, math.Min)
+			},
+		},
+		{
+			Name:   "max",
+			Params: []string{"x", "xs..."},
+			Doc:    "Returns the greatest of its arguments, or NaN if any is NaN.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				return fold("max", args, math.Max)
+			},
+		},
+	},
+}
+
+// numberFunction makes a function of one number that returns another.
+func numberFunction(name string, doc string, f func(float64) float64) Function {
+	return Function{
+		Name:   name,
+		Params: []string{"x"},
+		Doc:    doc,
+		Fn: func(alloc value.Allocator, args []any) (any, error) {
+			x, err := numberArg(name, args, 0)
+			if err != nil {
+				return nil, err
+			}
+			return f(x), nil
+		},
+	}
+}
+
+// numberArgs checks that every argument is a number.
+func numberArgs(name string, args []any) ([]float64, error) {
+	ns := make([]float64, len(args))
+	for i := range args {
+		n, err := numberArg(name, args, i)
+		if err != nil {
+			return nil, err
+		}
+		ns[i] = n
+	}
+	return ns, nil
+}
+
+// fold combines numeric arguments with f, from left to right.
+func fold(name string, args []any, f func(a, b float64) float64) (any, error) {
+	ns, err := numberArgs(name, args)
+	if err != nil {
+		return nil, err
+	}
+	result := ns[0]
+	for _, n := range ns[1:] {
+		result = f(result, n)
+	}
+	return result, nil
+}
@@ -0,0 +1,43 @@
+package stdlib_test
+
+import (
+	"math"
+	"testing"
+)
+
+func TestMath(t *testing.T) {
+	t.Parallel()
+
+	runCalls(t, []callCase{
+		{name: "floor", args: []any{-1.5}, want: -2.0},
+		{name: "ceil", args: []any{1.2}, want: 2.0},
+		{name: "round", args: []any{2.5}, want: 3.0},
+		{name: "round", args: []any{-2.5}, want: -3.0},
+		{name: "abs", args: []any{-3.0}, want: 3.0},
+		{name: "sqrt", args: []any{16.0}, want: 4.0},
+		{name: "sqrt", args: []any{"16"}, err: `Bad argument 1 to sqrt: expected a number but got "16".`},
+		{name: "sin", args: []any{0.0}, want: 0.0},
+		{name: "cos", args: []any{0.0}, want: 1.0},
+		{name: "log", args: []any{1.0}, want: 0.0},
+		{name: "log", args: []any{0.0}, want: math.Inf(-1)},
+		{name: "pow", args: []any{2.0, 10.0}, want: 1024.0},
+		{name: "pow", args: []any{2.0, nil}, err: "Bad argument 2 to pow: expected a number but got nil."},
+		{name: "atan2", args: []any{1.0, 0.0}, want: math.Pi / 2},
+		{name: "min", args: []any{3.0}, want: 3.0},
+		{name: "min", args: []any{3.0, -1.0, 2.0}, want: -1.0},
+		{name: "max", args: []any{3.0, -1.0, 2.0}, want: 3.0},
+		{name: "max", args: []any{1.0, true}, err: "Bad argument 2 to max: expected a number but got true."},
+		{name: "max", args: []any{}, err: "Expected at least 1 arguments but got 0."},
+	})
+
+	t.Run("nan", func(t *testing.T) {
+		t.Parallel()
+		got, err := call(t, "max", 1.0, math.NaN())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !math.IsNaN(got.(float64)) {
+			t.Fatalf("want NaN, got %v", got)
+		}
+	})
+}
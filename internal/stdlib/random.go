@@ -0,0 +1,88 @@
+package stdlib
+
+import (
+	"math/rand/v2"
+	"sync"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// Source is a seedable source of random numbers
+// that several programs can draw from at once.
+type Source struct {
+	mu  sync.Mutex
+	pcg *rand.PCG
+}
+
+// NewSource returns a Source seeded with seed.
+// Sources with the same seed produce the same numbers.
+func NewSource(seed uint64) *Source {
+	return &Source{pcg: rand.NewPCG(seed, seed)}
+}
+
+// Seed resets the source to the state NewSource(seed) starts in.
+func (s *Source) Seed(seed uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pcg.Seed(seed, seed)
+}
+
+// Uint64 returns the next random number, implementing rand.Source.
+func (s *Source) Uint64() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pcg.Uint64()
+}
+
+// Random returns the module of random number functions, drawing from source.
+func Random(source *Source) Module {
+	r := rand.New(source)
+	return Module{
+		Name: "Random",
+		Doc:  "Pseudo-random numbers. They aren't suitable for cryptography. The host can seed them to make runs reproducible.",
+		Functions: []Function{
+			{
+				Name:   "random",
+				Params: []string{},
+				Doc:    "Returns a random number from 0 up to but not including 1.",
+				Fn: func(alloc value.Allocator, args []any) (any, error) {
+					return r.Float64(), nil
+				},
+			},
+			{
+				Name:   "randomInt",
+				Params: []string{"a", "b"},
+				Doc:    "Returns a random whole number from `a` to `b`, including both.",
+				Fn: func(alloc value.Allocator, args []any) (any, error) {
+					a, err := intArg("randomInt", args, 0)
+					if err != nil {
+						return nil, err
+					}
+					b, err := intArg("randomInt", args, 1)
+					if err != nil {
+						return nil, err
+					}
+					if b < a {
+						return nil, argError("randomInt", 1, "%d is less than %d", b, a)
+					}
+					return float64(a + r.IntN(b-a+1)), nil
+				},
+			},
+			{
+				Name:   "shuffle",
+				Params: []string{"list"},
+				Doc:    "Puts the elements of `list` in a random order, and returns it.",
+				Fn: func(alloc value.Allocator, args []any) (any, error) {
+					l, err := listArg("shuffle", args, 0)
+					if err != nil {
+						return nil, err
+					}
+					r.Shuffle(len(l.Elements), func(i, j int) {
+						l.Elements[i], l.Elements[j] = l.Elements[j], l.Elements[i]
+					})
+					return l, nil
+				},
+			},
+		},
+	}
+}
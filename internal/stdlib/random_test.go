@@ -0,0 +1,102 @@
+package stdlib_test
+
+import (
+	"cmp"
+	"slices"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/stdlib"
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// draw calls a random module function n times.
+func draw(t *testing.T, source *stdlib.Source, name string, n int, args ...any) []any {
+	t.Helper()
+	var fn func(alloc value.Allocator, args []any) (any, error)
+	for _, f := range stdlib.Random(source).Functions {
+		if f.Name == name {
+			fn = f.Native().Fn
+		}
+	}
+	results := make([]any, n)
+	for i := range results {
+		v, err := fn(unlimited{}, args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[i] = v
+	}
+	return results
+}
+
+func TestRandom_SameSeedSameNumbers(t *testing.T) {
+	t.Parallel()
+
+	a := draw(t, stdlib.NewSource(42), "random", 10)
+	b := draw(t, stdlib.NewSource(42), "random", 10)
+	c := draw(t, stdlib.NewSource(43), "random", 10)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("draw %d: same seed gave %v and %v", i, a[i], b[i])
+		}
+		if n := a[i].(float64); n < 0 || n >= 1 {
+			t.Fatalf("draw %d: %v is outside [0, 1)", i, n)
+		}
+	}
+	if a[0] == c[0] && a[1] == c[1] {
+		t.Fatal("different seeds gave the same numbers")
+	}
+
+	source := stdlib.NewSource(7)
+	draw(t, source, "random", 3)
+	source.Seed(42)
+	if again := draw(t, source, "random", 1); again[0] != a[0] {
+		t.Fatalf("reseeding didn't restart the sequence: got %v, want %v", again[0], a[0])
+	}
+}
+
+func TestRandom_RandomIntIsInclusive(t *testing.T) {
+	t.Parallel()
+
+	seen := map[any]bool{}
+	for _, v := range draw(t, stdlib.NewSource(1), "randomInt", 200, -1.0, 1.0) {
+		seen[v] = true
+	}
+	for _, want := range []float64{-1, 0, 1} {
+		if !seen[want] {
+			t.Errorf("randomInt(-1, 1) never returned %v", want)
+		}
+	}
+	if len(seen) != 3 {
+		t.Errorf("want only -1, 0 and 1, got %v", seen)
+	}
+
+	runCalls(t, []callCase{
+		{name: "randomInt", args: []any{5.0, 5.0}, want: 5.0},
+		{name: "randomInt", args: []any{3.0, 1.0}, err: "Bad argument 2 to randomInt: 1 is less than 3."},
+		{name: "randomInt", args: []any{0.5, 1.0}, err: "Bad argument 1 to randomInt: expected a whole number but got 0.5."},
+	})
+}
+
+func TestRandom_ShufflePermutesInPlace(t *testing.T) {
+	t.Parallel()
+
+	shuffle := func(seed uint64) *value.List {
+		list := value.NewList(1.0, 2.0, 3.0, 4.0, 5.0, 6.0, 7.0, 8.0)
+		got := draw(t, stdlib.NewSource(seed), "shuffle", 1, list)
+		if got[0] != list {
+			t.Fatal("shuffle didn't return the list it was given")
+		}
+		return list
+	}
+
+	a, b := shuffle(3), shuffle(3)
+	if a.String() != b.String() {
+		t.Fatalf("same seed gave %v and %v", a, b)
+	}
+	sorted := slices.Clone(a.Elements)
+	slices.SortFunc(sorted, func(x, y any) int { return cmp.Compare(x.(float64), y.(float64)) })
+	if got := value.NewList(sorted...).String(); got != "[1, 2, 3, 4, 5, 6, 7, 8]" {
+		t.Fatalf("shuffle changed the elements: %s", a)
+	}
+}
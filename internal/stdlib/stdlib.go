@@ -0,0 +1,193 @@
+// Package stdlib implements the native functions golox gives every Lox program,
+// grouped into modules, along with the documentation of each.
+package stdlib
+
+//go:generate go run ../../cmd/generate-stdlib-docs ../../docs/stdlib.md
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// Function is a native function with its documentation.
+type Function struct {
+	Name string
+	// Params names the parameters. Optional ones end in "?"
+	// and can only be followed by other optional ones.
+	// A last one ending in "..." takes any number of arguments.
+	Params []string
+	// Doc describes what the function does, in a sentence or two.
+	Doc string
+	Fn  func(alloc value.Allocator, args []any) (any, error)
+}
+
+// Constant is a global value with its documentation.
+type Constant struct {
+	Name  string
+	Doc   string
+	Value any
+}
+
+// Module is a group of related functions and constants.
+type Module struct {
+	Name      string
+	Doc       string
+	Constants []Constant
+	Functions []Function
+}
+
+// Modules returns the standard library modules, in the order they're documented.
+// The random module draws its numbers from random.
+func Modules(random *Source) []Module {
+	return []Module{Strings, Lists, Maps, Math, Random(random)}
+}
+
+// Native returns the function as a native that checks its number of arguments.
+func (f Function) Native() *value.Native {
+	required, limit := 0, len(f.Params)
+	for _, p := range f.Params {
+		switch {
+		case strings.HasSuffix(p, "..."):
+			limit = -1
+		case !strings.HasSuffix(p, "?"):
+			required++
+		}
+	}
+	if required == limit {
+		return &value.Native{Name: f.Name, Arity: required, Fn: f.Fn}
+	}
+	return &value.Native{
+		Name:  f.Name,
+		Arity: -1,
+		Fn: func(alloc value.Allocator, args []any) (any, error) {
+			switch {
+			case limit < 0 && len(args) < required:
+				return nil, fmt.Errorf("Expected at least %d arguments but got %d.", required, len(args))
+			case limit >= 0 && (len(args) < required || len(args) > limit):
+				return nil, fmt.Errorf("Expected %d to %d arguments but got %d.", required, limit, len(args))
+			}
+			return f.Fn(alloc, args)
+		},
+	}
+}
+
+// Signature formats how the function is called, e.g. "substring(s, start, end?)".
+func (f Function) Signature() string {
+	return f.Name + "(" + strings.Join(f.Params, ", ") + ")"
+}
+
+// WriteReference writes a Markdown reference to the functions in modules.
+func WriteReference(w io.Writer, modules []Module) error {
+	var b strings.Builder
+	b.WriteString("# Standard library\n\n")
+	b.WriteString("<!-- Code generated by generate-stdlib-docs. DO NOT EDIT. -->\n\n")
+	b.WriteString("Every Lox program can call these native functions. ")
+	b.WriteString("Parameters ending in `?` are optional. ")
+	b.WriteString("A bad argument raises a runtime error naming the function and the argument.\n")
+	for _, m := range modules {
+		fmt.Fprintf(&b, "\n## %s\n\n%s\n", m.Name, m.Doc)
+		for _, c := range m.Constants {
+			fmt.Fprintf(&b, "\n### `%s`\n\n%s\n", c.Name, c.Doc)
+		}
+		for _, f := range m.Functions {
+			fmt.Fprintf(&b, "\n### `%s`\n\n%s\n", f.Signature(), f.Doc)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// newString accounts for a string a function built, before it's returned.
+func newString(alloc value.Allocator, s string) (any, error) {
+	if err := alloc.Allocate(int64(len(s))); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// allocateList accounts for a list of n elements, before it's built.
+func allocateList(alloc value.Allocator, n int) error {
+	return alloc.Allocate(value.ListSize + int64(n)*value.SlotSize)
+}
+
+// argError reports a bad argument to a function. i counts from 0.
+func argError(name string, i int, format string, a ...any) error {
+	return fmt.Errorf("Bad argument %d to %s: %s.", i+1, name, fmt.Sprintf(format, a...))
+}
+
+func stringArg(name string, args []any, i int) (string, error) {
+	s, ok := args[i].(string)
+	if !ok {
+		return "", argError(name, i, "expected a string but got %s", describe(args[i]))
+	}
+	return s, nil
+}
+
+func listArg(name string, args []any, i int) (*value.List, error) {
+	l, ok := args[i].(*value.List)
+	if !ok {
+		return nil, argError(name, i, "expected a list but got %s", describe(args[i]))
+	}
+	return l, nil
+}
+
+func mapArg(name string, args []any, i int) (*value.Map, error) {
+	m, ok := args[i].(*value.Map)
+	if !ok {
+		return nil, argError(name, i, "expected a map but got %s", describe(args[i]))
+	}
+	return m, nil
+}
+
+// keyArg checks that the ith argument can be a map key.
+func keyArg(name string, args []any, i int) (any, error) {
+	if err := value.CheckKey(args[i]); err != nil {
+		return nil, argError(name, i, "%s can't be a map key", describe(args[i]))
+	}
+	return args[i], nil
+}
+
+func numberArg(name string, args []any, i int) (float64, error) {
+	n, ok := args[i].(float64)
+	if !ok {
+		return 0, argError(name, i, "expected a number but got %s", describe(args[i]))
+	}
+	return n, nil
+}
+
+func intArg(name string, args []any, i int) (int, error) {
+	n, err := numberArg(name, args, i)
+	if err != nil {
+		return 0, err
+	}
+	if n != math.Trunc(n) || math.Abs(n) > math.MaxInt32 {
+		return 0, argError(name, i, "expected a whole number but got %s", value.Stringify(n))
+	}
+	return int(n), nil
+}
+
+// indexArg is intArg for an index into something of length n, or n itself.
+func indexArg(name string, args []any, i int, n int) (int, error) {
+	index, err := intArg(name, args, i)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 || index > n {
+		return 0, argError(name, i, "index %d is out of range 0 to %d", index, n)
+	}
+	return index, nil
+}
+
+// describe names a value in an error message.
+func describe(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	default:
+		return value.Stringify(v)
+	}
+}
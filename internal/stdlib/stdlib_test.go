@@ -0,0 +1,170 @@
+package stdlib_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/taylorlowery/lox/internal/stdlib"
+	"github.com/taylorlowery/lox/internal/value"
+	"github.com/taylorlowery/lox/internal/vm"
+)
+
+// unlimited is an allocator without a memory limit.
+type unlimited struct{}
+
+func (unlimited) Allocate(n int64) error {
+	return nil
+}
+
+// call calls the named standard library function as a Lox program would.
+func call(t *testing.T, name string, args ...any) (any, error) {
+	t.Helper()
+	return callWith(t, unlimited{}, name, args...)
+}
+
+func callWith(t *testing.T, alloc value.Allocator, name string, args ...any) (any, error) {
+	t.Helper()
+	for _, m := range stdlib.Modules(stdlib.NewSource(0)) {
+		for _, f := range m.Functions {
+			if f.Name == name {
+				native := f.Native()
+				if native.Arity >= 0 && native.Arity != len(args) {
+					t.Fatalf("%s takes %d arguments, not %d", name, native.Arity, len(args))
+				}
+				return native.Fn(alloc, args)
+			}
+		}
+	}
+	t.Fatalf("no function %s", name)
+	return nil, nil
+}
+
+// quotaCase is a call that allocates more than limit bytes.
+type quotaCase struct {
+	name  string
+	args  []any
+	limit int64
+}
+
+// runQuotas checks that each call fails with ErrMemoryLimit
+// when run by a VM with its memory limit, and succeeds without one.
+func runQuotas(t *testing.T, testCases []quotaCase) {
+	t.Helper()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			memory, err := vm.NewMemory(tc.limit)
+			if err != nil {
+				t.Fatal(err)
+			}
+			machine, err := vm.NewVM(vm.WithMemory(memory))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := callWith(t, machine, tc.name, tc.args...); !errors.Is(err, vm.ErrMemoryLimit) {
+				t.Fatalf("want %v, got %v", vm.ErrMemoryLimit, err)
+			}
+			if _, err := call(t, tc.name, tc.args...); err != nil {
+				t.Fatalf("without a limit: %v", err)
+			}
+		})
+	}
+}
+
+type callCase struct {
+	name string
+	args []any
+	want any
+	// err is the error message wanted instead of a result
+	err string
+}
+
+func runCalls(t *testing.T, testCases []callCase) {
+	t.Helper()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := call(t, tc.name, tc.args...)
+			if tc.err != "" {
+				if err == nil || err.Error() != tc.err {
+					t.Fatalf("want error %q, got %v", tc.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestModules_AreDocumented(t *testing.T) {
+	t.Parallel()
+
+	names := map[string]bool{}
+	for _, m := range stdlib.Modules(stdlib.NewSource(0)) {
+		if m.Doc == "" {
+			t.Errorf("module %s has no documentation", m.Name)
+		}
+		for _, f := range m.Functions {
+			if names[f.Name] {
+				t.Errorf("%s is defined twice", f.Name)
+			}
+			names[f.Name] = true
+			if f.Doc == "" {
+				t.Errorf("%s has no documentation", f.Name)
+			}
+		}
+	}
+}
+
+func TestWriteReference_MatchesCheckedInDocs(t *testing.T) {
+	t.Parallel()
+
+	var got bytes.Buffer
+	if err := stdlib.WriteReference(&got, stdlib.Modules(stdlib.NewSource(0))); err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("../../docs/stdlib.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(string(want), got.String()); diff != "" {
+		t.Fatalf("docs/stdlib.md is out of date, run go generate ./internal/stdlib (-want +got):\n%s", diff)
+	}
+}
+
+func TestFunction_NativeChecksOptionalArguments(t *testing.T) {
+	t.Parallel()
+
+	f := stdlib.Function{
+		Name:   "f",
+		Params: []string{"a", "b?"},
+		Fn:     func(alloc value.Allocator, args []any) (any, error) { return float64(len(args)), nil },
+	}
+	native := f.Native()
+	if native.Arity != -1 {
+		t.Fatalf("want a variadic native, got arity %d", native.Arity)
+	}
+	for _, n := range []int{1, 2} {
+		if got, err := native.Fn(unlimited{}, make([]any, n)); err != nil || got != float64(n) {
+			t.Errorf("%d arguments: want %d, got %v, %v", n, n, got, err)
+		}
+	}
+	for _, n := range []int{0, 3} {
+		_, err := native.Fn(unlimited{}, make([]any, n))
+		want := "Expected 1 to 2 arguments but got " + string(rune('0'+n)) + "."
+		if err == nil || err.Error() != want {
+			t.Errorf("%d arguments: want error %q, got %v", n, want, err)
+		}
+	}
+	if got := f.Signature(); got != "f(a, b?)" {
+		t.Errorf("want signature f(a, b?), got %s", got)
+	}
+}
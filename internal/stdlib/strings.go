@@ -0,0 +1,268 @@
+package stdlib
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+// Strings is the module of string functions.
+// They count in Unicode code points rather than bytes,
+// so "é" has length 1 and substring never splits a character.
+var Strings = Module{
+	Name: "Strings",
+	Doc: "String functions. Lengths and indexes count Unicode code points, not bytes, " +
+		"and indexes start at 0.",
+	Functions: []Function{
+		{
+			Name:   "len",
+			Params: []string{"x"},
+			Doc:    "Returns the number of characters in the string `x`, the number of elements in the list `x`, or the number of entries in the map `x`.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				switch x := args[0].(type) {
+				case string:
+					return float64(utf8.RuneCountInString(x)), nil
+				case *value.List:
+					return float64(len(x.Elements)), nil
+				case *value.Map:
+					return float64(x.Len()), nil
+				default:
+					return nil, argError("len", 0, "expected a string, a list or a map but got %s", describe(x))
+				}
+			},
+		},
+		{
+			Name:   "substring",
+			Params: []string{"s", "start", "end?"},
+			Doc:    "Returns the characters of `s` from index `start` up to but not including `end`, which defaults to the length of `s`.",
+			Fn:     substring,
+		},
+		{
+			Name:   "indexOf",
+			Params: []string{"s", "sub", "from?"},
+			Doc:    "Returns the index of the first occurrence of `sub` in `s` at or after index `from`, which defaults to 0, or -1 if there is none.",
+			Fn:     indexOf,
+		},
+		{
+			Name:   "upper",
+			Params: []string{"s"},
+			Doc:    "Returns `s` with every letter in upper case.",
+			Fn:     mapString("upper", strings.ToUpper),
+		},
+		{
+			Name:   "lower",
+			Params: []string{"s"},
+			Doc:    "Returns `s` with every letter in lower case.",
+			Fn:     mapString("lower", strings.ToLower),
+		},
+		{
+			Name:   "trim",
+			Params: []string{"s"},
+			Doc:    "Returns `s` without leading and trailing white space.",
+			Fn:     mapString("trim", strings.TrimSpace),
+		},
+		{
+			Name:   "replace",
+			Params: []string{"s", "old", "new"},
+			Doc:    "Returns `s` with every occurrence of `old` replaced by `new`.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				strs, err := stringArgs("replace", args)
+				if err != nil {
+					return nil, err
+				}
+				s, old, replacement := strs[0], strs[1], strs[2]
+				n := len(s) + strings.Count(s, old)*(len(replacement)-len(old))
+				if err := alloc.Allocate(int64(n)); err != nil {
+					return nil, err
+				}
+				return strings.ReplaceAll(s, old, replacement), nil
+			},
+		},
+		{
+			Name:   "startsWith",
+			Params: []string{"s", "prefix"},
+			Doc:    "Returns whether `s` starts with `prefix`.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				strs, err := stringArgs("startsWith", args)
+				if err != nil {
+					return nil, err
+				}
+				return strings.HasPrefix(strs[0], strs[1]), nil
+			},
+		},
+		{
+			Name:   "endsWith",
+			Params: []string{"s", "suffix"},
+			Doc:    "Returns whether `s` ends with `suffix`.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				strs, err := stringArgs("endsWith", args)
+				if err != nil {
+					return nil, err
+				}
+				return strings.HasSuffix(strs[0], strs[1]), nil
+			},
+		},
+		{
+			Name:   "split",
+			Params: []string{"s", "sep"},
+			Doc:    "Returns a list of the parts of `s` between occurrences of `sep`, or of its characters if `sep` is empty.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				strs, err := stringArgs("split", args)
+				if err != nil {
+					return nil, err
+				}
+				s, sep := strs[0], strs[1]
+				n := strings.Count(s, sep) + 1
+				if sep == "" {
+					n = utf8.RuneCountInString(s)
+				}
+				// the parts together are no longer than s
+				if err := allocateList(alloc, n); err != nil {
+					return nil, err
+				}
+				if err := alloc.Allocate(int64(len(s))); err != nil {
+					return nil, err
+				}
+				parts := strings.Split(s, sep)
+				elements := make([]any, len(parts))
+				for i, part := range parts {
+					elements[i] = part
+				}
+				return value.NewList(elements...), nil
+			},
+		},
+		{
+			Name:   "join",
+			Params: []string{"list", "sep"},
+			Doc:    "Returns the elements of `list`, printed as Lox prints them, with `sep` between each.",
+			Fn: func(alloc value.Allocator, args []any) (any, error) {
+				l, err := listArg("join", args, 0)
+				if err != nil {
+					return nil, err
+				}
+				sep, err := stringArg("join", args, 1)
+				if err != nil {
+					return nil, err
+				}
+				parts := make([]string, len(l.Elements))
+				n := 0
+				for i, v := range l.Elements {
+					parts[i] = value.Stringify(v)
+					n += len(parts[i])
+				}
+				if len(parts) > 1 {
+					n += (len(parts) - 1) * len(sep)
+				}
+				if err := alloc.Allocate(int64(n)); err != nil {
+					return nil, err
+				}
+				return strings.Join(parts, sep), nil
+			},
+		},
+		{
+			Name:   "charCode",
+			Params: []string{"s", "index?"},
+			Doc:    "Returns the Unicode code point of the character at `index` in `s`, which defaults to 0.",
+			Fn:     charCode,
+		},
+		{
+			Name:   "fromCharCode",
+			Params: []string{"code"},
+			Doc:    "Returns the one character string whose Unicode code point is `code`.",
+			Fn:     fromCharCode,
+		},
+	},
+}
+
+// mapString makes a function of one string that returns another.
+func mapString(name string, f func(string) string) func(alloc value.Allocator, args []any) (any, error) {
+	return func(alloc value.Allocator, args []any) (any, error) {
+		s, err := stringArg(name, args, 0)
+		if err != nil {
+			return nil, err
+		}
+		return newString(alloc, f(s))
+	}
+}
+
+// stringArgs checks that every argument is a string.
+func stringArgs(name string, args []any) ([]string, error) {
+	strs := make([]string, len(args))
+	for i := range args {
+		s, err := stringArg(name, args, i)
+		if err != nil {
+			return nil, err
+		}
+		strs[i] = s
+	}
+	return strs, nil
+}
+
+func substring(alloc value.Allocator, args []any) (any, error) {
+	s, err := stringArg("substring", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	start, end, err := bounds("substring", args, len(runes))
+	if err != nil {
+		return nil, err
+	}
+	return newString(alloc, string(runes[start:end]))
+}
+
+func indexOf(alloc value.Allocator, args []any) (any, error) {
+	s, err := stringArg("indexOf", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := stringArg("indexOf", args, 1)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	from := 0
+	if len(args) > 2 {
+		from, err = indexArg("indexOf", args, 2, len(runes))
+		if err != nil {
+			return nil, err
+		}
+	}
+	rest := string(runes[from:])
+	i := strings.Index(rest, sub)
+	if i < 0 {
+		return -1.0, nil
+	}
+	return float64(from + utf8.RuneCountInString(rest[:i])), nil
+}
+
+func charCode(alloc value.Allocator, args []any) (any, error) {
+	s, err := stringArg("charCode", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	runes := []rune(s)
+	if len(runes) == 0 {
+		return nil, argError("charCode", 0, "the string is empty")
+	}
+	index := 0
+	if len(args) > 1 {
+		index, err = indexArg("charCode", args, 1, len(runes)-1)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return float64(runes[index]), nil
+}
+
+func fromCharCode(alloc value.Allocator, args []any) (any, error) {
+	code, err := intArg("fromCharCode", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	if code < 0 || !utf8.ValidRune(rune(code)) {
+		return nil, argError("fromCharCode", 0, "%d isn't a Unicode code point", code)
+	}
+	return newString(alloc, string(rune(code)))
+}
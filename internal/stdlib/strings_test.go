@@ -0,0 +1,65 @@
+package stdlib_test
+
+import (
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+func TestStrings(t *testing.T) {
+	t.Parallel()
+
+	runCalls(t, []callCase{
+		{name: "len", args: []any{"héllo"}, want: 5.0},
+		{name: "len", args: []any{""}, want: 0.0},
+		{name: "len", args: []any{1.0}, err: "Bad argument 1 to len: expected a string, a list or a map but got 1."},
+		{name: "substring", args: []any{"héllo wörld", 6.0}, want: "wörld"},
+		{name: "substring", args: []any{"héllo", 1.0, 2.0}, want: "é"},
+		{name: "substring", args: []any{"abc", 3.0, 3.0}, want: ""},
+		{name: "substring", args: []any{"abc", 4.0}, err: "Bad argument 2 to substring: index 4 is out of range 0 to 3."},
+		{name: "substring", args: []any{"abc", 1.5}, err: "Bad argument 2 to substring: expected a whole number but got 1.5."},
+		{name: "substring", args: []any{"abc", 2.0, 1.0}, err: "Bad argument 3 to substring: end 1 is before start 2."},
+		{name: "substring", args: []any{"abc", "1"}, err: `Bad argument 2 to substring: expected a number but got "1".`},
+		{name: "indexOf", args: []any{"naïve naïve", "ve"}, want: 3.0},
+		{name: "indexOf", args: []any{"naïve naïve", "ve", 4.0}, want: 9.0},
+		{name: "indexOf", args: []any{"abc", "x"}, want: -1.0},
+		{name: "indexOf", args: []any{"abc", ""}, want: 0.0},
+		{name: "indexOf", args: []any{"abc", nil}, err: "Bad argument 2 to indexOf: expected a string but got nil."},
+		{name: "upper", args: []any{"héllo"}, want: "HÉLLO"},
+		{name: "lower", args: []any{"ÀB"}, want: "àb"},
+		{name: "trim", args: []any{" \t a b \n"}, want: "a b"},
+		{name: "replace", args: []any{"a-b-c", "-", "+"}, want: "a+b+c"},
+		{name: "replace", args: []any{"a-b", "-", true}, err: "Bad argument 3 to replace: expected a string but got true."},
+		{name: "startsWith", args: []any{"golox", "go"}, want: true},
+		{name: "startsWith", args: []any{"golox", "lox"}, want: false},
+		{name: "endsWith", args: []any{"golox", "lox"}, want: true},
+		{name: "split", args: []any{"a,b,,c", ","}, want: value.NewList("a", "b", "", "c")},
+		{name: "split", args: []any{"hé", ""}, want: value.NewList("h", "é")},
+		{name: "split", args: []any{"", ","}, want: value.NewList("")},
+		{name: "join", args: []any{value.NewList(1.0, "a", nil, value.NewList(true)), "-"}, want: "1-a-nil-[true]"},
+		{name: "join", args: []any{value.NewList(), ","}, want: ""},
+		{name: "join", args: []any{"abc", ","}, err: `Bad argument 1 to join: expected a list but got "abc".`},
+		{name: "charCode", args: []any{"A"}, want: 65.0},
+		{name: "charCode", args: []any{"aé", 1.0}, want: 233.0},
+		{name: "charCode", args: []any{"a", 1.0}, err: "Bad argument 2 to charCode: index 1 is out of range 0 to 0."},
+		{name: "charCode", args: []any{""}, err: "Bad argument 1 to charCode: the string is empty."},
+		{name: "fromCharCode", args: []any{233.0}, want: "é"},
+		{name: "fromCharCode", args: []any{-1.0}, err: "Bad argument 1 to fromCharCode: -1 isn't a Unicode code point."},
+		{name: "fromCharCode", args: []any{55296.0}, err: "Bad argument 1 to fromCharCode: 55296 isn't a Unicode code point."},
+	})
+}
+
+func TestStrings_ChargeMemory(t *testing.T) {
+	t.Parallel()
+
+	runQuotas(t, []quotaCase{
+		{name: "substring", args: []any{"aaaaaaaaaa", 2.0}, limit: 7},
+		{name: "upper", args: []any{"abcdef"}, limit: 5},
+		{name: "lower", args: []any{"ABCDEF"}, limit: 5},
+		{name: "trim", args: []any{" abcdef "}, limit: 5},
+		{name: "replace", args: []any{"aaaaaaaaaa", "a", "aaaaaaaaaa"}, limit: 99},
+		{name: "split", args: []any{"a,b,c", ","}, limit: value.ListSize + 2*value.SlotSize},
+		{name: "join", args: []any{value.NewList("ab", "cd", "ef"), ", "}, limit: 9},
+		{name: "fromCharCode", args: []any{233.0}, limit: 1},
+	})
+}
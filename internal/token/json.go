@@ -0,0 +1,116 @@
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// MarshalText encodes a TokenType as its name, e.g. "BANG_EQUAL",
+// so serialized tokens don't depend on the order of the constants.
+func (t TokenType) MarshalText() ([]byte, error) {
+	if t < 0 || t > EOF {
+		return nil, fmt.Errorf("invalid token type %d", int(t))
+	}
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText decodes a TokenType from its name.
+func (t *TokenType) UnmarshalText(text []byte) error {
+	for tt := LEFT_PAREN; tt <= EOF; tt++ {
+		if tt.String() == string(text) {
+			*t = tt
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown token type %q", text)
+}
+
+type jsonToken struct {
+	Type    TokenType       `json:"type"`
+	Lexeme  string          `json:"lexeme"`
+	Literal json.RawMessage `json:"literal"`
+	Line    int             `json:"line"`
+}
+
+// MarshalJSON encodes a Token as a JSON object,
+// with its literal encoded by MarshalLiteral.
+func (t Token) MarshalJSON() ([]byte, error) {
+	literal, err := MarshalLiteral(t.Literal)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonToken{
+		Type:    t.TokenType,
+		Lexeme:  t.Lexeme,
+		Literal: literal,
+		Line:    t.Line,
+	})
+}
+
+// UnmarshalJSON decodes a Token previously encoded by MarshalJSON.
+func (t *Token) UnmarshalJSON(data []byte) error {
+	var j jsonToken
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	literal, err := UnmarshalLiteral(j.Literal)
+	if err != nil {
+		return err
+	}
+	*t = Token{
+		TokenType: j.Type,
+		Lexeme:    j.Lexeme,
+		Literal:   literal,
+		Line:      j.Line,
+	}
+	return nil
+}
+
+// MarshalLiteral encodes a Lox literal value as JSON.
+// nil, bool, string and finite float64 values map onto their JSON counterparts.
+// JSON has no NaN or infinities, so those are written as {"number": "NaN"},
+// {"number": "+Inf"} and {"number": "-Inf"}.
+// Any other type is an error.
+func MarshalLiteral(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return json.Marshal(v)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return json.Marshal(map[string]string{"number": strconv.FormatFloat(v, 'g', -1, 64)})
+		}
+		return json.Marshal(v)
+	default:
+		return nil, fmt.Errorf("unsupported literal type %T", v)
+	}
+}
+
+// UnmarshalLiteral decodes a Lox literal value encoded by MarshalLiteral.
+// An empty input decodes to nil.
+func UnmarshalLiteral(data []byte) (any, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	switch v := v.(type) {
+	case nil, bool, string, float64:
+		return v, nil
+	case map[string]any:
+		s, ok := v["number"].(string)
+		if !ok || len(v) != 1 {
+			return nil, fmt.Errorf("invalid literal %s", data)
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid literal %s", data)
+		}
+		return f, nil
+	default:
+		return nil, fmt.Errorf("invalid literal %s", data)
+	}
+}
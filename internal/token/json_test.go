@@ -0,0 +1,62 @@
+package token_test
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/token"
+)
+
+func TestTokenJSON_RoundTrips(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		token token.Token
+	}{
+		{"operator", token.Token{TokenType: token.BANG_EQUAL, Lexeme: "!=", Line: 3}},
+		{"number", token.Token{TokenType: token.NUMBER, Lexeme: "1.5", Literal: 1.5, Line: 1}},
+		{"string", token.Token{TokenType: token.STRING, Lexeme: "\"hi\"", Literal: "hi", Line: 2}},
+		{"eof", token.Token{TokenType: token.EOF, Line: 9}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := json.Marshal(tc.token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got token.Token
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.token) {
+				t.Fatalf("want %#v, got %#v", tc.token, got)
+			}
+		})
+	}
+}
+
+func TestTokenJSON_EncodesTypeByName(t *testing.T) {
+	t.Parallel()
+
+	data, err := json.Marshal(token.Token{TokenType: token.BANG_EQUAL, Lexeme: "!=", Line: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"BANG_EQUAL","lexeme":"!=","literal":null,"line":4}`
+	if got := string(data); got != want {
+		t.Fatalf("want %s, got %s", want, got)
+	}
+}
+
+func TestTokenJSON_UnknownTypeIsAnError(t *testing.T) {
+	t.Parallel()
+
+	var got token.Token
+	err := json.Unmarshal([]byte(`{"type":"SPACESHIP","lexeme":"<=>","line":1}`), &got)
+	if err == nil {
+		t.Fatal("expected an error for an unknown token type")
+	}
+}
@@ -12,46 +12,50 @@ func _() {
 	_ = x[RIGHT_PAREN-1]
 	_ = x[LEFT_BRACE-2]
 	_ = x[RIGHT_BRACE-3]
-	_ = x[COMMA-4]
-	_ = x[DOT-5]
-	_ = x[MINUS-6]
-	_ = x[PLUS-7]
-	_ = x[SEMICOLON-8]
-	_ = x[SLASH-9]
-	_ = x[STAR-10]
-	_ = x[BANG-11]
-	_ = x[BANG_EQUAL-12]
-	_ = x[EQUAL-13]
-	_ = x[EQUAL_EQUAL-14]
-	_ = x[GREATER-15]
-	_ = x[GREATER_EQUAL-16]
-	_ = x[LESS-17]
-	_ = x[LESS_EQUAL-18]
-	_ = x[IDENTIFIER-19]
-	_ = x[STRING-20]
-	_ = x[NUMBER-21]
-	_ = x[AND-22]
-	_ = x[CLASS-23]
-	_ = x[ELSE-24]
-	_ = x[FALSE-25]
-	_ = x[FUN-26]
-	_ = x[FOR-27]
-	_ = x[IF-28]
-	_ = x[NIL-29]
-	_ = x[OR-30]
-	_ = x[PRINT-31]
-	_ = x[RETURN-32]
-	_ = x[SUPER-33]
-	_ = x[THIS-34]
-	_ = x[TRUE-35]
-	_ = x[VAR-36]
-	_ = x[WHILE-37]
-	_ = x[EOF-38]
+	_ = x[LEFT_BRACKET-4]
+	_ = x[RIGHT_BRACKET-5]
+	_ = x[COMMA-6]
+	_ = x[DOT-7]
+	_ = x[MINUS-8]
+	_ = x[PLUS-9]
+	_ = x[SEMICOLON-10]
+	_ = x[SLASH-11]
+	_ = x[STAR-12]
+	_ = x[QUESTION-13]
+	_ = x[COLON-14]
+	_ = x[BANG-15]
+	_ = x[BANG_EQUAL-16]
+	_ = x[EQUAL-17]
+	_ = x[EQUAL_EQUAL-18]
+	_ = x[GREATER-19]
+	_ = x[GREATER_EQUAL-20]
+	_ = x[LESS-21]
+	_ = x[LESS_EQUAL-22]
+	_ = x[IDENTIFIER-23]
+	_ = x[STRING-24]
+	_ = x[NUMBER-25]
+	_ = x[AND-26]
+	_ = x[CLASS-27]
+	_ = x[ELSE-28]
+	_ = x[FALSE-29]
+	_ = x[FUN-30]
+	_ = x[FOR-31]
+	_ = x[IF-32]
+	_ = x[NIL-33]
+	_ = x[OR-34]
+	_ = x[PRINT-35]
+	_ = x[RETURN-36]
+	_ = x[SUPER-37]
+	_ = x[THIS-38]
+	_ = x[TRUE-39]
+	_ = x[VAR-40]
+	_ = x[WHILE-41]
+	_ = x[EOF-42]
 }
 
-const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACECOMMADOTMINUSPLUSSEMICOLONSLASHSTARBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERANDCLASSELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILEEOF"
+const _TokenType_name = "LEFT_PARENRIGHT_PARENLEFT_BRACERIGHT_BRACELEFT_BRACKETRIGHT_BRACKETCOMMADOTMINUSPLUSSEMICOLONSLASHSTARQUESTIONCOLONBANGBANG_EQUALEQUALEQUAL_EQUALGREATERGREATER_EQUALLESSLESS_EQUALIDENTIFIERSTRINGNUMBERANDCLASSELSEFALSEFUNFORIFNILORPRINTRETURNSUPERTHISTRUEVARWHILEEOF"
 
-var _TokenType_index = [...]uint8{0, 10, 21, 31, 42, 47, 50, 55, 59, 68, 73, 77, 81, 91, 96, 107, 114, 127, 131, 141, 151, 157, 163, 166, 171, 175, 180, 183, 186, 188, 191, 193, 198, 204, 209, 213, 217, 220, 225, 228}
+var _TokenType_index = [...]uint16{0, 10, 21, 31, 42, 54, 67, 72, 75, 80, 84, 93, 98, 102, 110, 115, 119, 129, 134, 145, 152, 165, 169, 179, 189, 195, 201, 204, 209, 213, 218, 221, 224, 226, 229, 231, 236, 242, 247, 251, 255, 258, 263, 266}
 
 func (i TokenType) String() string {
 	if i < 0 || i >= TokenType(len(_TokenType_index)-1) {
@@ -0,0 +1,439 @@
+// Package value implements the semantics of Lox values
+// shared by everything in golox that evaluates Lox.
+//
+// Lox values are represented as Go values:
+// nil, bool, float64 and string, *List for lists, *Map for maps,
+// *Native for functions written in Go, and Object for instances of classes written in Go.
+package value
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Errors returned by the operators when given operands of the wrong type.
+// The messages are the ones from the book.
+var (
+	ErrOperandNumber         = errors.New("Operand must be a number.")
+	ErrOperandsNumbers       = errors.New("Operands must be numbers.")
+	ErrOperandsNumbersString = errors.New("Operands must be two numbers or two strings.")
+	ErrIndexList             = errors.New("Can only index lists and maps.")
+	ErrIndexWhole            = errors.New("List index must be a whole number.")
+	ErrMapKey                = errors.New("Map keys must be strings, numbers, booleans or nil.")
+	ErrMapKeyNaN             = errors.New("NaN can't be a map key.")
+	ErrProperties            = errors.New("Only instances have properties.")
+	ErrFields                = errors.New("Only instances have fields.")
+)
+
+// Native is a function written in Go that Lox programs can call.
+type Native struct {
+	Name string
+	// Arity is the number of arguments the function takes,
+	// or -1 if it takes any number.
+	Arity int
+	// Fn implements the function. An error it returns
+	// is raised as a runtime error with the error's message.
+	// It must account for the strings, lists and maps it builds with alloc.
+	Fn func(alloc Allocator, args []any) (any, error)
+}
+
+// Allocator accounts for the memory a program allocates.
+type Allocator interface {
+	// Allocate records that n more bytes are in use. If that would take the program
+	// over its memory limit, it returns an error instead, which a native should return.
+	Allocate(n int64) error
+}
+
+// Object is an instance of a class written in Go. Like lists, objects are mutable
+// and equal only to themselves. Since they're compared with ==, an Object should be
+// a pointer or wrap one. String formats the object the way Lox prints it.
+type Object interface {
+	// GetProperty returns the value of the named property.
+	// It must account for a value it builds with alloc, as a native does.
+	GetProperty(alloc Allocator, name string) (any, error)
+	// SetProperty sets the named property to v.
+	SetProperty(name string, v any) error
+	String() string
+}
+
+// The number of bytes memory accounting charges for lists and maps,
+// besides the strings they hold, roughly what Go uses for them.
+const (
+	// ListSize is the size of an empty list.
+	ListSize = 32
+	// SlotSize is the size of each element of a list.
+	SlotSize = 16
+	// MapSize is the size of an empty map.
+	MapSize = 48
+	// EntrySize is the size of each entry in a map,
+	// including its key's place in the map's order.
+	EntrySize = 48
+)
+
+// String formats a native function the way Lox prints it.
+func (n *Native) String() string {
+	return "<native fn " + n.Name + ">"
+}
+
+// List is a Lox list. Lists are mutable, and like functions
+// they are equal only to themselves.
+type List struct {
+	Elements []any
+}
+
+// NewList returns a list of the given elements.
+func NewList(elements ...any) *List {
+	return &List{Elements: elements}
+}
+
+// String formats a list the way Lox prints it, e.g. [1, a, [true]].
+// A list that contains itself prints as [...] inside itself.
+func (l *List) String() string {
+	return stringify(l, map[any]bool{})
+}
+
+// Map is a Lox map from keys to values. Like lists, maps are mutable
+// and equal only to themselves. Keys are kept in the order they were first added.
+// The zero Map is an empty map ready to use.
+type Map struct {
+	keys   []any
+	values map[any]any
+}
+
+// NewMap returns an empty map.
+func NewMap() *Map {
+	return &Map{}
+}
+
+// CheckKey returns an error if key can't be a map key.
+// Keys must be strings, numbers other than NaN, booleans or nil,
+// which are compared by value.
+func CheckKey(key any) error {
+	switch key := key.(type) {
+	case nil, bool, string:
+		return nil
+	case float64:
+		if math.IsNaN(key) {
+			return ErrMapKeyNaN
+		}
+		return nil
+	default:
+		return ErrMapKey
+	}
+}
+
+// Len returns the number of entries in the map.
+func (m *Map) Len() int {
+	return len(m.keys)
+}
+
+// Get returns the value of key, and whether the map has it.
+func (m *Map) Get(key any) (any, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Set sets the value of key, adding it after the other keys if the map doesn't have it.
+func (m *Map) Set(key, v any) error {
+	if err := CheckKey(key); err != nil {
+		return err
+	}
+	if m.values == nil {
+		m.values = map[any]any{}
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+	return nil
+}
+
+// Delete removes key from the map, and reports whether the map had it.
+func (m *Map) Delete(key any) bool {
+	if _, ok := m.values[key]; !ok {
+		return false
+	}
+	delete(m.values, key)
+	i := slices.Index(m.keys, key)
+	m.keys = slices.Delete(m.keys, i, i+1)
+	return true
+}
+
+// Keys returns the map's keys in order.
+func (m *Map) Keys() []any {
+	return slices.Clone(m.keys)
+}
+
+// Values returns the map's values in the order of their keys.
+func (m *Map) Values() []any {
+	values := make([]any, len(m.keys))
+	for i, key := range m.keys {
+		values[i] = m.values[key]
+	}
+	return values
+}
+
+// String formats a map the way Lox prints it, e.g. {a: 1, 2: [true]}.
+// A map that contains itself prints as {...} inside itself.
+func (m *Map) String() string {
+	return stringify(m, map[any]bool{})
+}
+
+// stringify formats a value the way Lox prints it.
+// printing holds the lists and maps being printed,
+// so that one inside itself prints as [...] or {...} rather than forever.
+func stringify(v any, printing map[any]bool) string {
+	switch v := v.(type) {
+	case *List:
+		if printing[v] {
+			return "[...]"
+		}
+		printing[v] = true
+		defer delete(printing, v)
+		parts := make([]string, len(v.Elements))
+		for i, element := range v.Elements {
+			parts[i] = stringify(element, printing)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case *Map:
+		if printing[v] {
+			return "{...}"
+		}
+		printing[v] = true
+		defer delete(printing, v)
+		parts := make([]string, len(v.keys))
+		for i, key := range v.keys {
+			parts[i] = Stringify(key) + ": " + stringify(v.values[key], printing)
+		}
+		return "{" + strings.Join(parts, ", ") + "}"
+	default:
+		return Stringify(v)
+	}
+}
+
+// IsTruthy reports whether a value counts as true in a condition.
+// nil and false are falsey, everything else is truthy.
+func IsTruthy(v any) bool {
+	if v == nil {
+		return false
+	}
+	if b, ok := v.(bool); ok {
+		return b
+	}
+	return true
+}
+
+// IsEqual reports whether two values are equal.
+// Values of different types are never equal.
+func IsEqual(a, b any) bool {
+	return a == b
+}
+
+// Stringify formats a value the way Lox prints it.
+// Whole numbers print without a decimal point.
+func Stringify(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		switch {
+		case math.IsNaN(v):
+			return "NaN"
+		case math.IsInf(v, 1):
+			return "Infinity"
+		case math.IsInf(v, -1):
+			return "-Infinity"
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// GetIndex implements list[index] and map[key].
+// It's an error to get a key that isn't in a map.
+func GetIndex(object, index any) (any, error) {
+	if m, ok := object.(*Map); ok {
+		if err := CheckKey(index); err != nil {
+			return nil, err
+		}
+		v, ok := m.Get(index)
+		if !ok {
+			return nil, fmt.Errorf("Key %s isn't in the map.", quoteKey(index))
+		}
+		return v, nil
+	}
+	l, i, err := element(object, index)
+	if err != nil {
+		return nil, err
+	}
+	return l.Elements[i], nil
+}
+
+// SetIndex implements list[index] = v and map[key] = v.
+func SetIndex(object, index, v any) error {
+	if m, ok := object.(*Map); ok {
+		return m.Set(index, v)
+	}
+	l, i, err := element(object, index)
+	if err != nil {
+		return err
+	}
+	l.Elements[i] = v
+	return nil
+}
+
+// GetProperty implements object.name.
+func GetProperty(alloc Allocator, object any, name string) (any, error) {
+	o, ok := object.(Object)
+	if !ok {
+		return nil, ErrProperties
+	}
+	return o.GetProperty(alloc, name)
+}
+
+// SetProperty implements object.name = v.
+func SetProperty(object any, name string, v any) error {
+	o, ok := object.(Object)
+	if !ok {
+		return ErrFields
+	}
+	return o.SetProperty(name, v)
+}
+
+// element checks that index is the index of an element of list.
+func element(list, index any) (*List, int, error) {
+	l, ok := list.(*List)
+	if !ok {
+		return nil, 0, ErrIndexList
+	}
+	n, ok := index.(float64)
+	if !ok || n != math.Trunc(n) {
+		return nil, 0, ErrIndexWhole
+	}
+	if n < 0 || n >= float64(len(l.Elements)) {
+		return nil, 0, fmt.Errorf("List index %s is out of range for a list of length %d.", Stringify(n), len(l.Elements))
+	}
+	return l, int(n), nil
+}
+
+// quoteKey formats a map key for an error message, quoting strings.
+func quoteKey(key any) string {
+	if s, ok := key.(string); ok {
+		return strconv.Quote(s)
+	}
+	return Stringify(key)
+}
+
+// Negate implements unary '-'.
+func Negate(v any) (any, error) {
+	n, ok := v.(float64)
+	if !ok {
+		return nil, ErrOperandNumber
+	}
+	return -n, nil
+}
+
+// Not implements unary '!'.
+func Not(v any) any {
+	return !IsTruthy(v)
+}
+
+// Add implements '+', which adds numbers and concatenates strings.
+func Add(a, b any) (any, error) {
+	switch a := a.(type) {
+	case float64:
+		if b, ok := b.(float64); ok {
+			return a + b, nil
+		}
+	case string:
+		if b, ok := b.(string); ok {
+			return a + b, nil
+		}
+	}
+	return nil, ErrOperandsNumbersString
+}
+
+// Subtract implements binary '-'.
+func Subtract(a, b any) (any, error) {
+	x, y, err := numbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return x - y, nil
+}
+
+// Multiply implements '*'.
+func Multiply(a, b any) (any, error) {
+	x, y, err := numbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return x * y, nil
+}
+
+// Divide implements '/'. Dividing by zero follows IEEE 754,
+// producing an infinity or NaN rather than an error.
+func Divide(a, b any) (any, error) {
+	x, y, err := numbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return x / y, nil
+}
+
+// Greater implements '>'.
+func Greater(a, b any) (any, error) {
+	x, y, err := numbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return x > y, nil
+}
+
+// GreaterEqual implements '>='.
+func GreaterEqual(a, b any) (any, error) {
+	x, y, err := numbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return x >= y, nil
+}
+
+// Less implements '<'.
+func Less(a, b any) (any, error) {
+	x, y, err := numbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return x < y, nil
+}
+
+// LessEqual implements '<='.
+func LessEqual(a, b any) (any, error) {
+	x, y, err := numbers(a, b)
+	if err != nil {
+		return nil, err
+	}
+	return x <= y, nil
+}
+
+func numbers(a, b any) (float64, float64, error) {
+	x, ok := a.(float64)
+	if !ok {
+		return 0, 0, ErrOperandsNumbers
+	}
+	y, ok := b.(float64)
+	if !ok {
+		return 0, 0, ErrOperandsNumbers
+	}
+	return x, y, nil
+}
@@ -0,0 +1,156 @@
+package value_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+func TestStringify(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		value any
+		want  string
+	}{
+		{nil, "nil"},
+		{true, "true"},
+		{false, "false"},
+		{3.0, "3"},
+		{2.5, "2.5"},
+		{-0.125, "-0.125"},
+		{1e21, "1000000000000000000000"},
+		{math.NaN(), "NaN"},
+		{math.Inf(1), "Infinity"},
+		{math.Inf(-1), "-Infinity"},
+		{"text", "text"},
+		{value.NewList(), "[]"},
+		{value.NewList(1.0, "a", value.NewList(true, nil)), "[1, a, [true, nil]]"},
+	}
+
+	for _, tc := range testCases {
+		if got := value.Stringify(tc.value); got != tc.want {
+			t.Errorf("Stringify(%#v): want %q, got %q", tc.value, tc.want, got)
+		}
+	}
+}
+
+func TestList_PrintsCyclesOnce(t *testing.T) {
+	t.Parallel()
+
+	inner := value.NewList()
+	outer := value.NewList(inner, inner)
+	inner.Elements = append(inner.Elements, outer)
+	if got, want := outer.String(), "[[[...]], [[...]]]"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestIndex(t *testing.T) {
+	t.Parallel()
+
+	list := value.NewList("a", "b")
+	if err := value.SetIndex(list, 1.0, "c"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := value.GetIndex(list, 1.0); err != nil || got != "c" {
+		t.Fatalf("want c, got %v (%v)", got, err)
+	}
+	if value.IsEqual(list, value.NewList("a", "c")) {
+		t.Error("lists with the same elements should only be equal to themselves")
+	}
+
+	if _, err := value.GetIndex("ab", 0.0); !errors.Is(err, value.ErrIndexList) {
+		t.Errorf("expected ErrIndexList, got %v", err)
+	}
+	if err := value.SetIndex(list, 1.5, nil); !errors.Is(err, value.ErrIndexWhole) {
+		t.Errorf("expected ErrIndexWhole, got %v", err)
+	}
+	if _, err := value.GetIndex(list, 2.0); err == nil || err.Error() != "List index 2 is out of range for a list of length 2." {
+		t.Errorf("expected an out of range error, got %v", err)
+	}
+}
+
+func TestMap(t *testing.T) {
+	t.Parallel()
+
+	var m value.Map
+	for _, key := range []any{"b", 1.0, nil, true} {
+		if err := m.Set(key, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.Set("b", "again"); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Delete(1.0) || m.Delete(1.0) {
+		t.Error("Delete should report whether the key was there")
+	}
+	if got, want := m.String(), "{b: again, nil: nil, true: true}"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+	if got, ok := m.Get(nil); !ok || got != nil {
+		t.Errorf("want nil, got %v (%t)", got, ok)
+	}
+	if m.Len() != 3 || len(m.Keys()) != 3 || len(m.Values()) != 3 {
+		t.Errorf("want 3 entries, got %d", m.Len())
+	}
+
+	if err := m.Set(value.NewList(), 1.0); !errors.Is(err, value.ErrMapKey) {
+		t.Errorf("expected ErrMapKey, got %v", err)
+	}
+	if err := m.Set(math.NaN(), 1.0); !errors.Is(err, value.ErrMapKeyNaN) {
+		t.Errorf("expected ErrMapKeyNaN, got %v", err)
+	}
+	if _, err := value.GetIndex(&m, "x"); err == nil || err.Error() != `Key "x" isn't in the map.` {
+		t.Errorf("expected a missing key error, got %v", err)
+	}
+
+	cycle := value.NewMap()
+	if err := cycle.Set("self", value.NewList(cycle)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := cycle.String(), "{self: [{...}]}"; got != want {
+		t.Errorf("want %q, got %q", want, got)
+	}
+}
+
+func TestIsTruthy(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		value any
+		want  bool
+	}{
+		{nil, false},
+		{false, false},
+		{true, true},
+		{0.0, true},
+		{"", true},
+	}
+
+	for _, tc := range testCases {
+		if got := value.IsTruthy(tc.value); got != tc.want {
+			t.Errorf("IsTruthy(%#v): want %t, got %t", tc.value, tc.want, got)
+		}
+	}
+}
+
+func TestOperators_RejectWrongTypes(t *testing.T) {
+	t.Parallel()
+
+	if _, err := value.Add(1.0, "a"); !errors.Is(err, value.ErrOperandsNumbersString) {
+		t.Errorf("Add: expected ErrOperandsNumbersString, got %v", err)
+	}
+	if _, err := value.Subtract("a", "b"); !errors.Is(err, value.ErrOperandsNumbers) {
+		t.Errorf("Subtract: expected ErrOperandsNumbers, got %v", err)
+	}
+	if _, err := value.Less(1.0, nil); !errors.Is(err, value.ErrOperandsNumbers) {
+		t.Errorf("Less: expected ErrOperandsNumbers, got %v", err)
+	}
+	if _, err := value.Negate(true); !errors.Is(err, value.ErrOperandNumber) {
+		t.Errorf("Negate: expected ErrOperandNumber, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrMemoryLimit is returned when a program allocates more memory than its Memory allows.
+var ErrMemoryLimit = errors.New("memory limit exceeded")
+
+// Memory accounts for the bytes programs allocate while they run,
+// and caps them. It can be shared by several VMs, and read while they run.
+//
+// Strings, lists and maps built at runtime are counted, whether by an
+// instruction, such as concatenation or a list literal, or by a native:
+// a string by its length, a list by value.ListSize plus value.SlotSize
+// for each element, and a map by value.MapSize plus value.EntrySize for each
+// entry. Growing a list or map counts the slots or entries added.
+// Constants are part of the program, and numbers, booleans and nil don't allocate.
+// Nothing a program allocates outlives it, so a VM releases what it
+// allocated when its program stops.
+type Memory struct {
+	limit int64
+	used  atomic.Int64
+}
+
+// NewMemory returns a Memory that allows limit bytes to be allocated at once,
+// or any amount if limit is 0.
+func NewMemory(limit int64) (*Memory, error) {
+	if limit < 0 {
+		return nil, errors.New("negative memory limit")
+	}
+	return &Memory{limit: limit}, nil
+}
+
+// Used returns the number of bytes allocated by running programs.
+func (m *Memory) Used() int64 {
+	return m.used.Load()
+}
+
+// allocate records an allocation of n bytes,
+// or returns ErrMemoryLimit if it would take usage over the limit.
+func (m *Memory) allocate(n int64) error {
+	for {
+		used := m.used.Load()
+		if m.limit > 0 && used+n > m.limit {
+			return ErrMemoryLimit
+		}
+		if m.used.CompareAndSwap(used, used+n) {
+			return nil
+		}
+	}
+}
+
+func (m *Memory) release(n int64) {
+	m.used.Add(-n)
+}
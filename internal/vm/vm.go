@@ -0,0 +1,529 @@
+// Package vm implements a stack-based virtual machine
+// that runs the bytecode produced by the compiler package.
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+
+	"github.com/taylorlowery/lox/internal/chunk"
+	"github.com/taylorlowery/lox/internal/value"
+)
+
+var (
+	// ErrCancelled is returned when a VM's context is cancelled while it runs.
+	ErrCancelled = errors.New("execution cancelled")
+	// ErrStepLimit is returned when a program runs more instructions than a VM allows.
+	ErrStepLimit = errors.New("step limit exceeded")
+	// ErrStackOverflow is returned when calls nest more deeply than a VM allows.
+	ErrStackOverflow = errors.New("stack overflow")
+)
+
+// RuntimeError is returned when a running program fails,
+// e.g. when an operator is applied to operands of the wrong type.
+type RuntimeError struct {
+	Line    int
+	Message string
+	// Trace lists the frames that were running when the error was raised,
+	// innermost first.
+	Trace []Frame
+	// Err is the cause of an error raised by the VM rather than the program,
+	// such as ErrStepLimit.
+	Err error
+}
+
+func (e RuntimeError) Error() string {
+	return e.Message
+}
+
+func (e RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Frame is an entry in a RuntimeError's trace.
+type Frame struct {
+	// Function is the name of the running function, "script" for top-level code.
+	Function string
+	// File is the chunk's source file, if it has one.
+	File string
+	Line int
+}
+
+// String formats a frame as "at function (file:line)".
+func (f Frame) String() string {
+	if f.File == "" {
+		return fmt.Sprintf("at %s (line %d)", f.Function, f.Line)
+	}
+	return fmt.Sprintf("at %s (%s:%d)", f.Function, f.File, f.Line)
+}
+
+type VM struct {
+	chunk *chunk.Chunk
+	ip    int
+	stack []any
+	// trace, if set, receives the stack and disassembly of every instruction before it runs.
+	trace io.Writer
+	// ctx, if set, stops the VM when it's cancelled
+	ctx context.Context
+	// maxSteps, if positive, is the number of instructions a program may run
+	maxSteps int
+	// maxCallDepth, if positive, is the number of frames that may be running at once
+	maxCallDepth int
+	// callDepth is the number of frames running: the script's, if there is one,
+	// and those of the natives being called
+	callDepth int
+	// memory, if set, accounts for the strings a program builds
+	memory *Memory
+	// allocated is the number of bytes the running program has taken from memory
+	allocated int64
+	// globals holds the values programs can refer to by name
+	globals map[string]any
+}
+
+// cancelCheckInterval is how many instructions run between checks of a VM's context.
+const cancelCheckInterval = 1024
+
+// Option configures a VM.
+type Option func(vm *VM) error
+
+// NewVM returns a VM with an empty stack.
+func NewVM(opts ...Option) (*VM, error) {
+	vm := &VM{
+		stack: make([]any, 0, 256),
+	}
+	for _, opt := range opts {
+		err := opt(vm)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return vm, nil
+}
+
+// WithTrace configures a VM to write the contents of its stack
+// and the disassembled instruction to a given writer before executing each instruction.
+func WithTrace(w io.Writer) Option {
+	return func(vm *VM) error {
+		if w == nil {
+			return errors.New("nil trace writer")
+		}
+		vm.trace = w
+		return nil
+	}
+}
+
+// WithContext configures a VM to stop running a program when a given context is done,
+// returning an error that wraps ErrCancelled and the context's error.
+func WithContext(ctx context.Context) Option {
+	return func(vm *VM) error {
+		if ctx == nil {
+			return errors.New("nil context")
+		}
+		vm.ctx = ctx
+		return nil
+	}
+}
+
+// WithMaxSteps configures a VM to stop a program that tries to run more than n instructions,
+// returning an error that wraps ErrStepLimit.
+func WithMaxSteps(n int) Option {
+	return func(vm *VM) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid step limit %d", n)
+		}
+		vm.maxSteps = n
+		return nil
+	}
+}
+
+// WithMaxCallDepth configures a VM to stop a call that would make more than n frames run at once,
+// counting the script's, returning an error that wraps ErrStackOverflow.
+// Lox has no functions of its own yet, so calls only nest when a native calls back into the VM.
+func WithMaxCallDepth(n int) Option {
+	return func(vm *VM) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid call depth limit %d", n)
+		}
+		vm.maxCallDepth = n
+		return nil
+	}
+}
+
+// WithMemory configures a VM to account for the memory its programs allocate with m,
+// stopping a program with an error that wraps ErrMemoryLimit if it goes over m's limit.
+func WithMemory(m *Memory) Option {
+	return func(vm *VM) error {
+		if m == nil {
+			return errors.New("nil memory")
+		}
+		vm.memory = m
+		return nil
+	}
+}
+
+// WithGlobals configures a VM with the global variables programs can read,
+// such as native functions. The map isn't copied, and mustn't be changed while a program runs.
+func WithGlobals(globals map[string]any) Option {
+	return func(vm *VM) error {
+		if globals == nil {
+			return errors.New("nil globals")
+		}
+		vm.globals = globals
+		return nil
+	}
+}
+
+// Run executes a chunk and returns the value it produces.
+func (vm *VM) Run(c *chunk.Chunk) (any, error) {
+	vm.chunk = c
+	vm.ip = 0
+	vm.stack = vm.stack[:0]
+	vm.callDepth = 1
+	defer func() { vm.callDepth = 0 }()
+	if vm.memory != nil {
+		defer func() {
+			vm.memory.release(vm.allocated)
+			vm.allocated = 0
+		}()
+	}
+	return vm.run()
+}
+
+func (vm *VM) run() (any, error) {
+	for steps := 0; ; steps++ {
+		if vm.maxSteps > 0 && steps >= vm.maxSteps {
+			return nil, vm.stop(ErrStepLimit)
+		}
+		if vm.ctx != nil && steps%cancelCheckInterval == 0 {
+			if err := context.Cause(vm.ctx); err != nil {
+				return nil, vm.stop(fmt.Errorf("%w: %w", ErrCancelled, err))
+			}
+		}
+		if vm.trace != nil {
+			vm.traceInstruction()
+		}
+		op := chunk.OpCode(vm.readByte())
+		switch op {
+		case chunk.OP_CONSTANT:
+			vm.push(vm.chunk.Constants()[vm.readByte()])
+		case chunk.OP_CONSTANT_LONG:
+			index := int(vm.readByte())<<16 | int(vm.readByte())<<8 | int(vm.readByte())
+			vm.push(vm.chunk.Constants()[index])
+		case chunk.OP_NIL:
+			vm.push(nil)
+		case chunk.OP_TRUE:
+			vm.push(true)
+		case chunk.OP_FALSE:
+			vm.push(false)
+		case chunk.OP_POP:
+			vm.pop()
+		case chunk.OP_EQUAL:
+			b := vm.pop()
+			a := vm.pop()
+			vm.push(value.IsEqual(a, b))
+		case chunk.OP_GREATER:
+			if err := vm.binary(value.Greater); err != nil {
+				return nil, err
+			}
+		case chunk.OP_GREATER_EQUAL:
+			if err := vm.binary(value.GreaterEqual); err != nil {
+				return nil, err
+			}
+		case chunk.OP_LESS:
+			if err := vm.binary(value.Less); err != nil {
+				return nil, err
+			}
+		case chunk.OP_LESS_EQUAL:
+			if err := vm.binary(value.LessEqual); err != nil {
+				return nil, err
+			}
+		case chunk.OP_ADD:
+			if err := vm.allocateConcatenation(); err != nil {
+				return nil, err
+			}
+			if err := vm.binary(value.Add); err != nil {
+				return nil, err
+			}
+		case chunk.OP_SUBTRACT:
+			if err := vm.binary(value.Subtract); err != nil {
+				return nil, err
+			}
+		case chunk.OP_MULTIPLY:
+			if err := vm.binary(value.Multiply); err != nil {
+				return nil, err
+			}
+		case chunk.OP_DIVIDE:
+			if err := vm.binary(value.Divide); err != nil {
+				return nil, err
+			}
+		case chunk.OP_NOT:
+			vm.push(value.Not(vm.pop()))
+		case chunk.OP_NEGATE:
+			result, err := value.Negate(vm.peek(0))
+			if err != nil {
+				return nil, vm.runtimeError(err.Error())
+			}
+			vm.stack[len(vm.stack)-1] = result
+		case chunk.OP_JUMP:
+			vm.ip += vm.readShort()
+		case chunk.OP_JUMP_IF_FALSE:
+			offset := vm.readShort()
+			if !value.IsTruthy(vm.peek(0)) {
+				vm.ip += offset
+			}
+		case chunk.OP_JUMP_IF_TRUE:
+			offset := vm.readShort()
+			if value.IsTruthy(vm.peek(0)) {
+				vm.ip += offset
+			}
+		case chunk.OP_GET_GLOBAL:
+			name := vm.chunk.Constants()[vm.readByte()].(string)
+			v, ok := vm.globals[name]
+			if !ok {
+				return nil, vm.runtimeError(fmt.Sprintf("Undefined variable '%s'.", name))
+			}
+			vm.push(v)
+		case chunk.OP_CALL:
+			if err := vm.call(int(vm.readByte())); err != nil {
+				return nil, err
+			}
+		case chunk.OP_BUILD_LIST:
+			n := vm.readShort()
+			if err := vm.allocate(value.ListSize + int64(n)*value.SlotSize); err != nil {
+				return nil, err
+			}
+			elements := slices.Clone(vm.stack[len(vm.stack)-n:])
+			vm.stack = vm.stack[:len(vm.stack)-n]
+			vm.push(value.NewList(elements...))
+		case chunk.OP_BUILD_MAP:
+			n := vm.readShort()
+			if err := vm.allocate(value.MapSize + int64(n)*value.EntrySize); err != nil {
+				return nil, err
+			}
+			entries := vm.stack[len(vm.stack)-2*n:]
+			m := value.NewMap()
+			for i := 0; i < len(entries); i += 2 {
+				if err := m.Set(entries[i], entries[i+1]); err != nil {
+					return nil, vm.runtimeError(err.Error())
+				}
+			}
+			vm.stack = vm.stack[:len(vm.stack)-2*n]
+			vm.push(m)
+		case chunk.OP_GET_INDEX:
+			if err := vm.binary(value.GetIndex); err != nil {
+				return nil, err
+			}
+		case chunk.OP_SET_INDEX:
+			if err := vm.allocateEntry(); err != nil {
+				return nil, err
+			}
+			if err := value.SetIndex(vm.peek(2), vm.peek(1), vm.peek(0)); err != nil {
+				return nil, vm.runtimeError(err.Error())
+			}
+			v := vm.pop()
+			vm.stack = vm.stack[:len(vm.stack)-2]
+			vm.push(v)
+		case chunk.OP_GET_PROPERTY:
+			name := vm.chunk.Constants()[vm.readByte()].(string)
+			v, err := value.GetProperty(vm, vm.peek(0), name)
+			if err != nil {
+				return nil, vm.errorAt(vm.ip-1, err.Error(), err)
+			}
+			vm.stack[len(vm.stack)-1] = v
+		case chunk.OP_SET_PROPERTY:
+			name := vm.chunk.Constants()[vm.readByte()].(string)
+			if err := value.SetProperty(vm.peek(1), name, vm.peek(0)); err != nil {
+				return nil, vm.errorAt(vm.ip-1, err.Error(), err)
+			}
+			v := vm.pop()
+			vm.stack[len(vm.stack)-1] = v
+		case chunk.OP_RETURN:
+			return vm.pop(), nil
+		default:
+			return nil, vm.runtimeError(fmt.Sprintf("unknown opcode %d", op))
+		}
+	}
+}
+
+// binary replaces the top two values on the stack with the result of op.
+// The operands stay on the stack if op fails.
+func (vm *VM) binary(op func(a, b any) (any, error)) error {
+	result, err := op(vm.peek(1), vm.peek(0))
+	if err != nil {
+		return vm.runtimeError(err.Error())
+	}
+	vm.pop()
+	vm.stack[len(vm.stack)-1] = result
+	return nil
+}
+
+// call calls the function below the top argc values on the stack,
+// replacing it and its arguments with the result.
+func (vm *VM) call(argc int) error {
+	native, err := callable(vm.peek(argc), argc)
+	if err != nil {
+		return vm.runtimeError(err.Error())
+	}
+	if vm.maxCallDepth > 0 && vm.callDepth >= vm.maxCallDepth {
+		return vm.errorAt(vm.ip-1, ErrStackOverflow.Error(), ErrStackOverflow)
+	}
+	// natives get their own copy, so they can keep it
+	args := slices.Clone(vm.stack[len(vm.stack)-argc:])
+	vm.callDepth++
+	result, err := native.Fn(vm, args)
+	vm.callDepth--
+	if err != nil {
+		return vm.errorAt(vm.ip-1, err.Error(), err)
+	}
+	vm.stack = vm.stack[:len(vm.stack)-argc]
+	vm.stack[len(vm.stack)-1] = result
+	return nil
+}
+
+// Call calls a native function with args and returns its result.
+// Outside of any program, the call is given the VM's memory limit, like a program,
+// and releases what it allocated when it returns. A native can also use Call
+// to call back into the VM running it, making a nested call.
+// An error is returned as a RuntimeError without a line or trace.
+func (vm *VM) Call(callee any, args []any) (any, error) {
+	if vm.maxCallDepth > 0 && vm.callDepth >= vm.maxCallDepth {
+		return nil, RuntimeError{Message: ErrStackOverflow.Error(), Err: ErrStackOverflow}
+	}
+	if vm.memory != nil && vm.callDepth == 0 {
+		defer func() {
+			vm.memory.release(vm.allocated)
+			vm.allocated = 0
+		}()
+	}
+	native, err := callable(callee, len(args))
+	if err != nil {
+		return nil, RuntimeError{Message: err.Error()}
+	}
+	vm.callDepth++
+	result, err := native.Fn(vm, slices.Clone(args))
+	vm.callDepth--
+	if err != nil {
+		return nil, RuntimeError{Message: err.Error(), Err: err}
+	}
+	return result, nil
+}
+
+// callable checks that callee can be called with argc arguments.
+func callable(callee any, argc int) (*value.Native, error) {
+	native, ok := callee.(*value.Native)
+	if !ok {
+		return nil, errors.New("Can only call functions and classes.")
+	}
+	if native.Arity >= 0 && argc != native.Arity {
+		return nil, fmt.Errorf("Expected %d arguments but got %d.", native.Arity, argc)
+	}
+	return native, nil
+}
+
+// allocateConcatenation accounts for the string OP_ADD is about to build,
+// if its operands are strings, before it's built.
+func (vm *VM) allocateConcatenation() error {
+	if vm.memory == nil {
+		return nil
+	}
+	a, ok := vm.peek(1).(string)
+	b, ok2 := vm.peek(0).(string)
+	if !ok || !ok2 {
+		return nil
+	}
+	return vm.allocate(int64(len(a) + len(b)))
+}
+
+// allocateEntry accounts for the entry OP_SET_INDEX is about to add,
+// if it sets a key its map doesn't have yet.
+func (vm *VM) allocateEntry() error {
+	m, ok := vm.peek(2).(*value.Map)
+	if !ok || value.CheckKey(vm.peek(1)) != nil {
+		return nil
+	}
+	if _, ok := m.Get(vm.peek(1)); ok {
+		return nil
+	}
+	return vm.allocate(value.EntrySize)
+}
+
+// allocate is Allocate for the current instruction,
+// reporting ErrMemoryLimit as a RuntimeError.
+func (vm *VM) allocate(n int64) error {
+	if err := vm.Allocate(n); err != nil {
+		return vm.errorAt(vm.ip-1, err.Error(), err)
+	}
+	return nil
+}
+
+// Allocate accounts for n bytes the running program allocates,
+// returning ErrMemoryLimit if they would take it over the VM's limit.
+// Natives the VM calls are given it as their allocator.
+func (vm *VM) Allocate(n int64) error {
+	if vm.memory == nil {
+		return nil
+	}
+	if err := vm.memory.allocate(n); err != nil {
+		return err
+	}
+	vm.allocated += n
+	return nil
+}
+
+// traceInstruction writes the stack, bottom first, then the next instruction.
+func (vm *VM) traceInstruction() {
+	fmt.Fprint(vm.trace, "          ")
+	for _, v := range vm.stack {
+		fmt.Fprintf(vm.trace, "[ %s ]", value.Stringify(v))
+	}
+	fmt.Fprintln(vm.trace)
+	chunk.DisassembleInstruction(vm.trace, vm.chunk, vm.ip)
+}
+
+func (vm *VM) readByte() byte {
+	b := vm.chunk.Code[vm.ip]
+	vm.ip++
+	return b
+}
+
+func (vm *VM) readShort() int {
+	return int(vm.readByte())<<8 | int(vm.readByte())
+}
+
+func (vm *VM) push(v any) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *VM) pop() any {
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v
+}
+
+func (vm *VM) peek(distance int) any {
+	return vm.stack[len(vm.stack)-1-distance]
+}
+
+// runtimeError reports an error at the line of the instruction being executed.
+func (vm *VM) runtimeError(message string) RuntimeError {
+	return vm.errorAt(vm.ip-1, message, nil)
+}
+
+// stop reports that the VM stopped before the next instruction, because of err.
+func (vm *VM) stop(err error) RuntimeError {
+	return vm.errorAt(vm.ip, err.Error(), err)
+}
+
+func (vm *VM) errorAt(offset int, message string, err error) RuntimeError {
+	line := vm.chunk.Line(offset)
+	return RuntimeError{
+		Line:    line,
+		Message: message,
+		// there are no functions yet, so the only frame is the script's
+		Trace: []Frame{{Function: "script", File: vm.chunk.File, Line: line}},
+		Err:   err,
+	}
+}
@@ -0,0 +1,728 @@
+package vm_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/taylorlowery/lox/internal/compiler"
+	"github.com/taylorlowery/lox/internal/parser"
+	"github.com/taylorlowery/lox/internal/scanner"
+	"github.com/taylorlowery/lox/internal/value"
+	"github.com/taylorlowery/lox/internal/vm"
+)
+
+func run(t *testing.T, source string, opts ...vm.Option) (any, error) {
+	t.Helper()
+	tokens, scanErr := scanner.NewScanner(source).ScanTokens()
+	if scanErr != nil {
+		t.Fatal(scanErr)
+	}
+	expr, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := compiler.Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine, err := vm.NewVM(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return machine.Run(c)
+}
+
+func TestVM_EvaluatesExpressions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source string
+		want   any
+	}{
+		{"1 + 2 * 3", 7.0},
+		{"(1 + 2) * 3", 9.0},
+		{"10 / 4 - 1", 1.5},
+		{"-(2 - 5)", 3.0},
+		{`"con" + "cat"`, "concat"},
+		{"1 < 2", true},
+		{"2 <= 2", true},
+		{"1 > 2", false},
+		{"2 >= 3", false},
+		{"0 / 0 >= 0", false},
+		{"1 == 1", true},
+		{`"a" != "a"`, false},
+		{"nil == false", false},
+		{"!nil", true},
+		{"!0", false},
+		{"true ? 1 : 2", 1.0},
+		{"nil ? 1 : 2", 2.0},
+		{"false ? 1 : nil ? 2 : 3", 3.0},
+		{"1, 2", 2.0},
+		{"false ? -nil : 1", 1.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			got, err := run(t, tc.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestVM_RuntimeErrors(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source  string
+		message string
+		line    int
+	}{
+		{`-"a"`, "Operand must be a number.", 1},
+		{`1 +` + "\n" + `"a"`, "Operands must be two numbers or two strings.", 1},
+		{"1 -\n2 -\nnil", "Operands must be numbers.", 2},
+		{"true ? nil * 2 : 1", "Operands must be numbers.", 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			_, err := run(t, tc.source)
+			var runtimeErr vm.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected a RuntimeError, got %v", err)
+			}
+			if runtimeErr.Message != tc.message {
+				t.Errorf("want message %q, got %q", tc.message, runtimeErr.Message)
+			}
+			if runtimeErr.Line != tc.line {
+				t.Errorf("want line %d, got %d", tc.line, runtimeErr.Line)
+			}
+			wantTrace := []vm.Frame{{Function: "script", Line: tc.line}}
+			if !slices.Equal(runtimeErr.Trace, wantTrace) {
+				t.Errorf("want trace %v, got %v", wantTrace, runtimeErr.Trace)
+			}
+		})
+	}
+}
+
+func TestVM_CallsNatives(t *testing.T) {
+	t.Parallel()
+
+	errTooBig := errors.New("Number is too big.")
+	globals := map[string]any{
+		"answer": 42.0,
+		"add": &value.Native{Name: "add", Arity: 2, Fn: func(alloc value.Allocator, args []any) (any, error) {
+			return value.Add(args[0], args[1])
+		}},
+		"count": &value.Native{Name: "count", Arity: -1, Fn: func(alloc value.Allocator, args []any) (any, error) {
+			return float64(len(args)), nil
+		}},
+		"check": &value.Native{Name: "check", Arity: 1, Fn: func(alloc value.Allocator, args []any) (any, error) {
+			if args[0].(float64) > 10 {
+				return nil, errTooBig
+			}
+			return args[0], nil
+		}},
+	}
+
+	testCases := []struct {
+		source string
+		want   any
+	}{
+		{"answer", 42.0},
+		{"add(1, 2) * 3", 9.0},
+		{`add("a", "b")`, "ab"},
+		{"count()", 0.0},
+		{"count(1, 2, 3)", 3.0},
+		{"add(count(1), count(1, 2))", 3.0},
+		{"check(5)", 5.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			got, err := run(t, tc.source, vm.WithGlobals(globals))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		source  string
+		message string
+		line    int
+	}{
+		{"missing", "Undefined variable 'missing'.", 1},
+		{"add(1)", "Expected 2 arguments but got 1.", 1},
+		{"1,\nanswer()", "Can only call functions and classes.", 2},
+		{`add(1, "a")`, "Operands must be two numbers or two strings.", 1},
+		{"check(\n11)", "Number is too big.", 2},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.source, func(t *testing.T) {
+			_, err := run(t, tc.source, vm.WithGlobals(globals))
+			var runtimeErr vm.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected a RuntimeError, got %v", err)
+			}
+			if runtimeErr.Message != tc.message {
+				t.Errorf("want message %q, got %q", tc.message, runtimeErr.Message)
+			}
+			if runtimeErr.Line != tc.line {
+				t.Errorf("want line %d, got %d", tc.line, runtimeErr.Line)
+			}
+		})
+	}
+
+	t.Run("native errors are wrapped", func(t *testing.T) {
+		_, err := run(t, "check(11)", vm.WithGlobals(globals))
+		if !errors.Is(err, errTooBig) {
+			t.Fatalf("want %v, got %v", errTooBig, err)
+		}
+	})
+}
+
+func TestVM_CallCallsANativeOutsideAProgram(t *testing.T) {
+	t.Parallel()
+
+	memory, err := vm.NewMemory(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	machine, err := vm.NewVM(vm.WithMemory(memory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	repeat := &value.Native{Name: "repeat", Arity: 1, Fn: func(alloc value.Allocator, args []any) (any, error) {
+		s := args[0].(string) + args[0].(string)
+		if err := alloc.Allocate(int64(len(s))); err != nil {
+			return nil, err
+		}
+		return s, nil
+	}}
+
+	got, err := machine.Call(repeat, []any{"ab"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "abab" {
+		t.Fatalf("want abab, got %#v", got)
+	}
+	if used := memory.Used(); used != 0 {
+		t.Errorf("expected memory to be released after the call, got %d", used)
+	}
+
+	if _, err := machine.Call(repeat, []any{"abc"}); !errors.Is(err, vm.ErrMemoryLimit) {
+		t.Errorf("want %v, got %v", vm.ErrMemoryLimit, err)
+	}
+	var runtimeErr vm.RuntimeError
+	if _, err := machine.Call(repeat, nil); !errors.As(err, &runtimeErr) || runtimeErr.Message != "Expected 1 arguments but got 0." {
+		t.Errorf("expected an arity error, got %v", err)
+	}
+	if _, err := machine.Call("repeat", nil); !errors.As(err, &runtimeErr) || runtimeErr.Message != "Can only call functions and classes." {
+		t.Errorf("expected an error calling a string, got %v", err)
+	}
+}
+
+func TestVM_Lists(t *testing.T) {
+	t.Parallel()
+
+	list := value.NewList(1.0, 2.0)
+	globals := map[string]any{
+		"list": list,
+		"first": &value.Native{Name: "first", Arity: 1, Fn: func(alloc value.Allocator, args []any) (any, error) {
+			return value.GetIndex(args[0], 0.0)
+		}},
+	}
+
+	testCases := []struct {
+		source string
+		want   string
+	}{
+		{"[]", "[]"},
+		{`[1, "a", [true, nil]]`, "[1, a, [true, nil]]"},
+		{"[1, 2, 3][1 + 1]", "3"},
+		{"[[1, 2], [3]][0][1]", "2"},
+		{"[0][0] = 5", "5"},
+		{"first([0][0] = [7])", "7"},
+		{"[1, 2][1] = [3][0] = 4", "4"},
+		{"list[0] = list", "[[...], 2]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			got, err := run(t, tc.source, vm.WithGlobals(globals))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := value.Stringify(got); s != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, s)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		source  string
+		message string
+	}{
+		{"[1][1]", "List index 1 is out of range for a list of length 1."},
+		{"[1][-1]", "List index -1 is out of range for a list of length 1."},
+		{"[1][0.5]", "List index must be a whole number."},
+		{`[1]["0"]`, "List index must be a whole number."},
+		{`"abc"[0]`, "Can only index lists and maps."},
+		{"[][0] = 1", "List index 0 is out of range for a list of length 0."},
+		{"nil[0] = 1", "Can only index lists and maps."},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.source, func(t *testing.T) {
+			_, err := run(t, tc.source, vm.WithGlobals(globals))
+			var runtimeErr vm.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected a RuntimeError, got %v", err)
+			}
+			if runtimeErr.Message != tc.message {
+				t.Errorf("want message %q, got %q", tc.message, runtimeErr.Message)
+			}
+		})
+	}
+}
+
+func TestVM_Maps(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source string
+		want   string
+	}{
+		{"{}", "{}"},
+		{`{"b": 1, "a": [2], nil: {true: false}}`, "{b: 1, a: [2], nil: {true: false}}"},
+		{`{"a": 1, "b": 2, "a": 3}`, "{a: 3, b: 2}"},
+		{`{"a": 1, 2: "b"}[2]`, "b"},
+		{"{0: 1}[-0]", "1"},
+		{`{"a": 1}["a"] = 2`, "2"},
+		{`[{}][0]["x"] = [1][0] = {}`, "{}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			got, err := run(t, tc.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := value.Stringify(got); s != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, s)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		source  string
+		message string
+		line    int
+	}{
+		{`{"a": 1}["b"]`, `Key "b" isn't in the map.`, 1},
+		{"{}[1.5]", "Key 1.5 isn't in the map.", 1},
+		{"{\n[]: 1}", "Map keys must be strings, numbers, booleans or nil.", 1},
+		{"{}[{}] = 1", "Map keys must be strings, numbers, booleans or nil.", 1},
+		{"{}[0/0]", "NaN can't be a map key.", 1},
+		{"{}[0/0] = 1", "NaN can't be a map key.", 1},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.source, func(t *testing.T) {
+			_, err := run(t, tc.source)
+			var runtimeErr vm.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected a RuntimeError, got %v", err)
+			}
+			if runtimeErr.Message != tc.message {
+				t.Errorf("want message %q, got %q", tc.message, runtimeErr.Message)
+			}
+			if runtimeErr.Line != tc.line {
+				t.Errorf("want line %d, got %d", tc.line, runtimeErr.Line)
+			}
+		})
+	}
+}
+
+// point is an object with the properties x and y.
+type point struct {
+	x, y any
+}
+
+func (p *point) GetProperty(alloc value.Allocator, name string) (any, error) {
+	switch name {
+	case "x":
+		return p.x, nil
+	case "y":
+		return p.y, nil
+	default:
+		return nil, fmt.Errorf("Undefined property '%s'.", name)
+	}
+}
+
+func (p *point) SetProperty(name string, v any) error {
+	switch name {
+	case "x":
+		p.x = v
+	case "y":
+		p.y = v
+	default:
+		return fmt.Errorf("Undefined property '%s'.", name)
+	}
+	return nil
+}
+
+func (p *point) String() string {
+	return "point instance"
+}
+
+func TestVM_Properties(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source string
+		want   string
+	}{
+		{"p.x + p.y", "3"},
+		{"p", "point instance"},
+		{"p.x = 5", "5"},
+		{"(p.x = p.y = 4), p.x * p.y", "16"},
+		{"[p][0].y = [7], p.y[0]", "7"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			globals := map[string]any{"p": &point{x: 1.0, y: 2.0}}
+			got, err := run(t, tc.source, vm.WithGlobals(globals))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := value.Stringify(got); s != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, s)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		source  string
+		message string
+		line    int
+	}{
+		{"p.z", "Undefined property 'z'.", 1},
+		{"p.z = 1", "Undefined property 'z'.", 1},
+		{"1,\n[].x", "Only instances have properties.", 2},
+		{`"a".x = 1`, "Only instances have fields.", 1},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.source, func(t *testing.T) {
+			globals := map[string]any{"p": &point{x: 1.0, y: 2.0}}
+			_, err := run(t, tc.source, vm.WithGlobals(globals))
+			var runtimeErr vm.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected a RuntimeError, got %v", err)
+			}
+			if runtimeErr.Message != tc.message {
+				t.Errorf("want message %q, got %q", tc.message, runtimeErr.Message)
+			}
+			if runtimeErr.Line != tc.line {
+				t.Errorf("want line %d, got %d", tc.line, runtimeErr.Line)
+			}
+		})
+	}
+}
+
+func TestFrame_String(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		frame vm.Frame
+		want  string
+	}{
+		{vm.Frame{Function: "greet", File: "hello.lox", Line: 4}, "at greet (hello.lox:4)"},
+		{vm.Frame{Function: "script", Line: 1}, "at script (line 1)"},
+	}
+
+	for _, tc := range testCases {
+		if got := tc.frame.String(); got != tc.want {
+			t.Errorf("want %q, got %q", tc.want, got)
+		}
+	}
+}
+
+func TestVM_TraceWritesStackBeforeEachInstruction(t *testing.T) {
+	t.Parallel()
+
+	tokens, scanErr := scanner.NewScanner("-(1 + 2)").ScanTokens()
+	if scanErr != nil {
+		t.Fatal(scanErr)
+	}
+	expr, err := parser.NewParser(tokens).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := compiler.Compile(expr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trace bytes.Buffer
+	machine, err := vm.NewVM(vm.WithTrace(&trace))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := machine.Run(c); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `          
+0000    1 OP_CONSTANT         0 '1'
+          [ 1 ]
+0002    | OP_CONSTANT         1 '2'
+          [ 1 ][ 2 ]
+0004    | OP_ADD
+          [ 3 ]
+0005    | OP_NEGATE
+          [ -3 ]
+0006    | OP_RETURN
+`
+	if got := trace.String(); got != want {
+		t.Fatalf("want trace:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestNewVM_RejectsNilTraceWriter(t *testing.T) {
+	t.Parallel()
+
+	if _, err := vm.NewVM(vm.WithTrace(nil)); err == nil {
+		t.Fatal("expected an error for a nil trace writer")
+	}
+}
+
+func TestVM_StopsAtLimits(t *testing.T) {
+	t.Parallel()
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name string
+		opts []vm.Option
+		want error
+	}{
+		{
+			name: "step limit",
+			opts: []vm.Option{vm.WithMaxSteps(3)},
+			want: vm.ErrStepLimit,
+		},
+		{
+			name: "cancelled context",
+			opts: []vm.Option{vm.WithContext(cancelled)},
+			want: vm.ErrCancelled,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := run(t, "1 + 2 + 3", tc.opts...)
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("want %v, got %v", tc.want, err)
+			}
+			var runtimeErr vm.RuntimeError
+			if !errors.As(err, &runtimeErr) || runtimeErr.Line != 1 {
+				t.Fatalf("expected a RuntimeError on line 1, got %#v", err)
+			}
+		})
+	}
+}
+
+func TestVM_StopsAtCallDepth(t *testing.T) {
+	t.Parallel()
+
+	// nest calls itself through the VM running it, n times
+	var nest *value.Native
+	nest = &value.Native{Name: "nest", Arity: 1, Fn: func(alloc value.Allocator, args []any) (any, error) {
+		n := args[0].(float64)
+		if n == 0 {
+			return "done", nil
+		}
+		return alloc.(*vm.VM).Call(nest, []any{n - 1})
+	}}
+	globals := map[string]any{"nest": nest}
+
+	// the script and nest(2), nest(1) and nest(0) make 4 frames
+	got, err := run(t, "nest(2)", vm.WithGlobals(globals), vm.WithMaxCallDepth(4))
+	if err != nil || got != "done" {
+		t.Fatalf("want done, got %v, %v", got, err)
+	}
+	_, err = run(t, "nest(3)", vm.WithGlobals(globals), vm.WithMaxCallDepth(4))
+	if !errors.Is(err, vm.ErrStackOverflow) {
+		t.Fatalf("want %v, got %v", vm.ErrStackOverflow, err)
+	}
+	_, err = run(t, "\nnest(0)", vm.WithGlobals(globals), vm.WithMaxCallDepth(1))
+	var runtimeErr vm.RuntimeError
+	if !errors.As(err, &runtimeErr) || !errors.Is(err, vm.ErrStackOverflow) || runtimeErr.Line != 2 {
+		t.Fatalf("expected a stack overflow on line 2, got %#v", err)
+	}
+}
+
+func TestVM_CancelledErrorWrapsContextError(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := run(t, "nil", vm.WithContext(ctx))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("want %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestVM_RunsWithinLimits(t *testing.T) {
+	t.Parallel()
+
+	// 3 constants, 2 additions and a return
+	got, err := run(t, "1 + 2 + 3", vm.WithMaxSteps(6), vm.WithContext(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 6.0 {
+		t.Fatalf("want 6, got %v", got)
+	}
+}
+
+func TestNewVM_RejectsInvalidLimits(t *testing.T) {
+	t.Parallel()
+
+	for _, opt := range []vm.Option{vm.WithMaxSteps(0), vm.WithMaxSteps(-1), vm.WithContext(nil), vm.WithMaxCallDepth(0)} {
+		if _, err := vm.NewVM(opt); err == nil {
+			t.Error("expected an error")
+		}
+	}
+}
+
+// usageWriter records a Memory's usage each time the VM traces an instruction.
+type usageWriter struct {
+	memory *vm.Memory
+	usage  []int64
+}
+
+func (w *usageWriter) Write(p []byte) (int, error) {
+	w.usage = append(w.usage, w.memory.Used())
+	return len(p), nil
+}
+
+func TestVM_AccountsForConcatenation(t *testing.T) {
+	t.Parallel()
+
+	memory, err := vm.NewMemory(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := &usageWriter{memory: memory}
+	got, err := run(t, `"ab" + "cd" + "e"`, vm.WithMemory(memory), vm.WithTrace(w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "abcde" {
+		t.Fatalf("want abcde, got %v", got)
+	}
+	// "abcd" then "abcde" were built
+	if peak := slices.Max(w.usage); peak != 9 {
+		t.Errorf("want peak usage 9, got %d", peak)
+	}
+	if used := memory.Used(); used != 0 {
+		t.Errorf("expected memory to be released after the run, got %d", used)
+	}
+}
+
+func TestVM_StopsAtMemoryLimit(t *testing.T) {
+	t.Parallel()
+
+	memory, err := vm.NewMemory(8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = run(t, "\"ab\" + \"cd\" +\n\"e\"", vm.WithMemory(memory))
+	if !errors.Is(err, vm.ErrMemoryLimit) {
+		t.Fatalf("want %v, got %v", vm.ErrMemoryLimit, err)
+	}
+	var runtimeErr vm.RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Line != 1 {
+		t.Fatalf("expected a RuntimeError on line 1, got %#v", err)
+	}
+	if used := memory.Used(); used != 0 {
+		t.Errorf("expected memory to be released after the run, got %d", used)
+	}
+
+	// numbers don't allocate
+	if _, err := run(t, "1 + 2", vm.WithMemory(memory)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVM_AccountsForCollections(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		source string
+		// want is the number of bytes the source allocates
+		want int64
+	}{
+		{name: "empty list", source: "[]", want: value.ListSize},
+		{name: "list", source: "[1, 2, 3]", want: value.ListSize + 3*value.SlotSize},
+		{name: "nested lists", source: "[[1], []]", want: 3*value.ListSize + 3*value.SlotSize},
+		{name: "empty map", source: "{}", want: value.MapSize},
+		{name: "map", source: `{"a": 1, "b": 2}`, want: value.MapSize + 2*value.EntrySize},
+		{name: "new key", source: `{"a": 1}["b"] = 2`, want: value.MapSize + 2*value.EntrySize},
+		{name: "existing key", source: `{"a": 1}["a"] = 2`, want: value.MapSize + value.EntrySize},
+		{name: "list element", source: `[1][0] = 2`, want: value.ListSize + value.SlotSize},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			memory, err := vm.NewMemory(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := &usageWriter{memory: memory}
+			if _, err := run(t, tc.source, vm.WithMemory(memory), vm.WithTrace(w)); err != nil {
+				t.Fatal(err)
+			}
+			if peak := slices.Max(w.usage); peak != tc.want {
+				t.Errorf("want peak usage %d, got %d", tc.want, peak)
+			}
+
+			memory, err = vm.NewMemory(tc.want - 1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := run(t, tc.source, vm.WithMemory(memory)); !errors.Is(err, vm.ErrMemoryLimit) {
+				t.Fatalf("want %v, got %v", vm.ErrMemoryLimit, err)
+			}
+		})
+	}
+}
+
+func TestNewMemory_RejectsNegativeLimits(t *testing.T) {
+	t.Parallel()
+
+	if _, err := vm.NewMemory(-1); err == nil {
+		t.Fatal("expected an error")
+	}
+}
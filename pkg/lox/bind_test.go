@@ -0,0 +1,339 @@
+package lox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/taylorlowery/lox/pkg/lox"
+)
+
+func TestWithFunc_ConvertsArgumentsAndResults(t *testing.T) {
+	t.Parallel()
+
+	errEmpty := errors.New("Name is empty.")
+	in, err := lox.NewInterpreter(
+		lox.WithFunc("repeat", strings.Repeat),
+		lox.WithFunc("half", func(n int) float32 { return float32(n) / 2 }),
+		lox.WithFunc("greet", func(name string) (string, error) {
+			if name == "" {
+				return "", errEmpty
+			}
+			return "hello " + name, nil
+		}),
+		lox.WithFunc("sum", func(ns ...uint8) int {
+			total := 0
+			for _, n := range ns {
+				total += int(n)
+			}
+			return total
+		}),
+		lox.WithFunc("join", func(sep string, parts ...string) string {
+			return strings.Join(parts, sep)
+		}),
+		lox.WithFunc("nothing", func() {}),
+		lox.WithFunc("index", func(s string, i int) string { return s[i : i+1] }),
+		lox.WithFunc("pointer", func() *int { return new(int) }),
+		lox.WithFunc("evens", func(ns []int) []int {
+			var evens []int
+			for _, n := range ns {
+				if n%2 == 0 {
+					evens = append(evens, n)
+				}
+			}
+			return evens
+		}),
+		lox.WithFunc("counts", func(words []string) map[string]int {
+			counts := map[string]int{}
+			for _, w := range words {
+				counts[w]++
+			}
+			return counts
+		}),
+		lox.WithFunc("total", func(m map[string]float64) float64 {
+			total := 0.0
+			for _, n := range m {
+				total += n
+			}
+			return total
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		source string
+		want   lox.Value
+	}{
+		{`repeat("ab", 3)`, "ababab"},
+		{"half(3)", 1.5},
+		{`greet("ada")`, "hello ada"},
+		{"sum()", 0.0},
+		{"sum(1, 2, 3)", 6.0},
+		{`join("-", "a", "b")`, "a-b"},
+		{"nothing()", nil},
+		{"evens([1, 2, 3, 4])[1]", 4.0},
+		{"len(evens([1, 3]))", 0.0},
+		{`counts(split("a b a", " "))["a"]`, 2.0},
+		{`total({"x": 1.5, "y": 2})`, 3.5},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+			got, err := in.Eval(context.Background(), tc.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		source  string
+		message string
+	}{
+		{`repeat("ab")`, "Expected 2 arguments but got 1."},
+		{`repeat(3, 3)`, "Bad argument 1 to repeat: cannot convert 3 to string: not a string."},
+		{"half(1.5)", "Bad argument 1 to half: cannot convert 1.5 to int: not a whole number."},
+		{"sum(256)", "Bad argument 1 to sum: cannot convert 256 to uint8: out of range."},
+		{"join()", "Expected at least 1 arguments but got 0."},
+		{`greet("")`, "Name is empty."},
+		{`index("a", 5)`, "Native function index failed: runtime error: slice bounds out of range [:6] with length 1."},
+		{"pointer()", "Bad result from pointer: cannot convert"},
+		{`evens([1, "2"])`, `Bad argument 1 to evens: cannot convert "2" to int: not a number.`},
+		{`total({1: 1})`, "Bad argument 1 to total: cannot convert 1 to string: not a string."},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+			_, err := in.Eval(context.Background(), tc.source)
+			var runtimeErr lox.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected a RuntimeError, got %#v", err)
+			}
+			if !strings.HasPrefix(runtimeErr.Message, tc.message) {
+				t.Fatalf("want message %q, got %q", tc.message, runtimeErr.Message)
+			}
+		})
+	}
+}
+
+func TestHostFunctions_ChargeMemoryForResults(t *testing.T) {
+	t.Parallel()
+
+	in, err := lox.NewInterpreter(
+		lox.WithMaxMemory(100),
+		lox.WithFunc("repeat", strings.Repeat),
+		lox.WithFunc("words", func(n int) []string { return make([]string, n) }),
+		lox.WithNative("same", 1, func(args []lox.Value) (lox.Value, error) {
+			return args[0], nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range []string{`repeat("a", 100)`, "words(4)", "same([1, 2])"} {
+		if _, err := in.Eval(context.Background(), source); err != nil {
+			t.Errorf("%s: %v", source, err)
+		}
+	}
+	for _, source := range []string{`repeat("a", 101)`, "words(5)"} {
+		if _, err := in.Eval(context.Background(), source); !errors.Is(err, lox.ErrMemoryLimit) {
+			t.Errorf("%s: want %v, got %v", source, lox.ErrMemoryLimit, err)
+		}
+	}
+}
+
+func TestWithFunc_RejectsNonFunctions(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name string
+		fn   any
+	}{
+		{"nil", nil},
+		{"nil function", (func())(nil)},
+		{"not a function", 42},
+		{"too many results", func() (int, int, error) { return 0, 0, nil }},
+		{"second result isn't an error", func() (int, int) { return 0, 0 }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := lox.NewInterpreter(lox.WithFunc("f", tc.fn)); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+type Point struct {
+	X, Y  float64
+	Label string
+	// hidden isn't exported, so programs can't see it
+	hidden int
+}
+
+func (p Point) Dist(q *Point) float64 {
+	return math.Hypot(q.X-p.X, q.Y-p.Y)
+}
+
+func (p *Point) Move(dx, dy float64) *Point {
+	p.X += dx
+	p.Y += dy
+	return p
+}
+
+func TestBindType_ExposesAStructAsAClass(t *testing.T) {
+	t.Parallel()
+
+	origin := &Point{Label: "origin"}
+	in, err := lox.NewInterpreter(lox.BindType[Point]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set("origin", origin); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		source string
+		want   lox.Value
+	}{
+		{`Point(3, 4, "a").Label`, "a"},
+		{`Point(3, 4, "a").Dist(origin)`, 5.0},
+		{`origin.Dist(Point(0, 2, ""))`, 2.0},
+		{`Point(1, 2, "").Move(2, 3).Y`, 5.0},
+		{`Point(1, 2, "").X = 7`, 7.0},
+		{"origin == origin", true},
+		{`Point(0, 0, "") == Point(0, 0, "")`, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+			got, err := in.Eval(context.Background(), tc.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+
+	errorCases := []struct {
+		source  string
+		message string
+	}{
+		{"Point(1, 2)", "Expected 3 arguments but got 2."},
+		{`Point("1", 2, "")`, `Bad argument 1 to Point: cannot convert "1" to float64: not a number.`},
+		{"origin.hidden", "Undefined property 'hidden'."},
+		{"origin.Missing = 1", "Undefined field 'Missing'."},
+		{`origin.X = "a"`, `Bad value for Point.X: cannot convert "a" to float64: not a number.`},
+		{"origin.Dist(1)", "Bad argument 1 to Dist: cannot convert 1 to *lox_test.Point: not a value of this type."},
+		{"origin.Dist(nil)", "Native function Dist failed: runtime error: invalid memory address or nil pointer dereference."},
+		{"origin.Move(1)", "Expected 2 arguments but got 1."},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+			_, err := in.Eval(context.Background(), tc.source)
+			var runtimeErr lox.RuntimeError
+			if !errors.As(err, &runtimeErr) {
+				t.Fatalf("expected a RuntimeError, got %#v", err)
+			}
+			if runtimeErr.Message != tc.message {
+				t.Fatalf("want message %q, got %q", tc.message, runtimeErr.Message)
+			}
+		})
+	}
+}
+
+func TestBindType_SharesInstancesWithGo(t *testing.T) {
+	t.Parallel()
+
+	p := &Point{X: 1}
+	in, err := lox.NewInterpreter(lox.BindType[Point]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set("p", p); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := in.Eval(context.Background(), `p.Move(1, 1).Label = "moved"`); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Point{X: 2, Y: 1, Label: "moved"}); *p != want {
+		t.Errorf("want %+v, got %+v", want, *p)
+	}
+
+	v, err := in.Eval(context.Background(), `Point(5, 6, "new")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := fmt.Sprint(v); s != "Point instance" {
+		t.Errorf("want Point instance, got %s", s)
+	}
+	got, err := lox.FromValue[*Point](v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Point{X: 5, Y: 6, Label: "new"}); *got != want {
+		t.Errorf("want %+v, got %+v", want, *got)
+	}
+	if copied, err := lox.FromValue[Point](v); err != nil || copied != *got {
+		t.Errorf("want a copy of %+v, got %+v, %v", *got, copied, err)
+	}
+}
+
+func TestBindType_ChargesMemoryForInstances(t *testing.T) {
+	t.Parallel()
+
+	size := int64(reflect.TypeFor[Point]().Size())
+	in, err := lox.NewInterpreter(lox.WithMaxMemory(2*size), lox.BindType[Point]())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Eval(context.Background(), `Point(1, 2, ""), Point(3, 4, "")`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Eval(context.Background(), `Point(1, 2, ""), Point(3, 4, ""), Point(5, 6, "")`); !errors.Is(err, lox.ErrMemoryLimit) {
+		t.Fatalf("want %v, got %v", lox.ErrMemoryLimit, err)
+	}
+}
+
+func TestBindType_RejectsOtherTypes(t *testing.T) {
+	t.Parallel()
+
+	for name, opt := range map[string]lox.Option{
+		"not a struct": lox.BindType[celsius](),
+		"pointer":      lox.BindType[*Point](),
+		"unnamed":      lox.BindType[struct{ X int }](),
+		"generic":      lox.BindType[pair[int]](),
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := lox.NewInterpreter(opt); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+type pair[T any] struct {
+	First, Second T
+}
@@ -0,0 +1,147 @@
+package lox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+
+	"github.com/taylorlowery/lox/internal/compiler"
+	"github.com/taylorlowery/lox/internal/host"
+	"github.com/taylorlowery/lox/internal/vm"
+)
+
+// Interpreter evaluates Lox source for a Go program embedding Lox.
+// Each call to Eval runs its source as a separate program,
+// so an Interpreter can be used by several goroutines at once.
+type Interpreter struct {
+	cfg *host.Config
+
+	// mu guards globals, which Set replaces rather than changes,
+	// so that the programs already running keep the globals they started with
+	mu      sync.Mutex
+	globals map[string]any
+}
+
+// NewInterpreter returns an Interpreter configured with opts.
+// The limits set by WithMaxSteps, WithMaxCallDepth and WithMaxMemory apply to each call to Eval,
+// and memory is counted across all of them, as MemoryUsage reports.
+func NewInterpreter(opts ...Option) (*Interpreter, error) {
+	cfg, err := host.NewConfig(opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Interpreter{cfg: cfg, globals: cfg.Globals}, nil
+}
+
+// Eval runs source as a Lox program and returns the value it produces.
+// It returns a SyntaxError if source isn't valid,
+// and a RuntimeError if the program fails or ctx, or the context set by WithContext,
+// is done before it finishes. A nil ctx is treated as context.Background.
+func (in *Interpreter) Eval(ctx context.Context, source string) (Value, error) {
+	expr, err := host.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+	c, err := in.cfg.Compile(in.cfg.OptimizeTree(expr))
+	if err != nil {
+		var compileErr compiler.CompileError
+		if errors.As(err, &compileErr) {
+			return nil, SyntaxError{Line: compileErr.Line, Message: compileErr.Message}
+		}
+		return nil, err
+	}
+
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if in.cfg.Context != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = mergeContexts(ctx, in.cfg.Context)
+		defer cancel()
+	}
+	machine, err := in.cfg.NewVM(vm.WithContext(ctx), vm.WithGlobals(in.snapshot()))
+	if err != nil {
+		return nil, err
+	}
+	return machine.Run(c)
+}
+
+// mergeContexts returns a context that's done when either ctx or other is,
+// with the values of ctx and the cause of whichever finished first.
+func mergeContexts(ctx, other context.Context) (context.Context, context.CancelFunc) {
+	merged, cancel := context.WithCancelCause(ctx)
+	if err := context.Cause(other); err != nil {
+		// AfterFunc would only cancel merged once its goroutine ran
+		cancel(err)
+	}
+	stop := context.AfterFunc(other, func() {
+		cancel(context.Cause(other))
+	})
+	return merged, func() {
+		stop()
+		cancel(context.Canceled)
+	}
+}
+
+// Call calls the global function called name with args, converted with ToValue,
+// and returns its result. It returns a RuntimeError if there's no such function,
+// if it's given the wrong number of arguments, or if it fails.
+// The limits set by WithMaxCallDepth and WithMaxMemory apply to the call.
+func (in *Interpreter) Call(name string, args ...any) (Value, error) {
+	fn, ok := in.Get(name)
+	if !ok {
+		return nil, RuntimeError{Message: fmt.Sprintf("Undefined variable '%s'.", name)}
+	}
+	values := make([]any, len(args))
+	for i, arg := range args {
+		v, err := ToValue(arg)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	machine, err := in.cfg.NewVM()
+	if err != nil {
+		return nil, err
+	}
+	return machine.Call(fn, values)
+}
+
+// Get returns the value of the global called name, and whether there is one.
+func (in *Interpreter) Get(name string) (Value, bool) {
+	v, ok := in.snapshot()[name]
+	return v, ok
+}
+
+// Set sets the global called name to v, converted with ToValue,
+// for the programs evaluated from then on.
+// It returns an error if name isn't an identifier or v can't be converted.
+func (in *Interpreter) Set(name string, v any) error {
+	if !host.IsIdentifier(name) {
+		return fmt.Errorf("invalid global name %q", name)
+	}
+	converted, err := ToValue(v)
+	if err != nil {
+		return err
+	}
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	globals := maps.Clone(in.globals)
+	globals[name] = converted
+	in.globals = globals
+	return nil
+}
+
+// snapshot returns the current globals, which mustn't be changed.
+func (in *Interpreter) snapshot() map[string]any {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	return in.globals
+}
+
+// MemoryUsage returns the number of bytes allocated by the programs the Interpreter is running.
+func (in *Interpreter) MemoryUsage() int64 {
+	return in.cfg.Memory.Used()
+}
@@ -0,0 +1,562 @@
+package lox_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/taylorlowery/lox/pkg/lox"
+)
+
+func TestInterpreter_Eval(t *testing.T) {
+	t.Parallel()
+
+	in, err := lox.NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		source string
+		want   lox.Value
+	}{
+		{"60 * 60 * 24", 86400.0},
+		{`"a" + "b"`, "ab"},
+		{"1 < 2", true},
+		{"nil", nil},
+		{"!nil ? 1 : 2", 1.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.source, func(t *testing.T) {
+			t.Parallel()
+			got, err := in.Eval(context.Background(), tc.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tc.want {
+				t.Fatalf("want %#v, got %#v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestInterpreter_EvalErrors(t *testing.T) {
+	t.Parallel()
+
+	in, err := lox.NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("scan error", func(t *testing.T) {
+		t.Parallel()
+		_, err := in.Eval(context.Background(), "1 +\n@")
+		var syntaxErr lox.SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 {
+			t.Fatalf("expected a SyntaxError on line 2, got %#v", err)
+		}
+	})
+
+	t.Run("parse error", func(t *testing.T) {
+		t.Parallel()
+		_, err := in.Eval(context.Background(), "1 +\n(2")
+		var syntaxErr lox.SyntaxError
+		if !errors.As(err, &syntaxErr) || syntaxErr.Line != 2 {
+			t.Fatalf("expected a SyntaxError on line 2, got %#v", err)
+		}
+	})
+
+	t.Run("runtime error", func(t *testing.T) {
+		t.Parallel()
+		_, err := in.Eval(context.Background(), "1,\n\"a\" - 1")
+		var runtimeErr lox.RuntimeError
+		if !errors.As(err, &runtimeErr) {
+			t.Fatalf("expected a RuntimeError, got %#v", err)
+		}
+		want := []lox.Frame{{Function: "script", Line: 2}}
+		if len(runtimeErr.Trace) != 1 || runtimeErr.Trace[0] != want[0] {
+			t.Fatalf("want trace %v, got %v", want, runtimeErr.Trace)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := in.Eval(ctx, "1")
+		if !errors.Is(err, lox.ErrCancelled) {
+			t.Fatalf("want %v, got %v", lox.ErrCancelled, err)
+		}
+	})
+}
+
+func TestInterpreter_EvalCombinesContexts(t *testing.T) {
+	t.Parallel()
+
+	expired, cancel := context.WithDeadline(context.Background(), time.Unix(0, 0))
+	defer cancel()
+	in, err := lox.NewInterpreter(lox.WithContext(expired))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = in.Eval(context.Background(), "1")
+	if !errors.Is(err, lox.ErrCancelled) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want %v from the interpreter's context, got %v", context.DeadlineExceeded, err)
+	}
+
+	in, err = lox.NewInterpreter(lox.WithContext(context.Background()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := in.Eval(nil, "1 + 2")
+	if err != nil || got != 3.0 {
+		t.Fatalf("want 3 for a nil context, got %v, %v", got, err)
+	}
+}
+
+func TestInterpreter_AppliesLimits(t *testing.T) {
+	t.Parallel()
+
+	for _, optimize := range []bool{true, false} {
+		in, err := lox.NewInterpreter(lox.WithOptimizer(optimize), lox.WithMaxMemory(4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := in.Eval(context.Background(), `"ab" + "cd"`); err != nil {
+			t.Fatalf("optimize %t: %v", optimize, err)
+		}
+		if _, err := in.Eval(context.Background(), `"ab" + "cde"`); !errors.Is(err, lox.ErrMemoryLimit) {
+			t.Fatalf("optimize %t: want %v, got %v", optimize, lox.ErrMemoryLimit, err)
+		}
+		if usage := in.MemoryUsage(); usage != 0 {
+			t.Fatalf("optimize %t: expected no memory in use between runs, got %d", optimize, usage)
+		}
+	}
+}
+
+func TestInterpreter_ChargesNativesForMemory(t *testing.T) {
+	t.Parallel()
+
+	in, err := lox.NewInterpreter(lox.WithMaxMemory(100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = in.Eval(context.Background(), `len(replace(replace(replace("aaaaaaaaaa", "a", "aaaaaaaaaa"), "a", "aaaaaaaaaa"), "a", "aaaaaaaaaa"))`)
+	if !errors.Is(err, lox.ErrMemoryLimit) {
+		t.Fatalf("want %v, got %v", lox.ErrMemoryLimit, err)
+	}
+	if usage := in.MemoryUsage(); usage != 0 {
+		t.Fatalf("expected no memory in use between runs, got %d", usage)
+	}
+}
+
+func TestInterpreter_CallsNatives(t *testing.T) {
+	t.Parallel()
+
+	errNoSuchUser := errors.New("No such user.")
+	in, err := lox.NewInterpreter(
+		lox.WithNative("double", 1, func(args []lox.Value) (lox.Value, error) {
+			n, err := lox.FromValue[int](args[0])
+			if err != nil {
+				return nil, err
+			}
+			return n * 2, nil
+		}),
+		lox.WithNative("user", 1, func(args []lox.Value) (lox.Value, error) {
+			return nil, errNoSuchUser
+		}),
+		lox.WithNative("first", 1, func(args []lox.Value) (lox.Value, error) {
+			return args[0].(*lox.List).Elements[0], nil
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := in.Eval(context.Background(), "double(21)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42.0 {
+		t.Fatalf("want 42, got %#v", got)
+	}
+
+	got, err = in.Eval(context.Background(), "clock() > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != true {
+		t.Fatalf("expected clock() to be positive")
+	}
+
+	_, err = in.Eval(context.Background(), `user("ada")`)
+	var runtimeErr lox.RuntimeError
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != "No such user." || !errors.Is(err, errNoSuchUser) {
+		t.Fatalf("expected a RuntimeError wrapping %v, got %#v", errNoSuchUser, err)
+	}
+
+	_, err = in.Eval(context.Background(), "double(1.5)")
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a RuntimeError, got %#v", err)
+	}
+
+	// a panic in the native is a runtime error, not a crash
+	_, err = in.Eval(context.Background(), "first([])")
+	want := "Native function first failed: runtime error: index out of range [0] with length 0."
+	if !errors.As(err, &runtimeErr) || runtimeErr.Message != want {
+		t.Fatalf("expected a RuntimeError %q, got %#v", want, err)
+	}
+}
+
+func TestInterpreter_GetsAndSetsGlobals(t *testing.T) {
+	t.Parallel()
+
+	in, err := lox.NewInterpreter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := in.Get("answer"); ok {
+		t.Fatal("expected answer to be undefined")
+	}
+	if err := in.Set("answer", 41); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := in.Get("answer"); !ok || got != 41.0 {
+		t.Fatalf("want 41, got %#v, %t", got, ok)
+	}
+	got, err := in.Eval(context.Background(), "answer + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42.0 {
+		t.Fatalf("want 42, got %#v", got)
+	}
+
+	if err := in.Set("names", []string{"ada"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.Eval(context.Background(), `push(names, "grace")`); err != nil {
+		t.Fatal(err)
+	}
+	names, _ := in.Get("names")
+	if got, err := lox.FromValue[[]string](names); err != nil || !cmp.Equal(got, []string{"ada", "grace"}) {
+		t.Fatalf("want [ada grace], got %v, %v", got, err)
+	}
+
+	if err := in.Set("not a name", 1); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+	var conversionErr lox.ConversionError
+	if err := in.Set("ch", make(chan int)); !errors.As(err, &conversionErr) {
+		t.Errorf("expected a ConversionError, got %v", err)
+	}
+}
+
+func TestInterpreter_Call(t *testing.T) {
+	t.Parallel()
+
+	in, err := lox.NewInterpreter(lox.WithMaxMemory(10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := in.Set("answer", 42); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := in.Call("max", 1, 2.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 2.5 {
+		t.Fatalf("want 2.5, got %#v", got)
+	}
+
+	testCases := []struct {
+		name string
+		fn   string
+		args []any
+		want string
+	}{
+		{name: "undefined", fn: "missing", want: "Undefined variable 'missing'."},
+		{name: "not a function", fn: "answer", want: "Can only call functions and classes."},
+		{name: "wrong arity", fn: "len", want: "Expected 1 arguments but got 0."},
+		{name: "bad argument", fn: "len", args: []any{1}, want: "Bad argument 1 to len: expected a string, a list or a map but got 1."},
+		{name: "memory limit", fn: "upper", args: []any{"abcdefghijk"}, want: lox.ErrMemoryLimit.Error()},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := in.Call(tc.fn, tc.args...)
+			var runtimeErr lox.RuntimeError
+			if !errors.As(err, &runtimeErr) || runtimeErr.Message != tc.want {
+				t.Fatalf("expected a RuntimeError %q, got %#v", tc.want, err)
+			}
+		})
+	}
+	if usage := in.MemoryUsage(); usage != 0 {
+		t.Fatalf("expected no memory in use between calls, got %d", usage)
+	}
+}
+
+func TestWithSeed_MakesRandomNumbersReproducible(t *testing.T) {
+	t.Parallel()
+
+	draw := func(seed uint64) []lox.Value {
+		in, err := lox.NewInterpreter(lox.WithSeed(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var results []lox.Value
+		for range 5 {
+			v, err := in.Eval(context.Background(), "randomInt(1, 1000000)")
+			if err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, v)
+		}
+		return results
+	}
+
+	a, b := draw(1), draw(1)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("run %d: same seed gave %v and %v", i, a[i], b[i])
+		}
+	}
+	if c := draw(2); c[0] == a[0] && c[1] == a[1] {
+		t.Fatal("different seeds gave the same numbers")
+	}
+}
+
+func TestWithNative_RejectsInvalidNatives(t *testing.T) {
+	t.Parallel()
+
+	fn := func(args []lox.Value) (lox.Value, error) { return nil, nil }
+	testCases := []struct {
+		name   string
+		option lox.Option
+	}{
+		{"empty name", lox.WithNative("", 0, fn)},
+		{"name with spaces", lox.WithNative("two words", 0, fn)},
+		{"keyword", lox.WithNative("nil", 0, fn)},
+		{"negative arity", lox.WithNative("f", -2, fn)},
+		{"too many arguments", lox.WithNative("f", 256, fn)},
+		{"nil function", lox.WithNative("f", 0, nil)},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if _, err := lox.NewInterpreter(tc.option); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+type celsius float64
+
+func TestToValue(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		value   any
+		want    lox.Value
+		wantErr bool
+	}{
+		{name: "nil", value: nil, want: nil},
+		{name: "bool", value: true, want: true},
+		{name: "string", value: "s", want: "s"},
+		{name: "float64", value: 1.5, want: 1.5},
+		{name: "float32", value: float32(0.5), want: 0.5},
+		{name: "int", value: 3, want: 3.0},
+		{name: "negative int8", value: int8(-3), want: -3.0},
+		{name: "uint64", value: uint64(1 << 53), want: float64(1 << 53)},
+		{name: "named type", value: celsius(21.5), want: 21.5},
+		{name: "large int", value: int64(1<<53 + 1), wantErr: true},
+		{name: "large uint", value: uint64(math.MaxUint64), wantErr: true},
+		{name: "slice", value: []int{1, 2}, want: &lox.List{Elements: []any{1.0, 2.0}}},
+		{name: "nested array", value: [1][]string{{"a"}}, want: &lox.List{Elements: []any{&lox.List{Elements: []any{"a"}}}}},
+		{name: "list", value: &lox.List{Elements: []any{true}}, want: &lox.List{Elements: []any{true}}},
+		{name: "slice of channels", value: []chan int{nil}, wantErr: true},
+		{name: "map with NaN key", value: map[float64]int{math.NaN(): 1}, wantErr: true},
+		{name: "map with list keys", value: map[[1]int]int{{1}: 1}, wantErr: true},
+		{name: "map with keys that convert alike", value: map[any]int{1: 1, 1.0: 2}, wantErr: true},
+		{name: "map of functions", value: map[string]func(){"f": nil}, wantErr: true},
+		{name: "struct", value: struct{}{}, wantErr: true},
+		{name: "pointer", value: new(int), wantErr: true},
+		{name: "nil pointer to a struct", value: (*Point)(nil), want: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := lox.ToValue(tc.value)
+			if tc.wantErr {
+				var conversionErr lox.ConversionError
+				if !errors.As(err, &conversionErr) {
+					t.Fatalf("expected a ConversionError, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestToValue_Maps(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"empty", map[string]int{}, "{}"},
+		{"sorted string keys", map[string]int{"b": 2, "c": 3, "a": 1}, "{a: 1, b: 2, c: 3}"},
+		{"sorted number keys", map[int]string{10: "x", -1: "y", 2: "z"}, "{-1: y, 2: z, 10: x}"},
+		{"mixed keys", map[any]bool{"a": true, 1: true, true: false, nil: true}, "{nil: true, true: false, 1: true, a: true}"},
+		{"nested", map[string][]map[string]int{"a": {{"b": 1}}}, "{a: [{b: 1}]}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := lox.ToValue(tc.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			m, ok := got.(*lox.Map)
+			if !ok {
+				t.Fatalf("want a *Map, got %#v", got)
+			}
+			if m.String() != tc.want {
+				t.Fatalf("want %s, got %s", tc.want, m)
+			}
+		})
+	}
+}
+
+func TestFromValue(t *testing.T) {
+	t.Parallel()
+
+	check := func(t *testing.T, got, want any, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Fatalf("want %#v, got %#v", want, got)
+		}
+	}
+
+	t.Run("conversions", func(t *testing.T) {
+		t.Parallel()
+		f, err := lox.FromValue[float64](1.5)
+		check(t, f, 1.5, err)
+		f32, err := lox.FromValue[float32](0.5)
+		check(t, f32, float32(0.5), err)
+		i, err := lox.FromValue[int](-3.0)
+		check(t, i, -3, err)
+		u, err := lox.FromValue[uint8](255.0)
+		check(t, u, uint8(255), err)
+		s, err := lox.FromValue[string]("s")
+		check(t, s, "s", err)
+		b, err := lox.FromValue[bool](true)
+		check(t, b, true, err)
+		c, err := lox.FromValue[celsius](21.5)
+		check(t, c, celsius(21.5), err)
+		a, err := lox.FromValue[any]("s")
+		check(t, a, "s", err)
+		n, err := lox.FromValue[any](nil)
+		check(t, n, nil, err)
+		p, err := lox.FromValue[*int](nil)
+		check(t, p, (*int)(nil), err)
+	})
+
+	t.Run("lists", func(t *testing.T) {
+		t.Parallel()
+		list := &lox.List{Elements: []any{1.0, 2.0}}
+		ints, err := lox.FromValue[[]int](list)
+		if err != nil || len(ints) != 2 || ints[0] != 1 || ints[1] != 2 {
+			t.Fatalf("want [1 2], got %v (%v)", ints, err)
+		}
+		pair, err := lox.FromValue[[2]float64](list)
+		check(t, pair, [2]float64{1, 2}, err)
+		same, err := lox.FromValue[*lox.List](list)
+		check(t, same, list, err)
+		nested, err := lox.FromValue[[][]string](&lox.List{Elements: []any{&lox.List{Elements: []any{"a"}}}})
+		if err != nil || len(nested) != 1 || len(nested[0]) != 1 || nested[0][0] != "a" {
+			t.Fatalf("want [[a]], got %v (%v)", nested, err)
+		}
+	})
+
+	t.Run("maps", func(t *testing.T) {
+		t.Parallel()
+		m := &lox.Map{}
+		for _, err := range []error{m.Set("a", 1.0), m.Set("b", 2.0)} {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		ints, err := lox.FromValue[map[string]int](m)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(map[string]int{"a": 1, "b": 2}, ints); diff != "" {
+			t.Fatalf("mismatch (-want +got):\n%s", diff)
+		}
+		same, err := lox.FromValue[*lox.Map](m)
+		check(t, same, m, err)
+	})
+
+	failures := []struct {
+		name    string
+		convert func() error
+	}{
+		{"fraction to int", func() error { _, err := lox.FromValue[int](1.5); return err }},
+		{"NaN to int", func() error { _, err := lox.FromValue[int](math.NaN()); return err }},
+		{"infinity to int64", func() error { _, err := lox.FromValue[int64](math.Inf(1)); return err }},
+		{"overflowing int8", func() error { _, err := lox.FromValue[int8](128.0); return err }},
+		{"negative to uint", func() error { _, err := lox.FromValue[uint](-1.0); return err }},
+		{"string to float64", func() error { _, err := lox.FromValue[float64]("1"); return err }},
+		{"number to string", func() error { _, err := lox.FromValue[string](1.0); return err }},
+		{"nil to bool", func() error { _, err := lox.FromValue[bool](nil); return err }},
+		{"string to error", func() error { _, err := lox.FromValue[error]("s"); return err }},
+		{"number to slice", func() error { _, err := lox.FromValue[[]int](1.0); return err }},
+		{"list of strings to ints", func() error {
+			_, err := lox.FromValue[[]int](&lox.List{Elements: []any{"a"}})
+			return err
+		}},
+		{"list to longer array", func() error { _, err := lox.FromValue[[3]int](&lox.List{Elements: []any{1.0}}); return err }},
+		{"number to list", func() error { _, err := lox.FromValue[*lox.List](1.0); return err }},
+		{"list to map", func() error { _, err := lox.FromValue[map[string]int](&lox.List{}); return err }},
+		{"map with a bad key", func() error {
+			m := &lox.Map{}
+			if err := m.Set(true, 1.0); err != nil {
+				return err
+			}
+			_, err := lox.FromValue[map[string]int](m)
+			return err
+		}},
+	}
+
+	for _, tc := range failures {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			var conversionErr lox.ConversionError
+			if err := tc.convert(); !errors.As(err, &conversionErr) {
+				t.Fatalf("expected a ConversionError, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+// Package lox embeds Lox in a Go program.
+// An Interpreter evaluates Lox source, and the program can give it
+// Go functions and types to call, and limits to run under.
+// ToValue and FromValue convert between Lox values and Go types.
+package lox
+
+import (
+	"context"
+
+	"github.com/taylorlowery/lox/internal/host"
+	"github.com/taylorlowery/lox/internal/value"
+	"github.com/taylorlowery/lox/internal/vm"
+)
+
+// Value is a Lox value as Go sees it: nil, a bool, a float64 number or a string,
+// a *List, a *Map, a native function, or an instance of a type bound with BindType.
+// ToValue and FromValue convert between Values and other Go types.
+type Value = any
+
+// List is a Lox list. A Go program can change its Elements,
+// and the Lox program holding it sees the change.
+type List = value.List
+
+// Map is a Lox map, which keeps its keys in the order they were added.
+// Like a List, it's shared with the Lox program holding it.
+type Map = value.Map
+
+// SyntaxError is returned by Eval for source that isn't a valid Lox program.
+// Line is 0 if the error couldn't be placed.
+type SyntaxError = host.SyntaxError
+
+// RuntimeError is returned by Eval when a program fails while it runs.
+// It carries the line the program failed on and a stack trace,
+// and unwraps to ErrCancelled, ErrStepLimit, ErrStackOverflow or ErrMemoryLimit
+// if the program was stopped by a limit.
+type RuntimeError = vm.RuntimeError
+
+// Frame is an entry in a RuntimeError's stack trace.
+type Frame = vm.Frame
+
+// ConversionError is returned when a value has no equivalent in the other language,
+// such as a Go channel, or the Lox number 1.5 as a Go int.
+type ConversionError = host.ConversionError
+
+// Errors returned when the VM stops a program because it hit a limit
+// set with WithContext, WithMaxSteps, WithMaxCallDepth or WithMaxMemory.
+var (
+	ErrCancelled     = vm.ErrCancelled
+	ErrStepLimit     = vm.ErrStepLimit
+	ErrStackOverflow = vm.ErrStackOverflow
+	ErrMemoryLimit   = vm.ErrMemoryLimit
+)
+
+// Option configures an Interpreter.
+type Option = host.Option
+
+// WithOptimizer turns the optimizations made to a program on or off:
+// folding constants in its syntax tree, and rewriting its bytecode. They're on by default.
+func WithOptimizer(enabled bool) Option {
+	return host.WithOptimizer(enabled)
+}
+
+// WithContext configures an Interpreter to stop running a program when ctx is done,
+// with an error wrapping ErrCancelled.
+// Eval also stops when the context it's given is done.
+func WithContext(ctx context.Context) Option {
+	return host.WithContext(ctx)
+}
+
+// WithMaxSteps configures an Interpreter to stop a program that runs more than n instructions,
+// with an error wrapping ErrStepLimit.
+func WithMaxSteps(n int) Option {
+	return host.WithMaxSteps(n)
+}
+
+// WithMaxCallDepth configures an Interpreter to stop a program whose calls nest
+// more than n frames deep, counting the program's own, with an error wrapping ErrStackOverflow.
+// Lox has no functions of its own yet, so a program's calls to natives are only ever 2 frames deep.
+// Expressions nest too, but Eval rejects those that nest too deeply to compile with a SyntaxError.
+func WithMaxCallDepth(n int) Option {
+	return host.WithMaxCallDepth(n)
+}
+
+// WithMaxMemory configures an Interpreter to stop a program that allocates more than n bytes,
+// with an error wrapping ErrMemoryLimit.
+// The optimizer leaves string concatenation for the program to do,
+// so that the strings it builds count towards the limit whether or not it's on.
+// The results of functions given with WithNative and WithFunc count too.
+func WithMaxMemory(n int64) Option {
+	return host.WithMaxMemory(n)
+}
+
+// WithNative configures an Interpreter to give programs a function written in Go,
+// called name, replacing any builtin of the same name.
+// arity is the number of arguments it takes, or -1 for any number.
+// fn's result is converted with ToValue, and an error it returns
+// stops the program with a runtime error carrying the error's message,
+// as does a panic in fn.
+// Lox prints the function as <native fn name>.
+func WithNative(name string, arity int, fn func(args []Value) (Value, error)) Option {
+	return host.WithNative(name, arity, fn)
+}
+
+// WithFunc configures an Interpreter to give programs an ordinary Go function,
+// called name, converting its arguments and results automatically.
+//
+// fn's parameters can be of any type FromValue converts to, and its last may be variadic.
+// It can return nothing, a value ToValue converts, an error, or a value and an error.
+// Calling it with arguments that can't be converted, or that make it panic,
+// raises a runtime error instead of crashing the host.
+func WithFunc(name string, fn any) Option {
+	return host.WithFunc(name, fn)
+}
+
+// BindType configures an Interpreter to give programs the struct type T as a class of the same name.
+// Calling the class with a value for each of T's exported fields, in order,
+// returns a new instance, as a *T. Its exported fields are properties the program can get and set,
+// and its exported methods, of T or *T, can be called as with WithFunc.
+// Arguments and values are converted as with FromValue and ToValue, which convert
+// any *T a Go program passes in or gets back, sharing the struct with the program.
+// Each instance a program creates counts the size of T towards WithMaxMemory.
+func BindType[T any]() Option {
+	return host.BindType[T]()
+}
+
+// WithSeed configures an Interpreter to seed the random numbers
+// the standard library gives programs, so that runs with the same seed
+// see the same numbers. Without it, the seed is itself random.
+func WithSeed(seed uint64) Option {
+	return host.WithSeed(seed)
+}
+
+// ToValue converts a Go value to a Lox value.
+// Booleans and strings convert to themselves, and any integer or floating point type to a number.
+// Integers outside ±2^53 are rejected, since they can't all be represented exactly,
+// as are types with no Lox equivalent, such as functions and structs other than by pointer.
+// A slice or array converts to a new list of its converted elements,
+// and a Go map to a new map of its converted keys and values, with the keys sorted
+// so that the conversion is repeatable. A pointer to a named struct type converts
+// to an instance of it, as BindType creates, or to nil if the pointer is nil.
+// A *List, *Map, native function or instance is already a Lox value.
+func ToValue(v any) (Value, error) {
+	return host.ToValue(v)
+}
+
+// FromValue converts a Lox value to the Go type T.
+// A bool or string converts to a type of the same kind,
+// and a number to any floating point type, or to an integer type
+// if it's a whole number in the type's range.
+// A list converts to a slice, or to an array of the same length, by converting each element,
+// and a map to a Go map by converting each key and value.
+// An instance converts to a pointer to its struct, or to a copy of the struct.
+// nil converts to the zero value of an interface, pointer, slice or map type,
+// and any value converts to an interface type it implements, such as any,
+// or to its own type, such as *List.
+func FromValue[T any](v Value) (T, error) {
+	return host.FromValue[T](v)
+}